@@ -2,23 +2,54 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
-	"github.com/robfig/cron/v3"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/socketmode"
 
 	"github.com/pratikgajjar/fambot-go/internal/config"
 	"github.com/pratikgajjar/fambot-go/internal/database"
+	"github.com/pratikgajjar/fambot-go/internal/directory"
 	"github.com/pratikgajjar/fambot-go/internal/handlers"
+	"github.com/pratikgajjar/fambot-go/internal/health"
+	"github.com/pratikgajjar/fambot-go/internal/httpserver"
+	"github.com/pratikgajjar/fambot-go/internal/lease"
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
+	"github.com/pratikgajjar/fambot-go/internal/oura"
+	"github.com/pratikgajjar/fambot-go/internal/outbox"
+	"github.com/pratikgajjar/fambot-go/internal/scheduler"
+	"github.com/pratikgajjar/fambot-go/internal/secrets"
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
 	"github.com/pratikgajjar/fambot-go/internal/whoop"
+	"github.com/pratikgajjar/fambot-go/internal/workerpool"
 )
 
+// migrateUsage explains the migrate subcommand
+const migrateUsage = `usage: fambot migrate [status|up]
+  status  list schema migrations and whether each is applied (default)
+  up      apply pending migrations without starting the bot`
+
 func main() {
+	if len(os.Args) > 1 {
+		if os.Args[1] != "migrate" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n", os.Args[1], migrateUsage)
+			os.Exit(2)
+		}
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -34,10 +65,10 @@ func main() {
 
 	// Validate tokens before proceeding
 	if !strings.HasPrefix(cfg.SlackBotToken, "xoxb-") {
-		log.Fatalf("SLACK_BOT_TOKEN should start with 'xoxb-', got: %s", cfg.SlackBotToken[:10]+"...")
+		log.Fatalf("SLACK_BOT_TOKEN should start with 'xoxb-'")
 	}
-	if !strings.HasPrefix(cfg.SlackAppToken, "xapp-") {
-		log.Fatalf("SLACK_APP_TOKEN should start with 'xapp-', got: %s", cfg.SlackAppToken[:10]+"...")
+	if cfg.SlackMode == config.SlackModeSocket && !strings.HasPrefix(cfg.SlackAppToken, "xapp-") {
+		log.Fatalf("SLACK_APP_TOKEN should start with 'xapp-'")
 	}
 	log.Printf("Token validation passed")
 
@@ -47,11 +78,7 @@ func main() {
 		slack.OptionDebug(cfg.Debug),
 		slack.OptionLog(log.New(os.Stdout, "api: ", log.LstdFlags|log.Lshortfile)),
 		slack.OptionAppLevelToken(cfg.SlackAppToken),
-	)
-	socketClient := socketmode.New(
-		client,
-		socketmode.OptionDebug(cfg.Debug),
-		socketmode.OptionLog(log.New(os.Stdout, "socketmode: ", log.LstdFlags|log.Lshortfile)),
+		slack.OptionHTTPClient(&http.Client{Transport: metrics.NewTransport("slack", nil)}),
 	)
 
 	// Get bot user info
@@ -61,64 +88,126 @@ func main() {
 	}
 	log.Printf("Bot authenticated as %s (%s)", authTest.User, authTest.UserID)
 
-	// Initialize WHOOP services (if configured)
-	var whoopService *whoop.Service
-	var whoopServer *whoop.OAuthServer
+	// Initialize wearable providers (if configured)
+	var providers []wearables.Provider
 	if cfg.WHOOPClientID != "" && cfg.WHOOPClientSecret != "" {
 		whoopClient := whoop.NewClient(cfg.WHOOPClientID, cfg.WHOOPClientSecret, cfg.WHOOPRedirectURL)
-		whoopService = whoop.NewService(whoopClient, db)
-		whoopServer = whoop.NewOAuthServer(whoopService, "8080")
+		providers = append(providers, whoop.NewProvider(whoopClient))
 		log.Printf("WHOOP integration enabled")
 	} else {
 		log.Printf("WHOOP integration disabled - missing WHOOP_CLIENT_ID or WHOOP_CLIENT_SECRET")
 	}
+	if cfg.OuraClientID != "" && cfg.OuraClientSecret != "" {
+		ouraClient := oura.NewClient(cfg.OuraClientID, cfg.OuraClientSecret, cfg.OuraRedirectURL)
+		providers = append(providers, oura.NewProvider(ouraClient))
+		log.Printf("Oura integration enabled")
+	} else {
+		log.Printf("Oura integration disabled - missing OURA_CLIENT_ID or OURA_CLIENT_SECRET")
+	}
+
+	var whoopService *whoop.Service
+	if len(providers) > 0 {
+		tokenKeys, err := secrets.ParseKeyring(cfg.WHOOPTokenKeys)
+		if err != nil {
+			log.Fatalf("Invalid WHOOP_TOKEN_KEYS: %v", err)
+		}
+		db.SetTokenKeyring(tokenKeys)
+
+		rewritten, err := db.EncryptWHOOPTokens()
+		if err != nil {
+			log.Fatalf("Failed to encrypt stored WHOOP tokens: %v", err)
+		}
+		if rewritten > 0 {
+			log.Printf("Encrypted stored WHOOP tokens for %d connections", rewritten)
+		}
+
+		whoopService = whoop.NewService(db)
+		for _, provider := range providers {
+			whoopService.RegisterProvider(provider)
+		}
+	}
 
 	// Initialize handlers
 	handler := handlers.New(client, db, cfg.PeopleChannel, cfg.GratefulChannel, cfg.StandupChannel, whoopService)
 	handler.SetBotID(authTest.UserID)
 	handler.SetWorkspaceID(authTest.TeamID)
+	handler.SetStandupMinSharing(cfg.WHOOPStandupMinSharing)
 
-	// Set up socket mode event handler
-	go func() {
-		for evt := range socketClient.Events {
-			handler.HandleSocketModeEvent(evt, socketClient)
-		}
-	}()
+	// Resolve users and channels through a cache rather than asking Slack on
+	// every message
+	dir := directory.New(client, db, cfg.DirectoryTTL)
+	handler.SetDirectory(dir)
 
-	// Set up cron jobs for birthday and anniversary reminders
-	c := cron.New()
+	// Replicas share the database and tell themselves apart by instance ID
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		instanceID = lease.DefaultHolder()
+	}
 
-	// Check for birthdays and anniversaries daily at 9 AM
-	_, err = c.AddFunc("0 9 * * *", func() {
-		log.Println("Running daily birthday check...")
-		handler.SendBirthdayReminder()
-	})
-	if err != nil {
-		log.Printf("Failed to add birthday cron job: %v", err)
+	// Post messages through a persistent queue so rate limits and Slack
+	// outages delay them instead of losing them. Each message is claimed
+	// before posting, so only one replica posts it.
+	messageQueue := outbox.New(client, db, instanceID)
+	handler.SetOutbox(messageQueue)
+	messageQueue.Start()
+
+	// Handle events on a worker pool so one slow Slack call doesn't hold up
+	// every other channel
+	eventPool := workerpool.New(cfg.EventWorkers, cfg.EventQueueSize)
+	handler.SetEventPool(eventPool)
+	if whoopService != nil {
+		whoopService.SetNotifier(handler.SendDirectMessage)
+	}
+
+	// Schedule the recurring jobs. Runs are recorded in the database, so
+	// reminders missed while the bot was down are caught up at startup, and
+	// outcomes show up in metrics, readiness and /fambot-jobs.
+	sched := scheduler.New(db, cfg.ScheduleLocation)
+	handler.SetScheduler(sched)
+	handler.SetAdmins(cfg.AdminUserIDs)
+
+	addJob := func(name string, catchUp bool, fn func() error) {
+		if err := sched.Add(name, cfg.Schedules[name], catchUp, fn); err != nil {
+			log.Printf("Failed to add %s job: %v", name, err)
+		}
 	}
 
-	_, err = c.AddFunc("0 9 * * *", func() {
-		log.Println("Running daily anniversary check...")
+	addJob("birthdays", true, func() error {
+		handler.SendBirthdayReminder()
+		return nil
+	})
+	addJob("anniversaries", true, func() error {
 		handler.SendAnniversaryReminder()
+		return nil
 	})
-	if err != nil {
-		log.Printf("Failed to add anniversary cron job: %v", err)
-	}
 
-	// Add WHOOP morning standup (if WHOOP is configured)
+	// Forget old event deliveries
+	addJob("event_dedup_purge", false, handler.PurgeProcessedEvents)
+
+	// Add wearables morning standup (if a provider is configured)
 	if whoopService != nil {
-		_, err = c.AddFunc("0 9 * * *", func() {
-			log.Println("Running morning WHOOP standup...")
+		addJob("morning_standup", true, func() error {
 			handler.SendMorningStandup()
+			return nil
+		})
+	}
+
+	// Purge wellness data past the retention window (if configured)
+	if whoopService != nil && cfg.WHOOPRetentionDays > 0 {
+		addJob("wellness_purge", false, func() error {
+			_, err := whoopService.PurgeExpiredData(cfg.WHOOPRetentionDays)
+			return err
 		})
-		if err != nil {
-			log.Printf("Failed to add WHOOP standup cron job: %v", err)
-		}
 	}
 
-	// Start cron scheduler
-	c.Start()
-	defer c.Stop()
+	// Replicas share the database; only the one holding the scheduler lease
+	// runs scheduled jobs, and it catches up on missed ones when elected.
+	// Every replica still handles events.
+	elector := lease.NewElector(db, "scheduler", instanceID, cfg.LeaseTTL)
+	sched.SetLeaderCheck(elector.IsLeader)
+	elector.OnElected(sched.CatchUp)
+
+	sched.Start()
 
 	// Set up graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -128,26 +217,169 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	// Start OAuth server (if WHOOP is configured)
-	if whoopServer != nil {
-		go func() {
-			if err := whoopServer.Start(); err != nil {
-				log.Printf("OAuth server error: %v", err)
-			}
-		}()
+	electorDone := make(chan struct{})
+	go func() {
+		defer close(electorDone)
+		elector.Run(ctx)
+	}()
+
+	// Load every user and channel into the directory in the background;
+	// lookups fall back to Slack until it finishes
+	go func() {
+		if err := dir.Warm(ctx); err != nil {
+			log.Printf("Failed to warm directory: %v", err)
+		}
+	}()
+
+	// Process WHOOP webhook deliveries in the background
+	if whoopService != nil {
+		go whoopService.ProcessWebhooks(ctx)
 	}
 
-	// Start socket mode client in a goroutine
+	// Serve health probes and metrics. The hourly purge means a job should
+	// have run within the last couple of hours.
+	healthServer := health.NewServer(cfg.HealthAddr)
+	healthServer.AddCheck("database", db.Ping)
+	if cfg.SlackMode == config.SlackModeSocket {
+		healthServer.AddCheck("slack", func(ctx context.Context) error {
+			if !handler.Connected() {
+				return errors.New("socket mode is not connected")
+			}
+			return nil
+		})
+	}
+	healthServer.AddCheck("cron", sched.Check(2*time.Hour))
 	go func() {
-		log.Println("Starting FamBot...")
-		err := socketClient.RunContext(ctx)
-		if err != nil {
-			log.Printf("Socket mode client error: %v", err)
+		if err := healthServer.Start(); err != nil {
+			log.Printf("Health server error: %v", err)
 		}
 	}()
 
+	// Serve OAuth callbacks (if a provider is configured) and, in http mode,
+	// Slack requests
+	var httpServer *httpserver.Server
+	if whoopService != nil || cfg.SlackMode == config.SlackModeHTTP {
+		httpServer = httpserver.New(httpserver.Config{
+			Addr:         cfg.HTTPAddr,
+			ReadTimeout:  cfg.HTTPReadTimeout,
+			WriteTimeout: cfg.HTTPWriteTimeout,
+			TLSCertFile:  cfg.HTTPTLSCertFile,
+			TLSKeyFile:   cfg.HTTPTLSKeyFile,
+		})
+		if whoopService != nil {
+			whoop.NewOAuthServer(whoopService).RegisterRoutes(httpServer.Mux())
+		}
+		if cfg.SlackMode == config.SlackModeHTTP {
+			handler.RegisterHTTPRoutes(httpServer.Mux(), cfg.SlackSigningSecret)
+		}
+		go func() {
+			if err := httpServer.Start(); err != nil {
+				log.Printf("HTTP server error: %v", err)
+			}
+		}()
+	}
+
+	if cfg.SlackMode == config.SlackModeSocket {
+		socketClient := socketmode.New(
+			client,
+			socketmode.OptionDebug(cfg.Debug),
+			socketmode.OptionLog(log.New(os.Stdout, "socketmode: ", log.LstdFlags|log.Lshortfile)),
+		)
+
+		// Set up socket mode event handler
+		go func() {
+			for evt := range socketClient.Events {
+				handler.HandleSocketModeEvent(evt, socketClient)
+			}
+		}()
+
+		// Start socket mode client in a goroutine
+		go func() {
+			log.Println("Starting FamBot in socket mode...")
+			err := socketClient.RunContext(ctx)
+			if err != nil {
+				log.Printf("Socket mode client error: %v", err)
+			}
+		}()
+	} else {
+		log.Println("Starting FamBot in http mode...")
+	}
+
 	// Wait for interrupt signal
 	<-sigChan
 	log.Println("Shutting down FamBot...")
 	cancel()
+
+	// Let in-flight requests, queued events and probes finish
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if httpServer != nil {
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("HTTP server shutdown error: %v", err)
+		}
+	}
+	// Finish events that were already acknowledged
+	if err := eventPool.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Event queue did not drain: %v", err)
+	}
+	// Let running jobs finish before their messages' queue stops
+	if err := sched.Stop(shutdownCtx); err != nil {
+		log.Printf("Scheduled jobs did not finish: %v", err)
+	}
+	// Hand the lease over without waiting for it to expire
+	select {
+	case <-electorDone:
+	case <-shutdownCtx.Done():
+	}
+	// Messages still waiting are posted on the next start
+	if err := messageQueue.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Message queue shutdown error: %v", err)
+	}
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Health server shutdown error: %v", err)
+	}
+}
+
+// runMigrate runs `fambot migrate [status|up]` against the configured
+// database. Only DATABASE_PATH is needed, so it works without Slack tokens.
+func runMigrate(args []string) error {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if len(args) > 1 || (command != "status" && command != "up") {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := database.Open(config.DatabasePath())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if command == "up" {
+		if err := db.Migrate(ctx); err != nil {
+			return err
+		}
+	}
+
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, status := range statuses {
+		state := "applied " + status.AppliedAt.Local().Format(time.RFC3339)
+		switch {
+		case status.Unknown:
+			state += " (unknown to this version)"
+		case status.AppliedAt.IsZero():
+			state = "pending"
+			pending++
+		}
+		fmt.Printf("%04d  %-24s %s\n", status.Version, status.Name, state)
+	}
+	fmt.Printf("%d pending\n", pending)
+	return nil
 }
@@ -3,22 +3,95 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
 )
 
+// Slack connection modes
+const (
+	// SlackModeSocket receives events over a Socket Mode websocket
+	SlackModeSocket = "socket"
+	// SlackModeHTTP receives events as signed HTTP requests
+	SlackModeHTTP = "http"
+)
+
+// defaultSchedules are the cron specs of the scheduled jobs, by job name.
+// Each can be overridden with SCHEDULE_<NAME>, e.g. SCHEDULE_BIRTHDAYS.
+var defaultSchedules = map[string]string{
+	"birthdays":         "0 9 * * *",
+	"anniversaries":     "0 9 * * *",
+	"morning_standup":   "0 9 * * *",
+	"wellness_purge":    "0 3 * * *",
+	"event_dedup_purge": "@hourly",
+}
+
+// minRetentionDays is the shortest retention window that keeps 30-day
+// baselines and challenges working
+const minRetentionDays = 30
+
 // Config holds all configuration for the application
 type Config struct {
-	SlackBotToken    string
-	SlackAppToken    string
-	DatabasePath     string
-	PeopleChannel    string
-	GratefulChannel  string
-	StandupChannel   string
-	WHOOPClientID    string
-	WHOOPClientSecret string
-	WHOOPRedirectURL string
-	Debug            bool
+	SlackBotToken      string
+	SlackAppToken      string
+	SlackSigningSecret string
+	SlackMode          string // SlackModeSocket or SlackModeHTTP
+	DatabasePath       string
+	PeopleChannel      string
+	GratefulChannel    string
+	StandupChannel     string
+	WHOOPClientID      string
+	WHOOPClientSecret  string
+	WHOOPRedirectURL   string
+	WHOOPTokenKeys     string
+	OuraClientID       string
+	OuraClientSecret   string
+	OuraRedirectURL    string
+	// HTTPAddr is the listen address of the server for OAuth callbacks and,
+	// in http mode, Slack requests
+	HTTPAddr         string
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	// HTTPTLSCertFile and HTTPTLSKeyFile enable HTTPS when both are set
+	HTTPTLSCertFile string
+	HTTPTLSKeyFile  string
+	// HealthAddr is the listen address for /healthz, /readyz and /metrics
+	HealthAddr string
+	// EventWorkers handle Slack events in parallel, each queueing up to
+	// EventQueueSize events; a channel's events always share a worker
+	EventWorkers   int
+	EventQueueSize int
+	// DirectoryTTL is how long cached Slack users and channels are trusted
+	// before they are fetched again
+	DirectoryTTL time.Duration
+	// Schedules are cron specs by job name, evaluated in ScheduleLocation
+	Schedules        map[string]string
+	ScheduleLocation *time.Location
+	// AdminUserIDs may list and trigger scheduled jobs
+	AdminUserIDs []string
+	// InstanceID names this instance when electing which replica runs
+	// scheduled jobs and claiming queued messages; empty means hostname:pid
+	InstanceID string
+	// LeaseTTL is how long the scheduler lease lasts without renewal, and so
+	// how quickly another replica takes over if the leader dies
+	LeaseTTL time.Duration
+	// WHOOPStandupMinSharing hides individual standup rows while fewer than
+	// this many users share them; 0 disables aggregate-only mode
+	WHOOPStandupMinSharing int
+	// WHOOPRetentionDays is how long daily wellness records are kept before
+	// the nightly purge deletes them; 0 keeps them forever
+	WHOOPRetentionDays int
+	Debug              bool
+}
+
+// DatabasePath returns the configured database path without loading or
+// validating the rest of the configuration, for maintenance commands
+func DatabasePath() string {
+	_ = godotenv.Load()
+	return getEnvOrDefault("DATABASE_PATH", "fambot.db")
 }
 
 // Load loads configuration from environment variables
@@ -27,16 +100,93 @@ func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	config := &Config{
-		SlackBotToken:    os.Getenv("SLACK_BOT_TOKEN"),
-		SlackAppToken:    os.Getenv("SLACK_APP_TOKEN"),
-		DatabasePath:     getEnvOrDefault("DATABASE_PATH", "fambot.db"),
-		PeopleChannel:    getEnvOrDefault("PEOPLE_CHANNEL", "people"),
-		GratefulChannel:  getEnvOrDefault("GRATEFUL_CHANNEL", "thankyou"),
-		StandupChannel:   getEnvOrDefault("STANDUP_CHANNEL", "general"),
-		WHOOPClientID:    os.Getenv("WHOOP_CLIENT_ID"),
-		WHOOPClientSecret: os.Getenv("WHOOP_CLIENT_SECRET"),
-		WHOOPRedirectURL: getEnvOrDefault("WHOOP_REDIRECT_URL", "http://localhost:8080/whoop/callback"),
-		Debug:            os.Getenv("DEBUG") == "true",
+		SlackBotToken:      os.Getenv("SLACK_BOT_TOKEN"),
+		SlackAppToken:      os.Getenv("SLACK_APP_TOKEN"),
+		SlackMode:          getEnvOrDefault("SLACK_MODE", SlackModeSocket),
+		SlackSigningSecret: os.Getenv("SLACK_SIGNING_SECRET"),
+		DatabasePath:       getEnvOrDefault("DATABASE_PATH", "fambot.db"),
+		PeopleChannel:      getEnvOrDefault("PEOPLE_CHANNEL", "people"),
+		GratefulChannel:    getEnvOrDefault("GRATEFUL_CHANNEL", "thankyou"),
+		StandupChannel:     getEnvOrDefault("STANDUP_CHANNEL", "general"),
+		WHOOPClientID:      os.Getenv("WHOOP_CLIENT_ID"),
+		WHOOPClientSecret:  os.Getenv("WHOOP_CLIENT_SECRET"),
+		WHOOPRedirectURL:   getEnvOrDefault("WHOOP_REDIRECT_URL", "http://localhost:8080/whoop/callback"),
+		WHOOPTokenKeys:     os.Getenv("WHOOP_TOKEN_KEYS"),
+		OuraClientID:       os.Getenv("OURA_CLIENT_ID"),
+		OuraClientSecret:   os.Getenv("OURA_CLIENT_SECRET"),
+		OuraRedirectURL:    getEnvOrDefault("OURA_REDIRECT_URL", "http://localhost:8080/oura/callback"),
+		HTTPAddr:           getEnvOrDefault("HTTP_ADDR", ":8080"),
+		HTTPTLSCertFile:    os.Getenv("HTTP_TLS_CERT_FILE"),
+		HTTPTLSKeyFile:     os.Getenv("HTTP_TLS_KEY_FILE"),
+		HealthAddr:         getEnvOrDefault("HEALTH_ADDR", ":9090"),
+		InstanceID:         os.Getenv("INSTANCE_ID"),
+		Debug:              os.Getenv("DEBUG") == "true",
+	}
+
+	minSharing, err := getEnvIntOrDefault("WHOOP_STANDUP_MIN_SHARING", 0)
+	if err != nil {
+		return nil, err
+	}
+	config.WHOOPStandupMinSharing = minSharing
+
+	retentionDays, err := getEnvIntOrDefault("WHOOP_RETENTION_DAYS", 0)
+	if err != nil {
+		return nil, err
+	}
+	config.WHOOPRetentionDays = retentionDays
+
+	eventWorkers, err := getEnvIntOrDefault("EVENT_WORKERS", 8)
+	if err != nil {
+		return nil, err
+	}
+	config.EventWorkers = eventWorkers
+
+	eventQueueSize, err := getEnvIntOrDefault("EVENT_QUEUE_SIZE", 100)
+	if err != nil {
+		return nil, err
+	}
+	config.EventQueueSize = eventQueueSize
+
+	readTimeout, err := getEnvDurationOrDefault("HTTP_READ_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	config.HTTPReadTimeout = readTimeout
+
+	// OAuth callbacks wait on the provider's token and profile endpoints
+	writeTimeout, err := getEnvDurationOrDefault("HTTP_WRITE_TIMEOUT", 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	config.HTTPWriteTimeout = writeTimeout
+
+	directoryTTL, err := getEnvDurationOrDefault("DIRECTORY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	config.DirectoryTTL = directoryTTL
+
+	leaseTTL, err := getEnvDurationOrDefault("LEASE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	config.LeaseTTL = leaseTTL
+
+	config.Schedules = make(map[string]string, len(defaultSchedules))
+	for name, spec := range defaultSchedules {
+		config.Schedules[name] = getEnvOrDefault("SCHEDULE_"+strings.ToUpper(name), spec)
+	}
+
+	location, err := time.LoadLocation(getEnvOrDefault("SCHEDULE_TIMEZONE", "Local"))
+	if err != nil {
+		return nil, fmt.Errorf("SCHEDULE_TIMEZONE must be an IANA time zone such as America/New_York: %w", err)
+	}
+	config.ScheduleLocation = location
+
+	for _, id := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			config.AdminUserIDs = append(config.AdminUserIDs, id)
+		}
 	}
 
 	if err := config.validate(); err != nil {
@@ -51,12 +201,71 @@ func (c *Config) validate() error {
 	if c.SlackBotToken == "" {
 		return fmt.Errorf("SLACK_BOT_TOKEN is required")
 	}
-	if c.SlackAppToken == "" {
-		return fmt.Errorf("SLACK_APP_TOKEN is required")
+	switch c.SlackMode {
+	case SlackModeSocket:
+		if c.SlackAppToken == "" {
+			return fmt.Errorf("SLACK_APP_TOKEN is required in socket mode")
+		}
+	case SlackModeHTTP:
+		if c.SlackSigningSecret == "" {
+			return fmt.Errorf("SLACK_SIGNING_SECRET is required in http mode")
+		}
+	default:
+		return fmt.Errorf("SLACK_MODE must be %q or %q", SlackModeSocket, SlackModeHTTP)
+	}
+	if (c.WHOOPClientID != "" || c.OuraClientID != "") && c.WHOOPTokenKeys == "" {
+		return fmt.Errorf("WHOOP_TOKEN_KEYS is required when WHOOP or Oura integration is enabled")
+	}
+	if c.EventWorkers < 1 {
+		return fmt.Errorf("EVENT_WORKERS must be at least 1")
+	}
+	if (c.HTTPTLSCertFile == "") != (c.HTTPTLSKeyFile == "") {
+		return fmt.Errorf("HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together")
+	}
+	// Renewals happen every third of the ttl
+	if c.LeaseTTL < 3*time.Second {
+		return fmt.Errorf("LEASE_TTL must be at least 3s")
+	}
+	for name, spec := range c.Schedules {
+		if _, err := cron.ParseStandard(spec); err != nil {
+			return fmt.Errorf("SCHEDULE_%s is not a valid cron schedule: %w", strings.ToUpper(name), err)
+		}
+	}
+	// Personal baselines and challenges look back 30 days
+	if c.WHOOPRetentionDays > 0 && c.WHOOPRetentionDays < minRetentionDays {
+		return fmt.Errorf("WHOOP_RETENTION_DAYS must be 0 (keep forever) or at least %d", minRetentionDays)
 	}
 	return nil
 }
 
+// getEnvIntOrDefault parses a non-negative integer environment variable,
+// returning defaultValue when it is unset
+func getEnvIntOrDefault(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+// getEnvDurationOrDefault parses a positive duration environment variable
+// such as "30s", returning defaultValue when it is unset
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 30s", key)
+	}
+	return d, nil
+}
+
 // getEnvOrDefault returns the environment variable value or a default value
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
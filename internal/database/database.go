@@ -1,37 +1,34 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pratikgajjar/fambot-go/internal/models"
+	"github.com/pratikgajjar/fambot-go/internal/secrets"
 )
 
 // Database wraps the sql.DB connection and provides methods
 type Database struct {
-	db *sql.DB
+	db        *sql.DB
+	tokenKeys *secrets.Keyring
 }
 
-// New creates a new database connection and initializes tables
+// New opens the database and migrates it to the latest schema
 func New(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	database, err := Open(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
-	database := &Database{db: db}
-
-	// Initialize tables
-	if err := database.createTables(); err != nil {
-		return nil, fmt.Errorf("failed to create tables: %w", err)
+	if err := database.Migrate(context.Background()); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	// Insert default sassy responses
@@ -42,139 +39,134 @@ func New(dbPath string) (*Database, error) {
 	return database, nil
 }
 
+// Open opens the database without migrating it, for tools that inspect it
+func Open(dbPath string) (*Database, error) {
+	// Concurrent WHOOP syncs write from several goroutines; wait on SQLite's
+	// write lock instead of failing immediately with "database is locked"
+	dsn := dbPath
+	if strings.Contains(dsn, "?") {
+		dsn += "&_busy_timeout=5000"
+	} else {
+		dsn += "?_busy_timeout=5000"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &Database{db: db}, nil
+}
+
 // Close closes the database connection
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-// createTables creates all necessary tables
-func (d *Database) createTables() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			username TEXT NOT NULL,
-			real_name TEXT,
-			email TEXT
-		)`,
-		`CREATE TABLE IF NOT EXISTS karma (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			username TEXT NOT NULL,
-			score INTEGER DEFAULT 0,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS karma_log (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			given_by TEXT NOT NULL,
-			reason TEXT,
-			change INTEGER NOT NULL,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-			channel TEXT
-		)`,
-		`CREATE TABLE IF NOT EXISTS birthdays (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			username TEXT NOT NULL,
-			month INTEGER NOT NULL,
-			day INTEGER NOT NULL,
-			year INTEGER DEFAULT 0,
-			timezone TEXT DEFAULT 'UTC',
-			UNIQUE(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS anniversaries (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			username TEXT NOT NULL,
-			month INTEGER NOT NULL,
-			day INTEGER NOT NULL,
-			year INTEGER NOT NULL,
-			timezone TEXT DEFAULT 'UTC',
-			UNIQUE(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS sassy_responses (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			response TEXT NOT NULL,
-			category TEXT NOT NULL,
-			active BOOLEAN DEFAULT 1
-		)`,
-		`CREATE TABLE IF NOT EXISTS whoop_connections (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			whoop_user_id TEXT NOT NULL,
-			access_token TEXT NOT NULL,
-			refresh_token TEXT NOT NULL,
-			expires_at DATETIME NOT NULL,
-			connected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			active BOOLEAN DEFAULT 1,
-			UNIQUE(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS whoop_recovery (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			whoop_user_id TEXT NOT NULL,
-			date DATE NOT NULL,
-			score INTEGER NOT NULL,
-			hrv REAL NOT NULL,
-			rhr INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(user_id, date)
-		)`,
-		`CREATE TABLE IF NOT EXISTS whoop_sleep (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			whoop_user_id TEXT NOT NULL,
-			date DATE NOT NULL,
-			duration_ms INTEGER NOT NULL,
-			efficiency REAL NOT NULL,
-			score INTEGER NOT NULL,
-			stages_deep_ms INTEGER NOT NULL,
-			stages_rem_ms INTEGER NOT NULL,
-			stages_light_ms INTEGER NOT NULL,
-			stages_wake_ms INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(user_id, date)
-		)`,
-		`CREATE TABLE IF NOT EXISTS whoop_strain (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id TEXT NOT NULL,
-			whoop_user_id TEXT NOT NULL,
-			date DATE NOT NULL,
-			score REAL NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(user_id, date)
-		)`,
-	}
-
-	for _, query := range queries {
-		if _, err := d.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query %s: %w", query, err)
-		}
-	}
+// Ping checks that the database is reachable
+func (d *Database) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
 
-	return nil
+// SetTokenKeyring sets the keyring used to encrypt OAuth tokens at rest
+func (d *Database) SetTokenKeyring(keyring *secrets.Keyring) {
+	d.tokenKeys = keyring
 }
 
 // User operations
 func (d *Database) UpsertUser(user *models.User) error {
-	query := `INSERT OR REPLACE INTO users (id, username, real_name, email) VALUES (?, ?, ?, ?)`
-	_, err := d.db.Exec(query, user.ID, user.Username, user.RealName, user.Email)
+	query := `INSERT OR REPLACE INTO users (id, username, real_name, email, timezone, synced_at) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := d.db.Exec(query, user.ID, user.Username, user.RealName, user.Email, user.Timezone, user.SyncedAt.UTC())
 	return err
 }
 
+// UpsertUsers stores many users in one transaction
+func (d *Database) UpsertUsers(users []models.User) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO users (id, username, real_name, email, timezone, synced_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, user := range users {
+		if _, err := stmt.Exec(user.ID, user.Username, user.RealName, user.Email, user.Timezone, user.SyncedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to store user %s: %w", user.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (d *Database) GetUser(userID string) (*models.User, error) {
-	query := `SELECT id, username, real_name, email FROM users WHERE id = ?`
+	query := `SELECT id, username, COALESCE(real_name, ''), COALESCE(email, ''), COALESCE(timezone, ''), synced_at FROM users WHERE id = ?`
 	row := d.db.QueryRow(query, userID)
 
 	var user models.User
-	err := row.Scan(&user.ID, &user.Username, &user.RealName, &user.Email)
+	var syncedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &user.RealName, &user.Email, &user.Timezone, &syncedAt)
 	if err != nil {
 		return nil, err
 	}
+	user.SyncedAt = syncedAt.Time
 	return &user, nil
 }
 
+// Channel operations
+
+// UpsertChannels stores channels in one transaction
+func (d *Database) UpsertChannels(channels []models.Channel) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO channels (id, name, is_private, synced_at) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, channel := range channels {
+		if _, err := stmt.Exec(channel.ID, channel.Name, channel.IsPrivate, channel.SyncedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to store channel %s: %w", channel.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetChannel returns a cached channel by ID
+func (d *Database) GetChannel(channelID string) (*models.Channel, error) {
+	row := d.db.QueryRow(`SELECT id, name, is_private, synced_at FROM channels WHERE id = ?`, channelID)
+	return scanChannel(row)
+}
+
+// GetChannelByName returns a cached channel by name, without the leading #
+func (d *Database) GetChannelByName(name string) (*models.Channel, error) {
+	row := d.db.QueryRow(`SELECT id, name, is_private, synced_at FROM channels WHERE name = ? ORDER BY synced_at DESC LIMIT 1`, name)
+	return scanChannel(row)
+}
+
+func scanChannel(row *sql.Row) (*models.Channel, error) {
+	var channel models.Channel
+	var syncedAt sql.NullTime
+	if err := row.Scan(&channel.ID, &channel.Name, &channel.IsPrivate, &syncedAt); err != nil {
+		return nil, err
+	}
+	channel.SyncedAt = syncedAt.Time
+	return &channel, nil
+}
+
 // Karma operations
 func (d *Database) GetKarma(userID string) (*models.Karma, error) {
 	query := `SELECT id, user_id, username, score, updated_at FROM karma WHERE user_id = ?`
@@ -188,14 +180,30 @@ func (d *Database) GetKarma(userID string) (*models.Karma, error) {
 	return &karma, nil
 }
 
-func (d *Database) IncrementKarma(userID, username, givenBy, reason, channel string) error {
+// IncrementKarma gives userID a karma point for the message at messageTS in
+// channel. It reports false, changing nothing, if that message already gave
+// them karma, so redelivered events aren't counted twice.
+func (d *Database) IncrementKarma(userID, username, givenBy, reason, channel, messageTS string) (bool, error) {
 	// Start transaction
 	tx, err := d.db.Begin()
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer tx.Rollback()
 
+	// Log the karma change first; the unique index rejects repeats
+	result, err := tx.Exec(`
+		INSERT INTO karma_log (user_id, given_by, reason, change, timestamp, channel, message_ts)
+		VALUES (?, ?, ?, 1, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		userID, givenBy, reason, time.Now(), channel, messageTS)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
 	// Update or insert karma
 	_, err = tx.Exec(`
 		INSERT INTO karma (user_id, username, score, updated_at)
@@ -205,19 +213,244 @@ func (d *Database) IncrementKarma(userID, username, givenBy, reason, channel str
 			updated_at = ?`,
 		userID, username, time.Now(), time.Now())
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	// Log the karma change
-	_, err = tx.Exec(`
-		INSERT INTO karma_log (user_id, given_by, reason, change, timestamp, channel)
-		VALUES (?, ?, ?, 1, ?, ?)`,
-		userID, givenBy, reason, time.Now(), channel)
+	return true, tx.Commit()
+}
+
+// ClaimEvent claims key for handling, reporting whether the caller got it.
+// Keys identify Slack deliveries, such as an event ID. A key that is done,
+// or being handled since staleBefore, isn't claimed; an older claim is
+// taken over, as whoever held it must have crashed.
+func (d *Database) ClaimEvent(key string, staleBefore time.Time) (bool, error) {
+	result, err := d.db.Exec(`INSERT INTO processed_events (event_key, status, processed_at) VALUES (?, 'handling', ?)
+		ON CONFLICT(event_key) DO UPDATE SET processed_at = excluded.processed_at
+		WHERE status = 'handling' AND processed_at < ?`,
+		key, time.Now().UTC(), staleBefore.UTC())
 	if err != nil {
-		return err
+		return false, err
 	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
 
-	return tx.Commit()
+// FinishEvent records a claimed key as handled
+func (d *Database) FinishEvent(key string) error {
+	_, err := d.db.Exec(`UPDATE processed_events SET status = 'done', processed_at = ? WHERE event_key = ?`,
+		time.Now().UTC(), key)
+	return err
+}
+
+// ReleaseEvent gives up the claim on a key that wasn't handled, so a
+// redelivery can claim it again
+func (d *Database) ReleaseEvent(key string) error {
+	_, err := d.db.Exec(`DELETE FROM processed_events WHERE event_key = ? AND status = 'handling'`, key)
+	return err
+}
+
+// PurgeProcessedEvents forgets events processed before cutoff
+func (d *Database) PurgeProcessedEvents(cutoff time.Time) (int64, error) {
+	result, err := d.db.Exec(`DELETE FROM processed_events WHERE processed_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// OAuth state operations
+
+// CreateOAuthState records a state issued to a user for connecting provider,
+// valid until expiresAt, and forgets states that have expired
+func (d *Database) CreateOAuthState(state, userID, provider string, expiresAt time.Time) error {
+	if _, err := d.db.Exec(`DELETE FROM oauth_states WHERE expires_at < ?`, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to purge expired OAuth states: %w", err)
+	}
+	_, err := d.db.Exec(`INSERT INTO oauth_states (state, user_id, provider, expires_at) VALUES (?, ?, ?, ?)`,
+		state, userID, provider, expiresAt.UTC())
+	return err
+}
+
+// ConsumeOAuthState deletes a state issued for provider and returns the user
+// it was issued to. It returns sql.ErrNoRows if the state was never issued,
+// has expired or was already used.
+func (d *Database) ConsumeOAuthState(state, provider string) (string, error) {
+	var userID string
+	err := d.db.QueryRow(`DELETE FROM oauth_states WHERE state = ? AND provider = ? AND expires_at >= ?
+		RETURNING user_id`, state, provider, time.Now().UTC()).Scan(&userID)
+	return userID, err
+}
+
+// Job run operations
+
+// StartJobRun records that a job is starting. For scheduled runs it claims
+// the slot, returning false without recording anything if the slot already
+// ran. Manual runs pass a zero scheduledFor and are always recorded.
+func (d *Database) StartJobRun(job, trigger string, scheduledFor time.Time) (int64, bool, error) {
+	var slot any
+	if !scheduledFor.IsZero() {
+		slot = scheduledFor.UTC()
+	}
+	result, err := d.db.Exec(`INSERT INTO job_runs (job, scheduled_for, trigger, started_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(job, scheduled_for) WHERE scheduled_for IS NOT NULL DO NOTHING`,
+		job, slot, trigger, time.Now().UTC())
+	if err != nil {
+		return 0, false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return 0, false, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// FinishJobRun records a run's outcome
+func (d *Database) FinishJobRun(id int64, outcome, errMsg string) error {
+	_, err := d.db.Exec(`UPDATE job_runs SET finished_at = ?, outcome = ?, error = ? WHERE id = ?`,
+		time.Now().UTC(), outcome, errMsg, id)
+	return err
+}
+
+// GetLatestJobRuns returns each job's most recent run, by job name
+func (d *Database) GetLatestJobRuns() (map[string]models.JobRun, error) {
+	rows, err := d.db.Query(`SELECT id, job, scheduled_for, trigger, started_at, finished_at, outcome, error
+		FROM job_runs
+		WHERE id IN (SELECT MAX(id) FROM job_runs GROUP BY job)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make(map[string]models.JobRun)
+	for rows.Next() {
+		var run models.JobRun
+		var scheduledFor, finishedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.Job, &scheduledFor, &run.Trigger, &run.StartedAt, &finishedAt, &run.Outcome, &run.Error); err != nil {
+			return nil, err
+		}
+		run.ScheduledFor = scheduledFor.Time
+		run.FinishedAt = finishedAt.Time
+		runs[run.Job] = run
+	}
+	return runs, rows.Err()
+}
+
+// PurgeJobRuns deletes runs started before cutoff
+func (d *Database) PurgeJobRuns(cutoff time.Time) (int64, error) {
+	result, err := d.db.Exec(`DELETE FROM job_runs WHERE started_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Lease operations
+
+// AcquireLease takes or renews the named lease for holder until ttl from now,
+// reporting whether holder has it. It fails to take a lease another holder
+// has renewed within its ttl. Instances sharing the database must have
+// roughly synchronized clocks.
+func (d *Database) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	result, err := d.db.ExecContext(ctx, `INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at < ?`,
+		name, holder, now.Add(ttl), now)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReleaseLease gives up the named lease if holder has it, so another
+// instance can take it without waiting for it to expire
+func (d *Database) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := d.db.ExecContext(ctx, `DELETE FROM leases WHERE name = ? AND holder = ?`, name, holder)
+	return err
+}
+
+// Outbound message operations
+
+// EnqueueOutboundMessage stores a message to post, due immediately, and
+// returns its ID
+func (d *Database) EnqueueOutboundMessage(channel, threadTS, text string) (int64, error) {
+	now := time.Now().UTC()
+	result, err := d.db.Exec(`INSERT INTO outbound_messages (channel, thread_ts, text, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		channel, threadTS, text, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetOutboundHeads returns the oldest waiting message of each channel,
+// soonest due first. Later messages wait behind them to keep channel order.
+func (d *Database) GetOutboundHeads() ([]models.OutboundMessage, error) {
+	rows, err := d.db.Query(`SELECT id, channel, thread_ts, text, attempts, next_attempt_at, last_error, created_at
+		FROM outbound_messages
+		WHERE id IN (SELECT MIN(id) FROM outbound_messages GROUP BY channel)
+		ORDER BY next_attempt_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.OutboundMessage
+	for rows.Next() {
+		var m models.OutboundMessage
+		if err := rows.Scan(&m.ID, &m.Channel, &m.ThreadTS, &m.Text, &m.Attempts, &m.NextAttemptAt, &m.LastError, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// ClaimOutboundMessage claims a due message for holder until the given
+// time, reporting whether it got it. A message already claimed by another
+// instance, posted since it was read, or rescheduled isn't claimed.
+func (d *Database) ClaimOutboundMessage(id int64, holder string, until time.Time) (bool, error) {
+	now := time.Now().UTC()
+	result, err := d.db.Exec(`UPDATE outbound_messages SET claimed_by = ?, claimed_until = ?
+		WHERE id = ? AND next_attempt_at <= ? AND (claimed_until IS NULL OR claimed_until < ? OR claimed_by = ?)`,
+		holder, until.UTC(), id, now, now, holder)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+// RescheduleOutboundMessage records a failed attempt and when to try again,
+// releasing the message's claim
+func (d *Database) RescheduleOutboundMessage(id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := d.db.Exec(`UPDATE outbound_messages SET attempts = ?, next_attempt_at = ?, last_error = ?, claimed_by = '', claimed_until = NULL WHERE id = ?`,
+		attempts, nextAttemptAt.UTC(), lastError, id)
+	return err
+}
+
+// DeleteOutboundMessage removes a message once it is posted or given up on
+func (d *Database) DeleteOutboundMessage(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM outbound_messages WHERE id = ?`, id)
+	return err
+}
+
+// CountOutboundMessages returns how many messages are waiting to be posted
+func (d *Database) CountOutboundMessages() (int, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM outbound_messages`).Scan(&count)
+	return count, err
 }
 
 func (d *Database) GetTopKarma(limit int) ([]models.Karma, error) {
@@ -260,8 +493,9 @@ func (d *Database) GetBirthday(userID string) (*models.Birthday, error) {
 	return &birthday, nil
 }
 
-func (d *Database) GetTodaysBirthdays() ([]models.Birthday, error) {
-	now := time.Now()
+// GetTodaysBirthdays returns the birthdays falling on today's date in now's
+// time zone
+func (d *Database) GetTodaysBirthdays(now time.Time) ([]models.Birthday, error) {
 	month, day := int(now.Month()), now.Day()
 
 	query := `SELECT id, user_id, username, month, day, year, timezone FROM birthdays WHERE month = ? AND day = ?`
@@ -303,8 +537,9 @@ func (d *Database) GetAnniversary(userID string) (*models.Anniversary, error) {
 	return &anniversary, nil
 }
 
-func (d *Database) GetTodaysAnniversaries() ([]models.Anniversary, error) {
-	now := time.Now()
+// GetTodaysAnniversaries returns the anniversaries falling on today's date in
+// now's time zone
+func (d *Database) GetTodaysAnniversaries(now time.Time) ([]models.Anniversary, error) {
 	month, day := int(now.Month()), now.Day()
 
 	query := `SELECT id, user_id, username, month, day, year, timezone FROM anniversaries WHERE month = ? AND day = ?`
@@ -381,26 +616,54 @@ func (d *Database) insertDefaultSassyResponses() error {
 
 // WHOOP Connection operations
 func (d *Database) UpsertWHOOPConnection(conn *models.WHOOPConnection) error {
-	query := `INSERT OR REPLACE INTO whoop_connections (user_id, whoop_user_id, access_token, refresh_token, expires_at, connected_at, active) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := d.db.Exec(query, conn.UserID, conn.WHOOPUserID, conn.AccessToken, conn.RefreshToken, conn.ExpiresAt, conn.ConnectedAt, conn.Active)
+	accessToken, err := d.encryptToken(conn.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	refreshToken, err := d.encryptToken(conn.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+
+	query := `INSERT OR REPLACE INTO whoop_connections (user_id, provider, whoop_user_id, access_token, refresh_token, expires_at, connected_at, active) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = d.db.Exec(query, conn.UserID, conn.ProviderName(), conn.WHOOPUserID, accessToken, refreshToken, conn.ExpiresAt, conn.ConnectedAt, conn.Active)
 	return err
 }
 
 func (d *Database) GetWHOOPConnection(userID string) (*models.WHOOPConnection, error) {
-	query := `SELECT id, user_id, whoop_user_id, access_token, refresh_token, expires_at, connected_at, active FROM whoop_connections WHERE user_id = ? AND active = 1`
+	query := `SELECT id, user_id, provider, whoop_user_id, access_token, refresh_token, expires_at, connected_at, active FROM whoop_connections WHERE user_id = ? AND active = 1`
 	row := d.db.QueryRow(query, userID)
 
 	var conn models.WHOOPConnection
-	err := row.Scan(&conn.ID, &conn.UserID, &conn.WHOOPUserID, &conn.AccessToken, &conn.RefreshToken, &conn.ExpiresAt, &conn.ConnectedAt, &conn.Active)
+	err := row.Scan(&conn.ID, &conn.UserID, &conn.Provider, &conn.WHOOPUserID, &conn.AccessToken, &conn.RefreshToken, &conn.ExpiresAt, &conn.ConnectedAt, &conn.Active)
 	if err != nil {
 		return nil, err
 	}
+	if err := d.decryptConnectionTokens(&conn); err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// GetWHOOPConnectionByWHOOPUserID looks up an active WHOOP connection by the WHOOP-side user ID
+func (d *Database) GetWHOOPConnectionByWHOOPUserID(whoopUserID string) (*models.WHOOPConnection, error) {
+	query := `SELECT id, user_id, provider, whoop_user_id, access_token, refresh_token, expires_at, connected_at, active FROM whoop_connections WHERE provider = 'whoop' AND whoop_user_id = ? AND active = 1`
+	row := d.db.QueryRow(query, whoopUserID)
+
+	var conn models.WHOOPConnection
+	err := row.Scan(&conn.ID, &conn.UserID, &conn.Provider, &conn.WHOOPUserID, &conn.AccessToken, &conn.RefreshToken, &conn.ExpiresAt, &conn.ConnectedAt, &conn.Active)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.decryptConnectionTokens(&conn); err != nil {
+		return nil, err
+	}
 	return &conn, nil
 }
 
 func (d *Database) GetAllActiveWHOOPConnections() ([]models.WHOOPConnection, error) {
-	query := `SELECT id, user_id, whoop_user_id, access_token, refresh_token, expires_at, connected_at, active FROM whoop_connections WHERE active = 1`
+	query := `SELECT id, user_id, provider, whoop_user_id, access_token, refresh_token, expires_at, connected_at, active FROM whoop_connections WHERE active = 1`
 	rows, err := d.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -410,10 +673,13 @@ func (d *Database) GetAllActiveWHOOPConnections() ([]models.WHOOPConnection, err
 	var connections []models.WHOOPConnection
 	for rows.Next() {
 		var conn models.WHOOPConnection
-		err := rows.Scan(&conn.ID, &conn.UserID, &conn.WHOOPUserID, &conn.AccessToken, &conn.RefreshToken, &conn.ExpiresAt, &conn.ConnectedAt, &conn.Active)
+		err := rows.Scan(&conn.ID, &conn.UserID, &conn.Provider, &conn.WHOOPUserID, &conn.AccessToken, &conn.RefreshToken, &conn.ExpiresAt, &conn.ConnectedAt, &conn.Active)
 		if err != nil {
 			return nil, err
 		}
+		if err := d.decryptConnectionTokens(&conn); err != nil {
+			return nil, err
+		}
 		connections = append(connections, conn)
 	}
 
@@ -426,20 +692,190 @@ func (d *Database) DeactivateWHOOPConnection(userID string) error {
 	return err
 }
 
+// DeleteWHOOPConnection removes a user's connection along with its tokens
+func (d *Database) DeleteWHOOPConnection(userID string) error {
+	_, err := d.db.Exec(`DELETE FROM whoop_connections WHERE user_id = ?`, userID)
+	return err
+}
+
+// DeleteWHOOPUserData removes everything stored about a user's device data:
+// their connection, daily records, alert rules, privacy setting and
+// challenge memberships
+func (d *Database) DeleteWHOOPUserData(userID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tables := []string{
+		"whoop_connections",
+		"whoop_recovery",
+		"whoop_sleep",
+		"whoop_strain",
+		"whoop_alert_rules",
+		"whoop_privacy",
+		"whoop_challenge_participants",
+	}
+	for _, table := range tables {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE user_id = ?`, userID); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// PurgeWHOOPDataBefore deletes daily records dated before cutoff, and
+// connections that were deactivated and only hold dead tokens. It returns
+// how many rows were removed.
+func (d *Database) PurgeWHOOPDataBefore(cutoff time.Time) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var purged int64
+	queries := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`DELETE FROM whoop_recovery WHERE date < ?`, []interface{}{cutoff.Format("2006-01-02")}},
+		{`DELETE FROM whoop_sleep WHERE date < ?`, []interface{}{cutoff.Format("2006-01-02")}},
+		{`DELETE FROM whoop_strain WHERE date < ?`, []interface{}{cutoff.Format("2006-01-02")}},
+		{`DELETE FROM whoop_connections WHERE active = 0`, nil},
+	}
+	for _, q := range queries {
+		result, err := tx.Exec(q.query, q.args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge expired WHOOP data: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		purged += n
+	}
+	return purged, tx.Commit()
+}
+
+// EncryptWHOOPTokens encrypts any plaintext tokens left over from before
+// encryption was enabled and re-encrypts tokens sealed with a retired key.
+// It returns the number of connections that were rewritten.
+func (d *Database) EncryptWHOOPTokens() (int, error) {
+	if d.tokenKeys == nil {
+		return 0, fmt.Errorf("token keyring not configured")
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, access_token, refresh_token FROM whoop_connections`)
+	if err != nil {
+		return 0, err
+	}
+
+	type storedTokens struct {
+		id           int
+		accessToken  string
+		refreshToken string
+	}
+	var stale []storedTokens
+	for rows.Next() {
+		var tokens storedTokens
+		if err := rows.Scan(&tokens.id, &tokens.accessToken, &tokens.refreshToken); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !d.tokenKeys.IsCurrent(tokens.accessToken) || !d.tokenKeys.IsCurrent(tokens.refreshToken) {
+			stale = append(stale, tokens)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, tokens := range stale {
+		accessToken, err := d.reencryptToken(tokens.accessToken)
+		if err != nil {
+			return 0, fmt.Errorf("connection %d: access token: %w", tokens.id, err)
+		}
+		refreshToken, err := d.reencryptToken(tokens.refreshToken)
+		if err != nil {
+			return 0, fmt.Errorf("connection %d: refresh token: %w", tokens.id, err)
+		}
+
+		_, err = tx.Exec(`UPDATE whoop_connections SET access_token = ?, refresh_token = ? WHERE id = ?`,
+			accessToken, refreshToken, tokens.id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(stale), tx.Commit()
+}
+
+// encryptToken seals a token for storage
+func (d *Database) encryptToken(token string) (string, error) {
+	if d.tokenKeys == nil {
+		return "", fmt.Errorf("token keyring not configured")
+	}
+	return d.tokenKeys.Encrypt(token)
+}
+
+// reencryptToken brings a stored token up to the active key, encrypting
+// legacy plaintext values along the way
+func (d *Database) reencryptToken(stored string) (string, error) {
+	if d.tokenKeys.IsCurrent(stored) {
+		return stored, nil
+	}
+
+	plaintext := stored
+	if secrets.IsEncrypted(stored) {
+		var err error
+		plaintext, err = d.tokenKeys.Decrypt(stored)
+		if err != nil {
+			return "", err
+		}
+	}
+	return d.tokenKeys.Encrypt(plaintext)
+}
+
+// decryptConnectionTokens replaces the sealed tokens on conn with their plaintext
+func (d *Database) decryptConnectionTokens(conn *models.WHOOPConnection) error {
+	if d.tokenKeys == nil {
+		return fmt.Errorf("token keyring not configured")
+	}
+
+	accessToken, err := d.tokenKeys.Decrypt(conn.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt access token for user %s: %w", conn.UserID, err)
+	}
+	refreshToken, err := d.tokenKeys.Decrypt(conn.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt refresh token for user %s: %w", conn.UserID, err)
+	}
+
+	conn.AccessToken = accessToken
+	conn.RefreshToken = refreshToken
+	return nil
+}
+
 // WHOOP Recovery operations
 func (d *Database) UpsertWHOOPRecovery(recovery *models.WHOOPRecovery) error {
-	query := `INSERT OR REPLACE INTO whoop_recovery (user_id, whoop_user_id, date, score, hrv, rhr, created_at) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := d.db.Exec(query, recovery.UserID, recovery.WHOOPUserID, recovery.Date, recovery.Score, recovery.HRV, recovery.RHR, recovery.CreatedAt)
+	query := `INSERT OR REPLACE INTO whoop_recovery (user_id, whoop_user_id, date, score_state, calibrating, score, hrv, rhr, created_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := d.db.Exec(query, recovery.UserID, recovery.WHOOPUserID, recovery.Date.Format("2006-01-02"), recovery.ScoreState, recovery.Calibrating, recovery.Score, recovery.HRV, recovery.RHR, recovery.CreatedAt)
 	return err
 }
 
 func (d *Database) GetLatestWHOOPRecovery(userID string) (*models.WHOOPRecovery, error) {
-	query := `SELECT id, user_id, whoop_user_id, date, score, hrv, rhr, created_at FROM whoop_recovery WHERE user_id = ? ORDER BY date DESC LIMIT 1`
+	query := `SELECT id, user_id, whoop_user_id, date, score_state, calibrating, score, hrv, rhr, created_at FROM whoop_recovery WHERE user_id = ? ORDER BY date DESC LIMIT 1`
 	row := d.db.QueryRow(query, userID)
 
 	var recovery models.WHOOPRecovery
-	err := row.Scan(&recovery.ID, &recovery.UserID, &recovery.WHOOPUserID, &recovery.Date, &recovery.Score, &recovery.HRV, &recovery.RHR, &recovery.CreatedAt)
+	err := row.Scan(&recovery.ID, &recovery.UserID, &recovery.WHOOPUserID, &recovery.Date, &recovery.ScoreState, &recovery.Calibrating, &recovery.Score, &recovery.HRV, &recovery.RHR, &recovery.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -448,11 +884,11 @@ func (d *Database) GetLatestWHOOPRecovery(userID string) (*models.WHOOPRecovery,
 
 func (d *Database) GetWHOOPRecoveryForDate(userID string, date time.Time) (*models.WHOOPRecovery, error) {
 	dateStr := date.Format("2006-01-02")
-	query := `SELECT id, user_id, whoop_user_id, date, score, hrv, rhr, created_at FROM whoop_recovery WHERE user_id = ? AND date = ?`
+	query := `SELECT id, user_id, whoop_user_id, date, score_state, calibrating, score, hrv, rhr, created_at FROM whoop_recovery WHERE user_id = ? AND date = ?`
 	row := d.db.QueryRow(query, userID, dateStr)
 
 	var recovery models.WHOOPRecovery
-	err := row.Scan(&recovery.ID, &recovery.UserID, &recovery.WHOOPUserID, &recovery.Date, &recovery.Score, &recovery.HRV, &recovery.RHR, &recovery.CreatedAt)
+	err := row.Scan(&recovery.ID, &recovery.UserID, &recovery.WHOOPUserID, &recovery.Date, &recovery.ScoreState, &recovery.Calibrating, &recovery.Score, &recovery.HRV, &recovery.RHR, &recovery.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -461,18 +897,18 @@ func (d *Database) GetWHOOPRecoveryForDate(userID string, date time.Time) (*mode
 
 // WHOOP Sleep operations
 func (d *Database) UpsertWHOOPSleep(sleep *models.WHOOPSleep) error {
-	query := `INSERT OR REPLACE INTO whoop_sleep (user_id, whoop_user_id, date, duration_ms, efficiency, score, stages_deep_ms, stages_rem_ms, stages_light_ms, stages_wake_ms, created_at) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := d.db.Exec(query, sleep.UserID, sleep.WHOOPUserID, sleep.Date, sleep.DurationMS, sleep.Efficiency, sleep.Score, sleep.StagesDeepMS, sleep.StagesREMS, sleep.StagesLightMS, sleep.StagesWakeMS, sleep.CreatedAt)
+	query := `INSERT OR REPLACE INTO whoop_sleep (user_id, whoop_user_id, date, score_state, duration_ms, efficiency, score, stages_deep_ms, stages_rem_ms, stages_light_ms, stages_wake_ms, created_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := d.db.Exec(query, sleep.UserID, sleep.WHOOPUserID, sleep.Date.Format("2006-01-02"), sleep.ScoreState, sleep.DurationMS, sleep.Efficiency, sleep.Score, sleep.StagesDeepMS, sleep.StagesREMS, sleep.StagesLightMS, sleep.StagesWakeMS, sleep.CreatedAt)
 	return err
 }
 
 func (d *Database) GetLatestWHOOPSleep(userID string) (*models.WHOOPSleep, error) {
-	query := `SELECT id, user_id, whoop_user_id, date, duration_ms, efficiency, score, stages_deep_ms, stages_rem_ms, stages_light_ms, stages_wake_ms, created_at FROM whoop_sleep WHERE user_id = ? ORDER BY date DESC LIMIT 1`
+	query := `SELECT id, user_id, whoop_user_id, date, score_state, duration_ms, efficiency, score, stages_deep_ms, stages_rem_ms, stages_light_ms, stages_wake_ms, created_at FROM whoop_sleep WHERE user_id = ? ORDER BY date DESC LIMIT 1`
 	row := d.db.QueryRow(query, userID)
 
 	var sleep models.WHOOPSleep
-	err := row.Scan(&sleep.ID, &sleep.UserID, &sleep.WHOOPUserID, &sleep.Date, &sleep.DurationMS, &sleep.Efficiency, &sleep.Score, &sleep.StagesDeepMS, &sleep.StagesREMS, &sleep.StagesLightMS, &sleep.StagesWakeMS, &sleep.CreatedAt)
+	err := row.Scan(&sleep.ID, &sleep.UserID, &sleep.WHOOPUserID, &sleep.Date, &sleep.ScoreState, &sleep.DurationMS, &sleep.Efficiency, &sleep.Score, &sleep.StagesDeepMS, &sleep.StagesREMS, &sleep.StagesLightMS, &sleep.StagesWakeMS, &sleep.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -481,11 +917,11 @@ func (d *Database) GetLatestWHOOPSleep(userID string) (*models.WHOOPSleep, error
 
 func (d *Database) GetWHOOPSleepForDate(userID string, date time.Time) (*models.WHOOPSleep, error) {
 	dateStr := date.Format("2006-01-02")
-	query := `SELECT id, user_id, whoop_user_id, date, duration_ms, efficiency, score, stages_deep_ms, stages_rem_ms, stages_light_ms, stages_wake_ms, created_at FROM whoop_sleep WHERE user_id = ? AND date = ?`
+	query := `SELECT id, user_id, whoop_user_id, date, score_state, duration_ms, efficiency, score, stages_deep_ms, stages_rem_ms, stages_light_ms, stages_wake_ms, created_at FROM whoop_sleep WHERE user_id = ? AND date = ?`
 	row := d.db.QueryRow(query, userID, dateStr)
 
 	var sleep models.WHOOPSleep
-	err := row.Scan(&sleep.ID, &sleep.UserID, &sleep.WHOOPUserID, &sleep.Date, &sleep.DurationMS, &sleep.Efficiency, &sleep.Score, &sleep.StagesDeepMS, &sleep.StagesREMS, &sleep.StagesLightMS, &sleep.StagesWakeMS, &sleep.CreatedAt)
+	err := row.Scan(&sleep.ID, &sleep.UserID, &sleep.WHOOPUserID, &sleep.Date, &sleep.ScoreState, &sleep.DurationMS, &sleep.Efficiency, &sleep.Score, &sleep.StagesDeepMS, &sleep.StagesREMS, &sleep.StagesLightMS, &sleep.StagesWakeMS, &sleep.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -494,18 +930,18 @@ func (d *Database) GetWHOOPSleepForDate(userID string, date time.Time) (*models.
 
 // WHOOP Strain operations
 func (d *Database) UpsertWHOOPStrain(strain *models.WHOOPStrain) error {
-	query := `INSERT OR REPLACE INTO whoop_strain (user_id, whoop_user_id, date, score, created_at) 
-			  VALUES (?, ?, ?, ?, ?)`
-	_, err := d.db.Exec(query, strain.UserID, strain.WHOOPUserID, strain.Date, strain.Score, strain.CreatedAt)
+	query := `INSERT OR REPLACE INTO whoop_strain (user_id, whoop_user_id, date, score_state, score, created_at) 
+			  VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := d.db.Exec(query, strain.UserID, strain.WHOOPUserID, strain.Date.Format("2006-01-02"), strain.ScoreState, strain.Score, strain.CreatedAt)
 	return err
 }
 
 func (d *Database) GetLatestWHOOPStrain(userID string) (*models.WHOOPStrain, error) {
-	query := `SELECT id, user_id, whoop_user_id, date, score, created_at FROM whoop_strain WHERE user_id = ? ORDER BY date DESC LIMIT 1`
+	query := `SELECT id, user_id, whoop_user_id, date, score_state, score, created_at FROM whoop_strain WHERE user_id = ? ORDER BY date DESC LIMIT 1`
 	row := d.db.QueryRow(query, userID)
 
 	var strain models.WHOOPStrain
-	err := row.Scan(&strain.ID, &strain.UserID, &strain.WHOOPUserID, &strain.Date, &strain.Score, &strain.CreatedAt)
+	err := row.Scan(&strain.ID, &strain.UserID, &strain.WHOOPUserID, &strain.Date, &strain.ScoreState, &strain.Score, &strain.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -514,27 +950,55 @@ func (d *Database) GetLatestWHOOPStrain(userID string) (*models.WHOOPStrain, err
 
 func (d *Database) GetWHOOPStrainForDate(userID string, date time.Time) (*models.WHOOPStrain, error) {
 	dateStr := date.Format("2006-01-02")
-	query := `SELECT id, user_id, whoop_user_id, date, score, created_at FROM whoop_strain WHERE user_id = ? AND date = ?`
+	query := `SELECT id, user_id, whoop_user_id, date, score_state, score, created_at FROM whoop_strain WHERE user_id = ? AND date = ?`
 	row := d.db.QueryRow(query, userID, dateStr)
 
 	var strain models.WHOOPStrain
-	err := row.Scan(&strain.ID, &strain.UserID, &strain.WHOOPUserID, &strain.Date, &strain.Score, &strain.CreatedAt)
+	err := row.Scan(&strain.ID, &strain.UserID, &strain.WHOOPUserID, &strain.Date, &strain.ScoreState, &strain.Score, &strain.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &strain, nil
 }
 
-// Get all team members' latest data for morning standup
-func (d *Database) GetTeamWHOOPDataForStandup() ([]map[string]interface{}, error) {
+// GetOldestPendingWHOOPDate returns the earliest date on or after since that
+// has a recovery, sleep or strain still waiting for WHOOP to score it. The
+// zero time is returned when nothing is pending.
+func (d *Database) GetOldestPendingWHOOPDate(userID string, since time.Time) (time.Time, error) {
+	query := `SELECT MIN(date) FROM (
+			SELECT date FROM whoop_recovery WHERE user_id = ? AND score_state = ? AND date >= ?
+			UNION ALL
+			SELECT date FROM whoop_sleep WHERE user_id = ? AND score_state = ? AND date >= ?
+			UNION ALL
+			SELECT date FROM whoop_strain WHERE user_id = ? AND score_state = ? AND date >= ?
+		)`
+	sinceStr := since.Format("2006-01-02")
+	var oldest sql.NullString
+	err := d.db.QueryRow(query,
+		userID, models.ScoreStatePending, sinceStr,
+		userID, models.ScoreStatePending, sinceStr,
+		userID, models.ScoreStatePending, sinceStr,
+	).Scan(&oldest)
+	if err != nil || !oldest.Valid {
+		return time.Time{}, err
+	}
+	return time.Parse("2006-01-02", oldest.String)
+}
+
+// GetTeamWHOOPDataForStandup returns every actively connected user who hasn't
+// excluded themselves, with their privacy level and latest recovery, sleep
+// and strain
+func (d *Database) GetTeamWHOOPDataForStandup() ([]models.StandupEntry, error) {
 	query := `
 		SELECT 
-			u.id, u.username, u.real_name,
-			wr.score as recovery_score, wr.hrv, wr.rhr, wr.date as recovery_date,
-			ws.score as sleep_score, ws.duration_ms, ws.efficiency, ws.date as sleep_date,
-			wst.score as strain_score, wst.date as strain_date
+			u.id, u.username, u.real_name, COALESCE(wp.level, ?),
+			wr.id, wr.whoop_user_id, wr.date, wr.score_state, wr.calibrating, wr.score, wr.hrv, wr.rhr, wr.created_at,
+			ws.id, ws.whoop_user_id, ws.date, ws.score_state, ws.duration_ms, ws.efficiency, ws.score,
+			ws.stages_deep_ms, ws.stages_rem_ms, ws.stages_light_ms, ws.stages_wake_ms, ws.created_at,
+			wst.id, wst.whoop_user_id, wst.date, wst.score_state, wst.score, wst.created_at
 		FROM users u
 		INNER JOIN whoop_connections wc ON u.id = wc.user_id AND wc.active = 1
+		LEFT JOIN whoop_privacy wp ON u.id = wp.user_id
 		LEFT JOIN whoop_recovery wr ON u.id = wr.user_id AND wr.date = (
 			SELECT MAX(date) FROM whoop_recovery WHERE user_id = u.id
 		)
@@ -543,56 +1007,388 @@ func (d *Database) GetTeamWHOOPDataForStandup() ([]map[string]interface{}, error
 		)
 		LEFT JOIN whoop_strain wst ON u.id = wst.user_id AND wst.date = (
 			SELECT MAX(date) FROM whoop_strain WHERE user_id = u.id
-		)`
+		)
+		WHERE COALESCE(wp.level, ?) != ?`
 
-	rows, err := d.db.Query(query)
+	rows, err := d.db.Query(query, models.PrivacyFull, models.PrivacyFull, models.PrivacyExcluded)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	var entries []models.StandupEntry
 	for rows.Next() {
-		var userID, username, realName string
-		var recoveryScore, hrv, rhr sql.NullInt64
-		var recoveryDate, sleepDate, strainDate sql.NullString
-		var sleepScore sql.NullInt64
-		var durationMS sql.NullInt64
-		var efficiency, strainScore sql.NullFloat64
-
-		err := rows.Scan(&userID, &username, &realName, &recoveryScore, &hrv, &rhr, &recoveryDate,
-			&sleepScore, &durationMS, &efficiency, &sleepDate, &strainScore, &strainDate)
+		var entry models.StandupEntry
+		var (
+			recoveryID, recoveryScore, rhr                                  sql.NullInt64
+			recoveryWHOOPUserID, recoveryState                              sql.NullString
+			recoveryDate, recoveryCreatedAt                                 sql.NullTime
+			calibrating                                                     sql.NullBool
+			hrv                                                             sql.NullFloat64
+			sleepID, sleepScore, durationMS, deepMS, remMS, lightMS, wakeMS sql.NullInt64
+			sleepWHOOPUserID, sleepState                                    sql.NullString
+			sleepDate, sleepCreatedAt                                       sql.NullTime
+			efficiency                                                      sql.NullFloat64
+			strainID                                                        sql.NullInt64
+			strainWHOOPUserID, strainState                                  sql.NullString
+			strainDate, strainCreatedAt                                     sql.NullTime
+			strainScore                                                     sql.NullFloat64
+		)
+
+		err := rows.Scan(&entry.UserID, &entry.Username, &entry.RealName, &entry.Privacy,
+			&recoveryID, &recoveryWHOOPUserID, &recoveryDate, &recoveryState, &calibrating, &recoveryScore, &hrv, &rhr, &recoveryCreatedAt,
+			&sleepID, &sleepWHOOPUserID, &sleepDate, &sleepState, &durationMS, &efficiency, &sleepScore,
+			&deepMS, &remMS, &lightMS, &wakeMS, &sleepCreatedAt,
+			&strainID, &strainWHOOPUserID, &strainDate, &strainState, &strainScore, &strainCreatedAt)
 		if err != nil {
 			return nil, err
 		}
 
-		result := map[string]interface{}{
-			"user_id":   userID,
-			"username":  username,
-			"real_name": realName,
+		if recoveryID.Valid {
+			entry.Recovery = &models.WHOOPRecovery{
+				ID:          int(recoveryID.Int64),
+				UserID:      entry.UserID,
+				WHOOPUserID: recoveryWHOOPUserID.String,
+				Date:        recoveryDate.Time,
+				ScoreState:  recoveryState.String,
+				Calibrating: calibrating.Bool,
+				Score:       int(recoveryScore.Int64),
+				HRV:         hrv.Float64,
+				RHR:         int(rhr.Int64),
+				CreatedAt:   recoveryCreatedAt.Time,
+			}
+		}
+
+		if sleepID.Valid {
+			entry.Sleep = &models.WHOOPSleep{
+				ID:            int(sleepID.Int64),
+				UserID:        entry.UserID,
+				WHOOPUserID:   sleepWHOOPUserID.String,
+				Date:          sleepDate.Time,
+				ScoreState:    sleepState.String,
+				DurationMS:    int(durationMS.Int64),
+				Efficiency:    efficiency.Float64,
+				Score:         int(sleepScore.Int64),
+				StagesDeepMS:  int(deepMS.Int64),
+				StagesREMS:    int(remMS.Int64),
+				StagesLightMS: int(lightMS.Int64),
+				StagesWakeMS:  int(wakeMS.Int64),
+				CreatedAt:     sleepCreatedAt.Time,
+			}
+		}
+
+		if strainID.Valid {
+			entry.Strain = &models.WHOOPStrain{
+				ID:          int(strainID.Int64),
+				UserID:      entry.UserID,
+				WHOOPUserID: strainWHOOPUserID.String,
+				Date:        strainDate.Time,
+				ScoreState:  strainState.String,
+				Score:       strainScore.Float64,
+				CreatedAt:   strainCreatedAt.Time,
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetUserWHOOPSnapshot returns a user's latest recovery, sleep and strain,
+// leaving out any the user has no records for
+func (d *Database) GetUserWHOOPSnapshot(userID string) (*models.UserSnapshot, error) {
+	var snapshot models.UserSnapshot
+
+	recovery, err := d.GetLatestWHOOPRecovery(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get latest recovery: %w", err)
+	}
+	snapshot.Recovery = recovery
+
+	sleep, err := d.GetLatestWHOOPSleep(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get latest sleep: %w", err)
+	}
+	snapshot.Sleep = sleep
+
+	strain, err := d.GetLatestWHOOPStrain(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get latest strain: %w", err)
+	}
+	snapshot.Strain = strain
+
+	return &snapshot, nil
+}
+
+// GetWHOOPAverages returns a user's WHOOP averages for records dated on or after since
+func (d *Database) GetWHOOPAverages(userID string, since time.Time) (*models.WHOOPAverages, error) {
+	return d.whoopAverages("user_id = ?", since, userID)
+}
+
+// GetTeamWHOOPAverages returns WHOOP averages across every actively connected
+// user sharing full stats, for records dated on or after since. Users with
+// any other privacy level stay out, so their numbers can't be worked out from
+// the averages. Callers must check the Users counts before showing them.
+func (d *Database) GetTeamWHOOPAverages(since time.Time) (*models.WHOOPAverages, error) {
+	return d.whoopAverages(`user_id IN (SELECT user_id FROM whoop_connections WHERE active = 1)
+		AND user_id NOT IN (SELECT user_id FROM whoop_privacy WHERE level != ?)`, since, models.PrivacyFull)
+}
+
+// whoopAverages averages scored recovery, sleep and strain rows matching
+// userClause, whose placeholders are filled from userArgs, from since onwards
+func (d *Database) whoopAverages(userClause string, since time.Time, userArgs ...interface{}) (*models.WHOOPAverages, error) {
+	args := append(userArgs, since.Format("2006-01-02"), models.ScoreStateScored)
+	var averages models.WHOOPAverages
+
+	var recovery, hrv, rhr sql.NullFloat64
+	query := `SELECT AVG(score), AVG(hrv), AVG(rhr), COUNT(*), COUNT(DISTINCT user_id) FROM whoop_recovery
+			  WHERE ` + userClause + ` AND date >= ? AND score_state = ?`
+	err := d.db.QueryRow(query, args...).Scan(&recovery, &hrv, &rhr, &averages.RecoveryDays, &averages.RecoveryUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to average recovery: %w", err)
+	}
+	averages.Recovery, averages.HRV, averages.RHR = recovery.Float64, hrv.Float64, rhr.Float64
+
+	var durationMS sql.NullFloat64
+	query = `SELECT AVG(duration_ms), COUNT(*), COUNT(DISTINCT user_id) FROM whoop_sleep
+			 WHERE ` + userClause + ` AND date >= ? AND score_state = ?`
+	err = d.db.QueryRow(query, args...).Scan(&durationMS, &averages.SleepDays, &averages.SleepUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to average sleep: %w", err)
+	}
+	averages.SleepHours = durationMS.Float64 / (1000 * 60 * 60)
+
+	var strain sql.NullFloat64
+	query = `SELECT AVG(score), COUNT(*), COUNT(DISTINCT user_id) FROM whoop_strain
+			 WHERE ` + userClause + ` AND date >= ? AND score_state = ?`
+	err = d.db.QueryRow(query, args...).Scan(&strain, &averages.StrainDays, &averages.StrainUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to average strain: %w", err)
+	}
+	averages.Strain = strain.Float64
+
+	return &averages, nil
+}
+
+// GetRecentWHOOPRecoveries returns a user's latest scored recoveries, newest first
+func (d *Database) GetRecentWHOOPRecoveries(userID string, limit int) ([]models.WHOOPRecovery, error) {
+	query := `SELECT id, user_id, whoop_user_id, date, score_state, calibrating, score, hrv, rhr, created_at FROM whoop_recovery 
+			  WHERE user_id = ? AND score_state = ? ORDER BY date DESC LIMIT ?`
+	rows, err := d.db.Query(query, userID, models.ScoreStateScored, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recoveries []models.WHOOPRecovery
+	for rows.Next() {
+		var recovery models.WHOOPRecovery
+		err := rows.Scan(&recovery.ID, &recovery.UserID, &recovery.WHOOPUserID, &recovery.Date, &recovery.ScoreState, &recovery.Calibrating, &recovery.Score, &recovery.HRV, &recovery.RHR, &recovery.CreatedAt)
+		if err != nil {
+			return nil, err
 		}
+		recoveries = append(recoveries, recovery)
+	}
+	return recoveries, rows.Err()
+}
 
-		if recoveryScore.Valid {
-			result["recovery_score"] = recoveryScore.Int64
-			result["hrv"] = hrv.Int64
-			result["rhr"] = rhr.Int64
-			result["recovery_date"] = recoveryDate.String
+// GetRecentWHOOPSleeps returns a user's latest scored sleeps, newest first
+func (d *Database) GetRecentWHOOPSleeps(userID string, limit int) ([]models.WHOOPSleep, error) {
+	query := `SELECT id, user_id, whoop_user_id, date, score_state, duration_ms, efficiency, score, stages_deep_ms, stages_rem_ms, stages_light_ms, stages_wake_ms, created_at FROM whoop_sleep 
+			  WHERE user_id = ? AND score_state = ? ORDER BY date DESC LIMIT ?`
+	rows, err := d.db.Query(query, userID, models.ScoreStateScored, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sleeps []models.WHOOPSleep
+	for rows.Next() {
+		var sleep models.WHOOPSleep
+		err := rows.Scan(&sleep.ID, &sleep.UserID, &sleep.WHOOPUserID, &sleep.Date, &sleep.ScoreState, &sleep.DurationMS, &sleep.Efficiency, &sleep.Score, &sleep.StagesDeepMS, &sleep.StagesREMS, &sleep.StagesLightMS, &sleep.StagesWakeMS, &sleep.CreatedAt)
+		if err != nil {
+			return nil, err
 		}
+		sleeps = append(sleeps, sleep)
+	}
+	return sleeps, rows.Err()
+}
 
-		if sleepScore.Valid {
-			result["sleep_score"] = sleepScore.Int64
-			result["duration_ms"] = durationMS.Int64
-			result["efficiency"] = efficiency.Float64
-			result["sleep_date"] = sleepDate.String
+// WHOOP alert rule operations
+func (d *Database) UpsertWHOOPAlertRule(rule *models.WHOOPAlertRule) error {
+	query := `INSERT INTO whoop_alert_rules (user_id, rule, threshold) VALUES (?, ?, ?)
+			  ON CONFLICT(user_id, rule) DO UPDATE SET threshold = excluded.threshold`
+	_, err := d.db.Exec(query, rule.UserID, rule.Rule, rule.Threshold)
+	return err
+}
+
+func (d *Database) GetWHOOPAlertRules(userID string) ([]models.WHOOPAlertRule, error) {
+	query := `SELECT id, user_id, rule, threshold, last_alerted, created_at FROM whoop_alert_rules WHERE user_id = ? ORDER BY rule`
+	rows, err := d.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.WHOOPAlertRule
+	for rows.Next() {
+		var rule models.WHOOPAlertRule
+		var lastAlerted sql.NullTime
+		if err := rows.Scan(&rule.ID, &rule.UserID, &rule.Rule, &rule.Threshold, &lastAlerted, &rule.CreatedAt); err != nil {
+			return nil, err
 		}
+		rule.LastAlerted = lastAlerted.Time
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteWHOOPAlertRule removes one of a user's alert rules, or all of them
+// when rule is empty
+func (d *Database) DeleteWHOOPAlertRule(userID, rule string) error {
+	if rule == "" {
+		_, err := d.db.Exec(`DELETE FROM whoop_alert_rules WHERE user_id = ?`, userID)
+		return err
+	}
+	_, err := d.db.Exec(`DELETE FROM whoop_alert_rules WHERE user_id = ? AND rule = ?`, userID, rule)
+	return err
+}
+
+// MarkWHOOPAlertSent records the date of the record an alert was sent for
+func (d *Database) MarkWHOOPAlertSent(userID, rule string, date time.Time) error {
+	_, err := d.db.Exec(`UPDATE whoop_alert_rules SET last_alerted = ? WHERE user_id = ? AND rule = ?`,
+		date.Format("2006-01-02"), userID, rule)
+	return err
+}
+
+// WHOOP privacy operations
+func (d *Database) SetWHOOPPrivacy(privacy *models.WHOOPPrivacy) error {
+	query := `INSERT OR REPLACE INTO whoop_privacy (user_id, level, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)`
+	_, err := d.db.Exec(query, privacy.UserID, privacy.Level)
+	return err
+}
+
+func (d *Database) GetWHOOPPrivacy(userID string) (*models.WHOOPPrivacy, error) {
+	query := `SELECT user_id, level, updated_at FROM whoop_privacy WHERE user_id = ?`
+	row := d.db.QueryRow(query, userID)
+
+	var privacy models.WHOOPPrivacy
+	err := row.Scan(&privacy.UserID, &privacy.Level, &privacy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &privacy, nil
+}
+
+// WHOOP challenge operations
+func (d *Database) CreateWHOOPChallenge(challenge *models.WHOOPChallenge) error {
+	query := `INSERT INTO whoop_challenges (metric, goal, created_by, start_date, end_date, status) VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := d.db.Exec(query, challenge.Metric, challenge.Goal, challenge.CreatedBy,
+		challenge.StartDate.Format("2006-01-02"), challenge.EndDate.Format("2006-01-02"), challenge.Status)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	challenge.ID = int(id)
+	return nil
+}
 
-		if strainScore.Valid {
-			result["strain_score"] = strainScore.Float64
-			result["strain_date"] = strainDate.String
+func (d *Database) GetWHOOPChallenge(id int) (*models.WHOOPChallenge, error) {
+	query := `SELECT id, metric, goal, created_by, start_date, end_date, status, created_at FROM whoop_challenges WHERE id = ?`
+	row := d.db.QueryRow(query, id)
+
+	var challenge models.WHOOPChallenge
+	err := row.Scan(&challenge.ID, &challenge.Metric, &challenge.Goal, &challenge.CreatedBy, &challenge.StartDate, &challenge.EndDate, &challenge.Status, &challenge.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (d *Database) GetActiveWHOOPChallenges() ([]models.WHOOPChallenge, error) {
+	query := `SELECT id, metric, goal, created_by, start_date, end_date, status, created_at FROM whoop_challenges WHERE status = ? ORDER BY end_date, id`
+	rows, err := d.db.Query(query, models.ChallengeActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var challenges []models.WHOOPChallenge
+	for rows.Next() {
+		var challenge models.WHOOPChallenge
+		err := rows.Scan(&challenge.ID, &challenge.Metric, &challenge.Goal, &challenge.CreatedBy, &challenge.StartDate, &challenge.EndDate, &challenge.Status, &challenge.CreatedAt)
+		if err != nil {
+			return nil, err
 		}
+		challenges = append(challenges, challenge)
+	}
+	return challenges, rows.Err()
+}
+
+func (d *Database) SetWHOOPChallengeStatus(id int, status string) error {
+	_, err := d.db.Exec(`UPDATE whoop_challenges SET status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+func (d *Database) JoinWHOOPChallenge(challengeID int, userID string) error {
+	_, err := d.db.Exec(`INSERT OR IGNORE INTO whoop_challenge_participants (challenge_id, user_id) VALUES (?, ?)`, challengeID, userID)
+	return err
+}
+
+// LeaveWHOOPChallenge removes a participant, reporting whether they had joined
+func (d *Database) LeaveWHOOPChallenge(challengeID int, userID string) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM whoop_challenge_participants WHERE challenge_id = ? AND user_id = ?`, challengeID, userID)
+	if err != nil {
+		return false, err
+	}
+	removed, err := result.RowsAffected()
+	return removed > 0, err
+}
+
+// GetWHOOPChallengeStandings computes every participant's progress from the
+// synced WHOOP tables, best first
+func (d *Database) GetWHOOPChallengeStandings(challenge *models.WHOOPChallenge, greenThreshold int) ([]models.ChallengeStanding, error) {
+	var table, score string
+	var args []interface{}
+	switch challenge.Metric {
+	case models.ChallengeSleep:
+		table, score = "whoop_sleep", "COALESCE(AVG(w.duration_ms), 0) / 3600000.0"
+	case models.ChallengeGreen:
+		table, score = "whoop_recovery", "COALESCE(SUM(w.score >= ?), 0)"
+		args = append(args, greenThreshold)
+	case models.ChallengeStrain:
+		table, score = "whoop_strain", "COALESCE(SUM(w.score >= ?), 0)"
+		args = append(args, challenge.Goal)
+	default:
+		return nil, fmt.Errorf("unknown challenge metric %q", challenge.Metric)
+	}
+
+	query := `SELECT p.user_id, COALESCE(u.username, p.user_id), COALESCE(u.real_name, ''), ` + score + `, COUNT(w.id)
+		FROM whoop_challenge_participants p
+		LEFT JOIN users u ON u.id = p.user_id
+		LEFT JOIN ` + table + ` w ON w.user_id = p.user_id AND w.date BETWEEN ? AND ? AND w.score_state = ?
+		WHERE p.challenge_id = ?
+		GROUP BY p.user_id
+		ORDER BY 4 DESC, 5 DESC`
+	args = append(args, challenge.StartDate.Format("2006-01-02"), challenge.EndDate.Format("2006-01-02"), models.ScoreStateScored, challenge.ID)
 
-		results = append(results, result)
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return results, nil
+	var standings []models.ChallengeStanding
+	for rows.Next() {
+		var standing models.ChallengeStanding
+		if err := rows.Scan(&standing.UserID, &standing.Username, &standing.RealName, &standing.Score, &standing.Days); err != nil {
+			return nil, err
+		}
+		standings = append(standings, standing)
+	}
+	return standings, rows.Err()
 }
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClaimEvent(t *testing.T) {
+	d, err := New(filepath.Join(t.TempDir(), "fambot.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer d.Close()
+
+	claim := func(key string, staleBefore time.Time, want bool) {
+		t.Helper()
+		claimed, err := d.ClaimEvent(key, staleBefore)
+		if err != nil {
+			t.Fatalf("ClaimEvent(%s): %v", key, err)
+		}
+		if claimed != want {
+			t.Fatalf("ClaimEvent(%s) = %v, want %v", key, claimed, want)
+		}
+	}
+	longAgo := time.Now().Add(-time.Hour)
+
+	// A delivery in flight can't be claimed again
+	claim("event:1", longAgo, true)
+	claim("event:1", longAgo, false)
+
+	// Releasing it lets a redelivery handle it
+	if err := d.ReleaseEvent("event:1"); err != nil {
+		t.Fatalf("ReleaseEvent: %v", err)
+	}
+	claim("event:1", longAgo, true)
+
+	// Once done it stays done, however old
+	if err := d.FinishEvent("event:1"); err != nil {
+		t.Fatalf("FinishEvent: %v", err)
+	}
+	if err := d.ReleaseEvent("event:1"); err != nil {
+		t.Fatalf("ReleaseEvent: %v", err)
+	}
+	claim("event:1", time.Now().Add(time.Hour), false)
+
+	// A claim older than staleBefore was left by a crash and is taken over
+	claim("event:2", longAgo, true)
+	claim("event:2", time.Now().Add(time.Minute), true)
+}
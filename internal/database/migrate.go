@@ -0,0 +1,286 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles are the SQL migrations, named NNNN_description.sql. Add a
+// new file with the next number for each schema change; never edit one that
+// has been released.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// goMigrations are migrations that need more than SQL, numbered in the same
+// sequence as the files
+var goMigrations = []migration{
+	{version: 2, name: "add_late_columns", up: addLateColumns},
+}
+
+// migration is one schema change, applied by running either its SQL or up
+type migration struct {
+	version int
+	name    string
+	sql     string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// MigrationStatus describes a known or applied migration
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time // zero if pending
+	Unknown   bool      // applied by a newer version of FamBot
+}
+
+// loadMigrations returns every migration in version order
+func loadMigrations() ([]migration, error) {
+	migrations := append([]migration(nil), goMigrations...)
+
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s must be named NNNN_description.sql", file)
+		}
+		contents, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(contents)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("migrations %s and %s share version %d",
+				migrations[i-1].name, migrations[i].name, migrations[i].version)
+		}
+	}
+	return migrations, nil
+}
+
+func (d *Database) ensureMigrationsTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	return err
+}
+
+// appliedMigrations returns when each applied migration ran, by version
+func (d *Database) appliedMigrations(ctx context.Context) (map[int]MigrationStatus, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	for rows.Next() {
+		var status MigrationStatus
+		if err := rows.Scan(&status.Version, &status.Name, &status.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
+
+// Migrate applies pending migrations in order, each in its own transaction
+// together with its schema_migrations row. Instances starting together are
+// safe: the row is written first, so a migration another instance applied
+// meanwhile is skipped.
+func (d *Database) Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	if err := d.ensureMigrationsTable(ctx); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := d.appliedMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = true
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		if err := d.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %w", m.version, m.name, err)
+		}
+	}
+	for version, status := range applied {
+		if !known[version] {
+			log.Printf("Warning: database has migration %04d_%s, which this version of FamBot doesn't know", version, status.Name)
+		}
+	}
+	return nil
+}
+
+func (d *Database) applyMigration(ctx context.Context, m migration) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Claim the version first; this also takes SQLite's write lock
+	result, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)
+		ON CONFLICT(version) DO NOTHING`, m.version, m.name, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// Another instance applied it meanwhile
+		return nil
+	}
+
+	if m.up != nil {
+		err = m.up(ctx, tx)
+	} else {
+		_, err = tx.ExecContext(ctx, m.sql)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Applied migration %04d_%s", m.version, m.name)
+	return nil
+}
+
+// MigrationStatus lists every known migration and any unknown applied ones,
+// in version order. It only reads: on a database that has never been
+// migrated, every migration is pending.
+func (d *Database) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var tracked bool
+	err = d.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&tracked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for schema_migrations: %w", err)
+	}
+	applied := make(map[int]MigrationStatus)
+	if tracked {
+		applied, err = d.appliedMigrations(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			status.AppliedAt = a.AppliedAt
+			delete(applied, m.version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, a := range applied {
+		a.Unknown = true
+		statuses = append(statuses, a)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// addLateColumns brings databases created before versioned migrations up to
+// the 0001 schema by adding the columns that used to be added at startup,
+// then does what depends on them. On new databases it changes nothing.
+func addLateColumns(ctx context.Context, tx *sql.Tx) error {
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"users", "timezone", "TEXT DEFAULT ''"},
+		{"users", "synced_at", "DATETIME"},
+		{"karma_log", "message_ts", "TEXT"},
+		{"whoop_connections", "provider", "TEXT NOT NULL DEFAULT 'whoop'"},
+		{"whoop_recovery", "score_state", "TEXT NOT NULL DEFAULT 'SCORED'"},
+		{"whoop_recovery", "calibrating", "BOOLEAN DEFAULT 0"},
+		{"whoop_sleep", "score_state", "TEXT NOT NULL DEFAULT 'SCORED'"},
+		{"whoop_strain", "score_state", "TEXT NOT NULL DEFAULT 'SCORED'"},
+	}
+	for _, c := range columns {
+		if err := ensureColumn(ctx, tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	queries := []string{
+		// Each message gives a recipient karma once, however often Slack
+		// delivers it; older rows have no message_ts
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_karma_log_message
+			ON karma_log(channel, message_ts, user_id) WHERE message_ts IS NOT NULL`,
+		// WHOOP dates used to be stored as full timestamps; keep only the day
+		`UPDATE OR REPLACE whoop_recovery SET date = substr(date, 1, 10) WHERE length(date) > 10`,
+		`UPDATE OR REPLACE whoop_sleep SET date = substr(date, 1, 10) WHERE length(date) > 10`,
+		`UPDATE OR REPLACE whoop_strain SET date = substr(date, 1, 10) WHERE length(date) > 10`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to execute query %s: %w", query, err)
+		}
+	}
+	return nil
+}
+
+// ensureColumn adds a column to an existing table if it is missing
+func ensureColumn(ctx context.Context, tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// preMigrationSchema is the schema FamBot created before versioned
+// migrations, with WHOOP dates stored as full timestamps
+const preMigrationSchema = `
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	username TEXT NOT NULL,
+	real_name TEXT,
+	email TEXT
+);
+CREATE TABLE karma (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	username TEXT NOT NULL,
+	score INTEGER DEFAULT 0,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id)
+);
+CREATE TABLE karma_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	given_by TEXT NOT NULL,
+	reason TEXT,
+	change INTEGER NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	channel TEXT
+);
+CREATE TABLE birthdays (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	username TEXT NOT NULL,
+	month INTEGER NOT NULL,
+	day INTEGER NOT NULL,
+	year INTEGER DEFAULT 0,
+	timezone TEXT DEFAULT 'UTC',
+	UNIQUE(user_id)
+);
+CREATE TABLE anniversaries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	username TEXT NOT NULL,
+	month INTEGER NOT NULL,
+	day INTEGER NOT NULL,
+	year INTEGER NOT NULL,
+	timezone TEXT DEFAULT 'UTC',
+	UNIQUE(user_id)
+);
+CREATE TABLE sassy_responses (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	response TEXT NOT NULL,
+	category TEXT NOT NULL,
+	active BOOLEAN DEFAULT 1
+);
+CREATE TABLE whoop_connections (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	whoop_user_id TEXT NOT NULL,
+	access_token TEXT NOT NULL,
+	refresh_token TEXT NOT NULL,
+	expires_at DATETIME NOT NULL,
+	connected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	active BOOLEAN DEFAULT 1,
+	UNIQUE(user_id)
+);
+CREATE TABLE whoop_recovery (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	whoop_user_id TEXT NOT NULL,
+	date DATE NOT NULL,
+	score INTEGER NOT NULL,
+	hrv REAL NOT NULL,
+	rhr INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id, date)
+);
+CREATE TABLE whoop_sleep (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	whoop_user_id TEXT NOT NULL,
+	date DATE NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	efficiency REAL NOT NULL,
+	score INTEGER NOT NULL,
+	stages_deep_ms INTEGER NOT NULL,
+	stages_rem_ms INTEGER NOT NULL,
+	stages_light_ms INTEGER NOT NULL,
+	stages_wake_ms INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id, date)
+);
+CREATE TABLE whoop_strain (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	whoop_user_id TEXT NOT NULL,
+	date DATE NOT NULL,
+	score REAL NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id, date)
+);
+
+INSERT INTO users (id, username) VALUES ('U1', 'alice');
+INSERT INTO karma_log (user_id, given_by, change, channel) VALUES ('U1', 'U2', 1, 'C1');
+INSERT INTO whoop_connections (user_id, whoop_user_id, access_token, refresh_token, expires_at)
+	VALUES ('U1', '42', 'access', 'refresh', '2024-03-06 00:00:00+00:00');
+-- Two syncs of the same day stored under different timestamps
+INSERT INTO whoop_recovery (user_id, whoop_user_id, date, score, hrv, rhr)
+	VALUES ('U1', '42', '2024-03-05 00:00:00+00:00', 60, 50, 55);
+INSERT INTO whoop_recovery (user_id, whoop_user_id, date, score, hrv, rhr)
+	VALUES ('U1', '42', '2024-03-05 07:30:00+00:00', 65, 52, 54);
+INSERT INTO whoop_sleep (user_id, whoop_user_id, date, duration_ms, efficiency, score,
+	stages_deep_ms, stages_rem_ms, stages_light_ms, stages_wake_ms)
+	VALUES ('U1', '42', '2024-03-05 00:00:00+00:00', 28800000, 90, 80, 1, 1, 1, 1);
+INSERT INTO whoop_strain (user_id, whoop_user_id, date, score)
+	VALUES ('U1', '42', '2024-03-04 00:00:00+00:00', 12.5);
+`
+
+// openFixture creates a database in the pre-migration schema
+func openFixture(t *testing.T) *Database {
+	t.Helper()
+	d, err := Open(filepath.Join(t.TempDir(), "fambot.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	if _, err := d.db.Exec(preMigrationSchema); err != nil {
+		t.Fatalf("creating fixture: %v", err)
+	}
+	return d
+}
+
+func TestMigrateUpgradesPreMigrationDatabase(t *testing.T) {
+	ctx := context.Background()
+	d := openFixture(t)
+
+	// A second run must find nothing to do
+	for i := 0; i < 2; i++ {
+		if err := d.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate run %d: %v", i+1, err)
+		}
+	}
+
+	columns := []struct{ table, column string }{
+		{"users", "timezone"},
+		{"users", "synced_at"},
+		{"karma_log", "message_ts"},
+		{"whoop_connections", "provider"},
+		{"whoop_recovery", "score_state"},
+		{"whoop_recovery", "calibrating"},
+		{"whoop_sleep", "score_state"},
+		{"whoop_strain", "score_state"},
+	}
+	for _, c := range columns {
+		var n int
+		err := d.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&n)
+		if err != nil {
+			t.Fatalf("inspecting %s: %v", c.table, err)
+		}
+		if n != 1 {
+			t.Errorf("column %s.%s is missing", c.table, c.column)
+		}
+	}
+
+	var indexes int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_karma_log_message'`).Scan(&indexes)
+	if err != nil {
+		t.Fatalf("inspecting indexes: %v", err)
+	}
+	if indexes != 1 {
+		t.Error("index idx_karma_log_message is missing")
+	}
+
+	// Existing rows get the new columns' defaults
+	var provider, scoreState string
+	if err := d.db.QueryRow(`SELECT provider FROM whoop_connections WHERE user_id = 'U1'`).Scan(&provider); err != nil {
+		t.Fatalf("reading connection: %v", err)
+	}
+	if provider != "whoop" {
+		t.Errorf("connection provider = %q, want whoop", provider)
+	}
+	if err := d.db.QueryRow(`SELECT score_state FROM whoop_sleep`).Scan(&scoreState); err != nil {
+		t.Fatalf("reading sleep: %v", err)
+	}
+	if scoreState != "SCORED" {
+		t.Errorf("sleep score_state = %q, want SCORED", scoreState)
+	}
+
+	// Dates are cast to text so the driver doesn't parse them
+	dates := []struct {
+		table string
+		want  []string
+	}{
+		{"whoop_recovery", []string{"2024-03-05"}},
+		{"whoop_sleep", []string{"2024-03-05"}},
+		{"whoop_strain", []string{"2024-03-04"}},
+	}
+	for _, tc := range dates {
+		rows, err := d.db.Query(`SELECT CAST(date AS TEXT) FROM ` + tc.table + ` ORDER BY date`)
+		if err != nil {
+			t.Fatalf("reading %s dates: %v", tc.table, err)
+		}
+		var got []string
+		for rows.Next() {
+			var date string
+			if err := rows.Scan(&date); err != nil {
+				t.Fatalf("scanning %s date: %v", tc.table, err)
+			}
+			got = append(got, date)
+		}
+		rows.Close()
+		if len(got) != len(tc.want) {
+			t.Errorf("%s dates = %v, want %v", tc.table, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s dates = %v, want %v", tc.table, got, tc.want)
+				break
+			}
+		}
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	applied, err := d.appliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("appliedMigrations: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("schema_migrations has %d rows, want %d", len(applied), len(migrations))
+	}
+	for _, m := range migrations {
+		if status, ok := applied[m.version]; !ok || status.Name != m.name {
+			t.Errorf("migration %04d_%s isn't recorded", m.version, m.name)
+		}
+	}
+}
+
+func TestMigrationStatusReportsUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	d := openFixture(t)
+
+	if err := d.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	// As if a newer FamBot had migrated the database further
+	_, err := d.db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'from_the_future', ?)`,
+		time.Now().UTC())
+	if err != nil {
+		t.Fatalf("recording unknown migration: %v", err)
+	}
+	if err := d.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate with an unknown version: %v", err)
+	}
+
+	statuses, err := d.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	if len(statuses) == 0 {
+		t.Fatal("MigrationStatus returned nothing")
+	}
+
+	last := statuses[len(statuses)-1]
+	if last.Version != 9999 || last.Name != "from_the_future" || !last.Unknown {
+		t.Errorf("last status = %+v, want unknown version 9999", last)
+	}
+	for _, status := range statuses[:len(statuses)-1] {
+		if status.Unknown {
+			t.Errorf("migration %04d_%s reported as unknown", status.Version, status.Name)
+		}
+		if status.AppliedAt.IsZero() {
+			t.Errorf("migration %04d_%s reported as pending", status.Version, status.Name)
+		}
+	}
+}
+
+func TestMigrationStatusDoesNotWrite(t *testing.T) {
+	ctx := context.Background()
+	d := openFixture(t)
+
+	statuses, err := d.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(statuses) != len(migrations) {
+		t.Errorf("got %d statuses, want %d", len(statuses), len(migrations))
+	}
+	for _, status := range statuses {
+		if !status.AppliedAt.IsZero() || status.Unknown {
+			t.Errorf("migration %04d_%s = %+v, want pending", status.Version, status.Name, status)
+		}
+	}
+
+	var tables int
+	err = d.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&tables)
+	if err != nil {
+		t.Fatalf("inspecting tables: %v", err)
+	}
+	if tables != 0 {
+		t.Error("MigrationStatus created schema_migrations")
+	}
+}
@@ -0,0 +1,200 @@
+-- Full schema as of the first versioned release. Every statement is
+-- idempotent so databases created before migrations existed can adopt it;
+-- columns they are missing are added by migration 0002.
+
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    username TEXT NOT NULL,
+    real_name TEXT,
+    email TEXT,
+    timezone TEXT DEFAULT '',
+    synced_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS channels (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    is_private BOOLEAN DEFAULT 0,
+    synced_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_channels_name ON channels(name);
+
+CREATE TABLE IF NOT EXISTS karma (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    username TEXT NOT NULL,
+    score INTEGER DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS karma_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    given_by TEXT NOT NULL,
+    reason TEXT,
+    change INTEGER NOT NULL,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    channel TEXT,
+    message_ts TEXT
+);
+
+CREATE TABLE IF NOT EXISTS birthdays (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    username TEXT NOT NULL,
+    month INTEGER NOT NULL,
+    day INTEGER NOT NULL,
+    year INTEGER DEFAULT 0,
+    timezone TEXT DEFAULT 'UTC',
+    UNIQUE(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS anniversaries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    username TEXT NOT NULL,
+    month INTEGER NOT NULL,
+    day INTEGER NOT NULL,
+    year INTEGER NOT NULL,
+    timezone TEXT DEFAULT 'UTC',
+    UNIQUE(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS sassy_responses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    response TEXT NOT NULL,
+    category TEXT NOT NULL,
+    active BOOLEAN DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS whoop_connections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    provider TEXT NOT NULL DEFAULT 'whoop',
+    whoop_user_id TEXT NOT NULL,
+    access_token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    connected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    active BOOLEAN DEFAULT 1,
+    UNIQUE(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS whoop_recovery (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    whoop_user_id TEXT NOT NULL,
+    date DATE NOT NULL,
+    score_state TEXT NOT NULL DEFAULT 'SCORED',
+    calibrating BOOLEAN DEFAULT 0,
+    score INTEGER NOT NULL,
+    hrv REAL NOT NULL,
+    rhr INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS whoop_sleep (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    whoop_user_id TEXT NOT NULL,
+    date DATE NOT NULL,
+    score_state TEXT NOT NULL DEFAULT 'SCORED',
+    duration_ms INTEGER NOT NULL,
+    efficiency REAL NOT NULL,
+    score INTEGER NOT NULL,
+    stages_deep_ms INTEGER NOT NULL,
+    stages_rem_ms INTEGER NOT NULL,
+    stages_light_ms INTEGER NOT NULL,
+    stages_wake_ms INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS whoop_strain (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    whoop_user_id TEXT NOT NULL,
+    date DATE NOT NULL,
+    score_state TEXT NOT NULL DEFAULT 'SCORED',
+    score REAL NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS whoop_privacy (
+    user_id TEXT PRIMARY KEY,
+    level TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS whoop_challenges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    metric TEXT NOT NULL,
+    goal REAL DEFAULT 0,
+    created_by TEXT NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS whoop_challenge_participants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    challenge_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(challenge_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS whoop_alert_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    rule TEXT NOT NULL,
+    threshold REAL NOT NULL,
+    last_alerted DATE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, rule)
+);
+
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_key TEXT PRIMARY KEY,
+    processed_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job TEXT NOT NULL,
+    scheduled_for DATETIME,
+    trigger TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    outcome TEXT NOT NULL DEFAULT 'running',
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS outbound_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel TEXT NOT NULL,
+    thread_ts TEXT NOT NULL DEFAULT '',
+    text TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events(processed_at);
+CREATE INDEX IF NOT EXISTS idx_outbound_messages_channel ON outbound_messages(channel, id);
+
+-- A scheduled slot runs once, however often the bot restarts
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_runs_slot ON job_runs(job, scheduled_for) WHERE scheduled_for IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_job_runs_started_at ON job_runs(started_at);
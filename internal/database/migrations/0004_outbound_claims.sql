@@ -0,0 +1,5 @@
+-- A message is claimed by the instance posting it, so replicas sharing the
+-- database don't post it twice. A claim lapses at claimed_until in case its
+-- holder dies mid-post.
+ALTER TABLE outbound_messages ADD COLUMN claimed_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE outbound_messages ADD COLUMN claimed_until DATETIME;
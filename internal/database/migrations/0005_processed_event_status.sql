@@ -0,0 +1,4 @@
+-- Slack deliveries are claimed as 'handling' before they're handled and
+-- marked 'done' after, so a redelivery racing the first one, possibly on
+-- another replica, is skipped. Rows from before are all done.
+ALTER TABLE processed_events ADD COLUMN status TEXT NOT NULL DEFAULT 'done';
@@ -0,0 +1,300 @@
+// Package directory caches Slack users and channels in the database so
+// handlers don't call the Slack API for every message
+package directory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/pratikgajjar/fambot-go/internal/database"
+	"github.com/pratikgajjar/fambot-go/internal/models"
+)
+
+const (
+	// DefaultTTL is how long a cached user or channel is trusted before it is
+	// fetched again
+	DefaultTTL = 24 * time.Hour
+
+	// channelListInterval throttles full channel listings triggered by
+	// lookups of unknown names
+	channelListInterval = time.Minute
+
+	// lookupTimeout bounds a single Slack lookup made on behalf of a handler
+	lookupTimeout = 10 * time.Second
+
+	// channelPageSize is the largest page conversations.list allows
+	channelPageSize = 1000
+)
+
+// channelTypes are the conversations the bot resolves names for
+var channelTypes = []string{"public_channel", "private_channel"}
+
+// Directory resolves Slack users and channels, reading through to Slack when
+// the cached copy is missing or older than the TTL. If Slack can't be reached
+// a stale copy is used rather than failing.
+type Directory struct {
+	client *slack.Client
+	db     *database.Database
+	ttl    time.Duration
+
+	listMu     sync.Mutex // serializes channel listings
+	lastListed time.Time
+}
+
+// New creates a directory; a ttl of zero uses DefaultTTL
+func New(client *slack.Client, db *database.Database, ttl time.Duration) *Directory {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Directory{client: client, db: db, ttl: ttl}
+}
+
+// fresh reports whether something synced at syncedAt can still be trusted
+func (d *Directory) fresh(syncedAt time.Time) bool {
+	return !syncedAt.IsZero() && time.Since(syncedAt) < d.ttl
+}
+
+// User returns a user's profile, fetching it from Slack when the cached copy
+// is missing or expired
+func (d *Directory) User(userID string) (*models.User, error) {
+	cached, err := d.db.GetUser(userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error reading cached user %s: %v", userID, err)
+	}
+	if cached != nil && d.fresh(cached.SyncedAt) {
+		return cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	info, err := d.client.GetUserInfoContext(ctx, userID)
+	if err != nil {
+		if cached != nil {
+			log.Printf("Using stale profile for %s: %v", userID, err)
+			return cached, nil
+		}
+		return nil, fmt.Errorf("failed to get user info for %s: %w", userID, err)
+	}
+
+	user := userFromSlack(info, time.Now())
+	if err := d.db.UpsertUser(&user); err != nil {
+		log.Printf("Error caching user %s: %v", userID, err)
+	}
+	return &user, nil
+}
+
+// UserChanged updates a cached profile from a user_change event
+func (d *Directory) UserChanged(u slackevents.User) {
+	user := models.User{
+		ID:       u.ID,
+		Username: u.Name,
+		RealName: u.RealName,
+		Timezone: u.TZ,
+		SyncedAt: time.Now(),
+	}
+	// The event profile has no email, so keep the one already stored
+	if cached, err := d.db.GetUser(u.ID); err == nil {
+		user.Email = cached.Email
+	}
+	if err := d.db.UpsertUser(&user); err != nil {
+		log.Printf("Error caching changed user %s: %v", u.ID, err)
+	}
+}
+
+// ChannelName returns a channel's name without the #, or its ID if the name
+// can't be found
+func (d *Directory) ChannelName(channelID string) string {
+	cached, err := d.db.GetChannel(channelID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error reading cached channel %s: %v", channelID, err)
+	}
+	if cached != nil && d.fresh(cached.SyncedAt) {
+		return cached.Name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	info, err := d.client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: channelID})
+	if err != nil || info.Name == "" {
+		if cached != nil {
+			return cached.Name
+		}
+		if err != nil {
+			log.Printf("Error getting channel info for %s: %v", channelID, err)
+		}
+		// DMs and group DMs have no name
+		return channelID
+	}
+
+	channel := channelFromSlack(info, time.Now())
+	if err := d.db.UpsertChannels([]models.Channel{channel}); err != nil {
+		log.Printf("Error caching channel %s: %v", channelID, err)
+	}
+	return channel.Name
+}
+
+// ChannelID resolves a channel name, with or without the #, to its ID.
+// Channel IDs are returned as-is.
+func (d *Directory) ChannelID(name string) (string, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "#")
+	if isChannelID(name) {
+		return name, nil
+	}
+
+	cached, err := d.db.GetChannelByName(name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error reading cached channel #%s: %v", name, err)
+	}
+	if cached != nil && d.fresh(cached.SyncedAt) {
+		return cached.ID, nil
+	}
+
+	// Unknown or expired: refresh the whole list, at most once a minute so a
+	// misconfigured name doesn't list every channel on every event
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	if err := d.refreshChannels(ctx, false); err != nil {
+		if cached != nil {
+			log.Printf("Using stale ID for #%s: %v", name, err)
+			return cached.ID, nil
+		}
+		return "", err
+	}
+
+	channel, err := d.db.GetChannelByName(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		if cached != nil {
+			return cached.ID, nil
+		}
+		return "", fmt.Errorf("channel #%s not found", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up channel #%s: %w", name, err)
+	}
+	return channel.ID, nil
+}
+
+// UpdateChannel records a channel's name from a channel_created or
+// channel_rename event
+func (d *Directory) UpdateChannel(channelID, name string) {
+	channel := models.Channel{ID: channelID, Name: name, SyncedAt: time.Now()}
+	if cached, err := d.db.GetChannel(channelID); err == nil {
+		channel.IsPrivate = cached.IsPrivate
+	}
+	if err := d.db.UpsertChannels([]models.Channel{channel}); err != nil {
+		log.Printf("Error caching channel %s: %v", channelID, err)
+	}
+}
+
+// Warm loads every user and channel into the cache
+func (d *Directory) Warm(ctx context.Context) error {
+	start := time.Now()
+
+	members, err := d.client.GetUsersContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	users := make([]models.User, 0, len(members))
+	for i := range members {
+		if members[i].Deleted {
+			continue
+		}
+		users = append(users, userFromSlack(&members[i], start))
+	}
+	if err := d.db.UpsertUsers(users); err != nil {
+		return fmt.Errorf("failed to cache users: %w", err)
+	}
+
+	if err := d.refreshChannels(ctx, true); err != nil {
+		return err
+	}
+
+	log.Printf("Directory warmed with %d users in %s", len(users), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// refreshChannels lists every channel page by page and caches them. Unless
+// force is set, it does nothing if the list was fetched recently.
+func (d *Directory) refreshChannels(ctx context.Context, force bool) error {
+	d.listMu.Lock()
+	defer d.listMu.Unlock()
+
+	if !force && time.Since(d.lastListed) < channelListInterval {
+		return nil
+	}
+
+	now := time.Now()
+	params := &slack.GetConversationsParameters{
+		Types:           channelTypes,
+		ExcludeArchived: true,
+		Limit:           channelPageSize,
+	}
+	var channels []models.Channel
+	for {
+		page, cursor, err := d.client.GetConversationsContext(ctx, params)
+		var rateLimited *slack.RateLimitedError
+		if errors.As(err, &rateLimited) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(rateLimited.RetryAfter):
+				continue
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list channels: %w", err)
+		}
+
+		for i := range page {
+			channels = append(channels, channelFromSlack(&page[i], now))
+		}
+		if cursor == "" {
+			break
+		}
+		params.Cursor = cursor
+	}
+
+	if err := d.db.UpsertChannels(channels); err != nil {
+		return fmt.Errorf("failed to cache channels: %w", err)
+	}
+	d.lastListed = now
+	return nil
+}
+
+// isChannelID reports whether s looks like a channel ID rather than a name.
+// IDs are upper case, which channel names never are.
+func isChannelID(s string) bool {
+	if len(s) < 9 || (s[0] != 'C' && s[0] != 'G') {
+		return false
+	}
+	return strings.ToUpper(s) == s
+}
+
+func userFromSlack(u *slack.User, syncedAt time.Time) models.User {
+	return models.User{
+		ID:       u.ID,
+		Username: u.Name,
+		RealName: u.RealName,
+		Email:    u.Profile.Email,
+		Timezone: u.TZ,
+		SyncedAt: syncedAt,
+	}
+}
+
+func channelFromSlack(c *slack.Channel, syncedAt time.Time) models.Channel {
+	return models.Channel{
+		ID:        c.ID,
+		Name:      c.Name,
+		IsPrivate: c.IsPrivate,
+		SyncedAt:  syncedAt,
+	}
+}
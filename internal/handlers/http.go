@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+const (
+	// maxSlackBodySize bounds Slack request bodies; events and interaction
+	// payloads are far smaller
+	maxSlackBodySize = 1 << 20
+	// enqueueTimeout bounds how long a request waits for room in a full event
+	// queue before Slack is told to retry, keeping within its three seconds
+	enqueueTimeout = 2 * time.Second
+	// replayWindow matches the timestamp tolerance slack.NewSecretsVerifier
+	// enforces. A signature seen again within it is rejected as a replay.
+	replayWindow = 5 * time.Minute
+)
+
+var (
+	// errInvalidSignature is returned when a request isn't signed with the
+	// app's signing secret
+	errInvalidSignature = errors.New("missing or invalid signature")
+	// errReplayedRequest is returned when a signed request is delivered twice
+	errReplayedRequest = errors.New("request signature was already used")
+)
+
+// requestVerifier checks Slack request signatures and rejects replays
+type requestVerifier struct {
+	signingSecret string
+
+	mu   sync.Mutex
+	seen map[string]time.Time // signature -> when it can be forgotten
+}
+
+func newRequestVerifier(signingSecret string) *requestVerifier {
+	return &requestVerifier{
+		signingSecret: signingSecret,
+		seen:          make(map[string]time.Time),
+	}
+}
+
+// verify checks the request's signature and timestamp against the signing
+// secret, then remembers the signature so the same request can't be replayed
+// while its timestamp is still accepted. Slack retries are signed afresh, so
+// they are not affected.
+func (v *requestVerifier) verify(header http.Header, body []byte) error {
+	// slack-go's mismatch error includes the signature it computed, which
+	// must never reach the logs, so only the expiry error is passed through
+	sv, err := slack.NewSecretsVerifier(header, v.signingSecret)
+	if errors.Is(err, slack.ErrExpiredTimestamp) {
+		return err
+	}
+	if err != nil {
+		return errInvalidSignature
+	}
+	if _, err := sv.Write(body); err != nil {
+		return errInvalidSignature
+	}
+	if err := sv.Ensure(); err != nil {
+		return errInvalidSignature
+	}
+
+	signature := header.Get("X-Slack-Signature")
+	now := time.Now()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for seen, forgetAt := range v.seen {
+		if now.After(forgetAt) {
+			delete(v.seen, seen)
+		}
+	}
+	if _, ok := v.seen[signature]; ok {
+		return errReplayedRequest
+	}
+	// Timestamps are accepted up to the window either side of now
+	v.seen[signature] = now.Add(2 * replayWindow)
+	return nil
+}
+
+// handler wraps next so it only sees verified POST requests, with the body
+// already read
+func (v *requestVerifier) handler(next func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxSlackBodySize))
+		if err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if err := v.verify(r.Header, body); err != nil {
+			log.Printf("Rejected Slack request to %s: %v", r.URL.Path, err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		// Let form parsing read the body again
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r, body)
+	}
+}
+
+// RegisterHTTPRoutes serves Slack's HTTP deliveries for running without
+// Socket Mode. Point the app's Event Subscriptions, Slash Commands and
+// Interactivity request URLs at /slack/events, /slack/commands and
+// /slack/interactive. Slack wants an answer within three seconds, so each
+// request is queued on the event pool and acknowledged right away.
+func (h *SlackHandler) RegisterHTTPRoutes(mux *http.ServeMux, signingSecret string) {
+	verifier := newRequestVerifier(signingSecret)
+	mux.HandleFunc("/slack/events", verifier.handler(h.serveEvents))
+	mux.HandleFunc("/slack/commands", verifier.handler(h.serveSlashCommand))
+	mux.HandleFunc("/slack/interactive", verifier.handler(h.serveInteraction))
+}
+
+// enqueue queues task on the event pool and acknowledges the request, or
+// answers 503 so Slack retries if the queue stays full
+func (h *SlackHandler) enqueue(w http.ResponseWriter, r *http.Request, channel string, task func()) {
+	ctx, cancel := context.WithTimeout(r.Context(), enqueueTimeout)
+	defer cancel()
+	if err := h.dispatch(ctx, channel, task); err != nil {
+		http.Error(w, "Busy, retry later", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveEvents handles Events API deliveries and the URL verification
+// handshake Slack performs when the request URL is saved
+func (h *SlackHandler) serveEvents(w http.ResponseWriter, r *http.Request, body []byte) {
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if event.Type == slackevents.URLVerification {
+		verification, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			http.Error(w, "Invalid payload", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(verification.Challenge))
+		return
+	}
+
+	h.enqueue(w, r, eventChannel(event), func() {
+		h.handleEventsAPI(event)
+	})
+}
+
+// serveSlashCommand handles slash command deliveries. Responses are posted
+// through the API, so the acknowledgement is empty.
+func (h *SlackHandler) serveSlashCommand(w http.ResponseWriter, r *http.Request, body []byte) {
+	cmd, err := slack.SlashCommandParse(r)
+	if err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	h.enqueue(w, r, cmd.ChannelID, func() {
+		h.handleSlashCommand(cmd)
+	})
+}
+
+// serveInteraction handles interactivity deliveries such as button clicks
+func (h *SlackHandler) serveInteraction(w http.ResponseWriter, r *http.Request, body []byte) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(r.PostForm.Get("payload")), &callback); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	h.enqueue(w, r, callback.Channel.ID, func() {
+		h.handleInteraction(callback)
+	})
+}
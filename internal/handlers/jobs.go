@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/pratikgajjar/fambot-go/internal/scheduler"
+)
+
+// fambotJobsUsage explains the /fambot-jobs subcommands
+const fambotJobsUsage = "*Usage:*\n" +
+	"• `/fambot-jobs` or `/fambot-jobs list` - show scheduled jobs and their last runs\n" +
+	"• `/fambot-jobs run <job>` - run a job now"
+
+// jobTimeFormat shows job times compactly, in the scheduler's time zone
+const jobTimeFormat = "Mon Jan 2 15:04"
+
+// SetScheduler sets the scheduler /fambot-jobs lists and triggers
+func (h *SlackHandler) SetScheduler(s *scheduler.Scheduler) {
+	h.scheduler = s
+}
+
+// now returns the current time in the scheduler's time zone, so daily jobs
+// agree with their schedule on which day it is
+func (h *SlackHandler) now() time.Time {
+	if h.scheduler == nil {
+		return time.Now()
+	}
+	return time.Now().In(h.scheduler.Location())
+}
+
+// SetAdmins sets the users allowed to run admin commands
+func (h *SlackHandler) SetAdmins(userIDs []string) {
+	h.admins = userIDs
+}
+
+// isAdmin reports whether a user may run admin commands
+func (h *SlackHandler) isAdmin(userID string) bool {
+	return slices.Contains(h.admins, userID)
+}
+
+// handleFambotJobsCommand handles the /fambot-jobs [list|run <job>] slash command
+func (h *SlackHandler) handleFambotJobsCommand(cmd slack.SlashCommand) {
+	if !h.isAdmin(cmd.UserID) {
+		h.respondEphemeral(cmd, "🚫 Only FamBot admins can manage scheduled jobs.")
+		return
+	}
+	if h.scheduler == nil {
+		h.respondEphemeral(cmd, "The scheduler isn't running.")
+		return
+	}
+
+	args := strings.Fields(cmd.Text)
+	switch {
+	case len(args) == 0 || args[0] == "list":
+		h.respondEphemeral(cmd, h.formatJobs())
+	case args[0] == "run" && len(args) == 2:
+		name := args[1]
+		err := h.scheduler.Trigger(name)
+		switch {
+		case errors.Is(err, scheduler.ErrUnknownJob):
+			h.respondEphemeral(cmd, fmt.Sprintf("❓ There's no job called `%s`. Use `/fambot-jobs list` to see them.", name))
+		case errors.Is(err, scheduler.ErrJobRunning):
+			h.respondEphemeral(cmd, fmt.Sprintf("⏳ `%s` is already running.", name))
+		case err != nil:
+			log.Printf("Failed to trigger job %s: %v", name, err)
+			h.respondEphemeral(cmd, fmt.Sprintf("❌ Failed to start `%s`.", name))
+		default:
+			log.Printf("User %s triggered job %s", cmd.UserID, name)
+			h.respondEphemeral(cmd, fmt.Sprintf("▶️ Started `%s`. Check `/fambot-jobs list` for the outcome.", name))
+		}
+	default:
+		h.respondEphemeral(cmd, fambotJobsUsage)
+	}
+}
+
+// formatJobs lists each job's schedule, next run and last run
+func (h *SlackHandler) formatJobs() string {
+	jobs, err := h.scheduler.Jobs()
+	if err != nil {
+		log.Printf("Failed to list jobs: %v", err)
+		return "❌ Failed to load scheduled jobs. Please try again later."
+	}
+
+	location := h.scheduler.Location()
+	var b strings.Builder
+	fmt.Fprintf(&b, "🗓️ *Scheduled jobs* (times in %s)\n\n", location)
+	for _, job := range jobs {
+		fmt.Fprintf(&b, "• `%s` `%s` - next %s", job.Name, job.Schedule, job.Next.In(location).Format(jobTimeFormat))
+		switch {
+		case job.Running:
+			b.WriteString(", ⏳ running now")
+		case job.LastRun == nil:
+			b.WriteString(", never run")
+		default:
+			fmt.Fprintf(&b, ", last %s %s %s (%s)", job.LastRun.StartedAt.In(location).Format(jobTimeFormat),
+				outcomeEmoji(job.LastRun.Outcome), job.LastRun.Outcome, job.LastRun.Trigger)
+			if job.LastRun.Error != "" {
+				fmt.Fprintf(&b, ": %s", job.LastRun.Error)
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n" + fambotJobsUsage)
+	return b.String()
+}
+
+func outcomeEmoji(outcome string) string {
+	switch outcome {
+	case scheduler.OutcomeSuccess:
+		return "✅"
+	case scheduler.OutcomeRunning:
+		return "⏳"
+	default:
+		return "❌"
+	}
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/slack-go/slack"
@@ -14,10 +18,41 @@ import (
 	"github.com/slack-go/slack/socketmode"
 
 	"github.com/pratikgajjar/fambot-go/internal/database"
+	"github.com/pratikgajjar/fambot-go/internal/directory"
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
 	"github.com/pratikgajjar/fambot-go/internal/models"
+	"github.com/pratikgajjar/fambot-go/internal/oura"
+	"github.com/pratikgajjar/fambot-go/internal/outbox"
+	"github.com/pratikgajjar/fambot-go/internal/scheduler"
+	"github.com/pratikgajjar/fambot-go/internal/wellness"
 	"github.com/pratikgajjar/fambot-go/internal/whoop"
+	"github.com/pratikgajjar/fambot-go/internal/workerpool"
 )
 
+const (
+	// whoopSyncTimeout bounds how long a command or job waits on WHOOP syncs
+	whoopSyncTimeout = 2 * time.Minute
+
+	// defaultTrendDays and maxTrendDays bound the /whoop-trends window
+	defaultTrendDays = 7
+	maxTrendDays     = 90
+
+	// maxImportSize bounds export files accepted by /wellness-import
+	maxImportSize = 200 << 20
+
+	// processedEventTTL is how long delivered events are remembered for
+	// deduplication; Slack stops retrying well within it
+	processedEventTTL = 24 * time.Hour
+
+	// deliveryClaimTTL is how long a delivery being handled stays claimed.
+	// It outlasts any handler, so a claim this old belongs to an instance
+	// that crashed, and a redelivery takes it over.
+	deliveryClaimTTL = 5 * time.Minute
+)
+
+// notConnectedMessage answers wearable commands from users without a device
+const notConnectedMessage = "❌ You haven't connected a device yet! Use `/connect-whoop` or `/connect-oura` to link your WHOOP or Oura account."
+
 var (
 	karmaRegex    = regexp.MustCompile(`<@([A-Z0-9]+)>\s*\+\+`)
 	thankYouRegex = regexp.MustCompile(`(?i)\b(thank\s*(you|u)|thanks|thx|ty)\b`)
@@ -27,6 +62,7 @@ var (
 type SlackHandler struct {
 	client          *slack.Client
 	db              *database.Database
+	directory       *directory.Directory
 	botID           string
 	peopleChannel   string
 	gratefulChannel string
@@ -34,6 +70,11 @@ type SlackHandler struct {
 	workspaceID     string
 	whoopService    *whoop.Service
 	whoopFormatter  *whoop.MessageFormatter
+	connected       atomic.Bool
+	events          *workerpool.Pool
+	outbox          *outbox.Queue
+	scheduler       *scheduler.Scheduler
+	admins          []string
 }
 
 // New creates a new SlackHandler
@@ -41,6 +82,7 @@ func New(client *slack.Client, db *database.Database, peopleChannel, gratefulCha
 	return &SlackHandler{
 		client:          client,
 		db:              db,
+		directory:       directory.New(client, db, directory.DefaultTTL),
 		peopleChannel:   peopleChannel,
 		gratefulChannel: gratefulChannel,
 		standupChannel:  standupChannel,
@@ -59,13 +101,46 @@ func (h *SlackHandler) SetWorkspaceID(workspaceID string) {
 	h.workspaceID = workspaceID
 }
 
+// SetStandupMinSharing sets how many users must share individual WHOOP stats
+// before the morning standup shows per-person rows
+func (h *SlackHandler) SetStandupMinSharing(n int) {
+	h.whoopFormatter.SetMinSharing(n)
+}
+
+// SetDirectory sets the cache users and channels are resolved through
+func (h *SlackHandler) SetDirectory(dir *directory.Directory) {
+	h.directory = dir
+}
+
+// SetOutbox sets the queue messages are posted through. Without one,
+// messages are posted directly and lost if Slack rejects them.
+func (h *SlackHandler) SetOutbox(queue *outbox.Queue) {
+	h.outbox = queue
+}
+
+// SetEventPool sets the worker pool events are handled on. Without one,
+// events are handled inline by the caller.
+func (h *SlackHandler) SetEventPool(pool *workerpool.Pool) {
+	h.events = pool
+}
+
+// Connected reports whether the socket mode connection is up
+func (h *SlackHandler) Connected() bool {
+	return h.connected.Load()
+}
+
 // HandleSocketModeEvent handles incoming socket mode events
 func (h *SlackHandler) HandleSocketModeEvent(evt socketmode.Event, client *socketmode.Client) {
 	switch evt.Type {
 	case socketmode.EventTypeConnecting:
+		h.connected.Store(false)
 		log.Println("Connecting to Slack...")
 	case socketmode.EventTypeConnected:
+		h.connected.Store(true)
 		log.Println("Connected to Slack!")
+	case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth, socketmode.EventTypeDisconnect:
+		h.connected.Store(false)
+		log.Printf("Slack connection lost: %s", evt.Type)
 	case socketmode.EventTypeEventsAPI:
 		eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
 		if !ok {
@@ -74,7 +149,9 @@ func (h *SlackHandler) HandleSocketModeEvent(evt socketmode.Event, client *socke
 		}
 
 		client.Ack(*evt.Request)
-		h.handleEventsAPI(eventsAPIEvent)
+		h.dispatch(context.Background(), eventChannel(eventsAPIEvent), func() {
+			h.handleEventsAPI(eventsAPIEvent)
+		})
 
 	case socketmode.EventTypeSlashCommand:
 		cmd, ok := evt.Data.(slack.SlashCommand)
@@ -84,29 +161,134 @@ func (h *SlackHandler) HandleSocketModeEvent(evt socketmode.Event, client *socke
 		}
 
 		client.Ack(*evt.Request)
-		h.handleSlashCommand(cmd)
+		h.dispatch(context.Background(), cmd.ChannelID, func() {
+			h.handleSlashCommand(cmd)
+		})
+
+	case socketmode.EventTypeInteractive:
+		callback, ok := evt.Data.(slack.InteractionCallback)
+		if !ok {
+			log.Printf("Ignored %+v\n", evt)
+			return
+		}
+
+		client.Ack(*evt.Request)
+		h.dispatch(context.Background(), callback.Channel.ID, func() {
+			h.handleInteraction(callback)
+		})
 
 	default:
 		log.Printf("Ignored event type: %s\n", evt.Type)
 	}
 }
 
+// dispatch queues task behind earlier work for the same channel, so each
+// channel's events are handled in order while channels proceed in parallel.
+// It blocks while the queue is full, until ctx is done.
+func (h *SlackHandler) dispatch(ctx context.Context, channel string, task func()) error {
+	if h.events == nil {
+		task()
+		return nil
+	}
+	if err := h.events.Submit(ctx, channel, task); err != nil {
+		log.Printf("Dropping event for channel %s: %v", channel, err)
+		return err
+	}
+	return nil
+}
+
+// eventChannel returns the channel an Events API event belongs to, or "" for
+// events without one
+func eventChannel(event slackevents.EventsAPIEvent) string {
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.MessageEvent:
+		return ev.Channel
+	case *slackevents.AppMentionEvent:
+		return ev.Channel
+	}
+	return ""
+}
+
 // handleEventsAPI handles Events API events
 func (h *SlackHandler) handleEventsAPI(event slackevents.EventsAPIEvent) {
 	switch event.Type {
 	case slackevents.CallbackEvent:
-		innerEvent := event.InnerEvent
-		switch ev := innerEvent.Data.(type) {
-		case *slackevents.MessageEvent:
-			h.handleMessage(ev)
-		case *slackevents.AppMentionEvent:
-			h.handleAppMention(ev)
+		handle := func() {
+			innerEvent := event.InnerEvent
+			metrics.EventsHandled.Inc(innerEvent.Type)
+			switch ev := innerEvent.Data.(type) {
+			case *slackevents.MessageEvent:
+				h.handleMessage(ev)
+			case *slackevents.AppMentionEvent:
+				h.handleAppMention(ev)
+			case *slackevents.UserChangeEvent:
+				h.directory.UserChanged(ev.User)
+			case *slackevents.ChannelCreatedEvent:
+				h.directory.UpdateChannel(ev.Channel.ID, ev.Channel.Name)
+			case *slackevents.ChannelRenameEvent:
+				h.directory.UpdateChannel(ev.Channel.ID, ev.Channel.Name)
+			}
+		}
+
+		// Slack redelivers events it thinks timed out, and again after
+		// socket reconnects
+		callback, ok := event.Data.(*slackevents.EventsAPICallbackEvent)
+		if !ok {
+			handle()
+			return
+		}
+		if !h.handleOnce("event:"+callback.EventID, handle) {
+			log.Printf("Skipping duplicate delivery of event %s", callback.EventID)
 		}
 	default:
 		log.Printf("Unsupported Events API event received: %v\n", event.Type)
 	}
 }
 
+// handleOnce runs handle for a Slack delivery unless it was already handled
+// or is being handled, reporting whether it ran. The delivery is claimed
+// first, so a redelivery racing it, here or on another replica, is skipped.
+// If handle panics the claim is released, so a later redelivery is handled.
+// If claiming fails the delivery is handled anyway; karma has its own guard
+// against double counting.
+func (h *SlackHandler) handleOnce(key string, handle func()) bool {
+	claimed, err := h.db.ClaimEvent(key, time.Now().Add(-deliveryClaimTTL))
+	if err != nil {
+		log.Printf("Error claiming event %s: %v", key, err)
+		claimed = true
+	}
+	if !claimed {
+		return false
+	}
+
+	handled := false
+	defer func() {
+		if handled {
+			err = h.db.FinishEvent(key)
+		} else {
+			err = h.db.ReleaseEvent(key)
+		}
+		if err != nil {
+			log.Printf("Error recording outcome of event %s: %v", key, err)
+		}
+	}()
+	handle()
+	handled = true
+	return true
+}
+
+// PurgeProcessedEvents forgets deliveries older than processedEventTTL
+func (h *SlackHandler) PurgeProcessedEvents() error {
+	purged, err := h.db.PurgeProcessedEvents(time.Now().Add(-processedEventTTL))
+	if err != nil {
+		return fmt.Errorf("failed to purge processed events: %w", err)
+	}
+	if purged > 0 {
+		log.Printf("Purged %d processed event records", purged)
+	}
+	return nil
+}
+
 // handleMessage handles regular message events
 func (h *SlackHandler) handleMessage(event *slackevents.MessageEvent) {
 	// Skip bot messages and message subtypes we don't care about
@@ -114,11 +296,14 @@ func (h *SlackHandler) handleMessage(event *slackevents.MessageEvent) {
 		return
 	}
 
-	// Handle karma increments
-	h.handleKarmaIncrements(event)
+	// The same message can arrive under different event IDs
+	h.handleOnce("message:"+event.Channel+":"+event.TimeStamp, func() {
+		// Handle karma increments
+		h.handleKarmaIncrements(event)
 
-	// Handle thank you responses
-	h.handleThankYou(event)
+		// Handle thank you responses
+		h.handleThankYou(event)
+	})
 }
 
 // handleAppMention handles app mention events
@@ -151,7 +336,6 @@ func (h *SlackHandler) handleAppMention(event *slackevents.AppMentionEvent) {
 // handleKarmaIncrements processes karma increment patterns
 func (h *SlackHandler) handleKarmaIncrements(event *slackevents.MessageEvent) {
 
-
 	matches := karmaRegex.FindAllStringSubmatch(event.Text, -1)
 	var karmaRecipients []string
 
@@ -174,30 +358,26 @@ func (h *SlackHandler) handleKarmaIncrements(event *slackevents.MessageEvent) {
 			continue
 		}
 
-		// Get user info
-		userInfo, err := h.client.GetUserInfo(targetUserID)
+		// Get user info; the directory keeps the users table current
+		userInfo, err := h.directory.User(targetUserID)
 		if err != nil {
 			log.Printf("Error getting user info for %s: %v", targetUserID, err)
 			continue
 		}
 
-		// Store/update user in database
-		user := &models.User{
-			ID:       userInfo.ID,
-			Username: userInfo.Name,
-			RealName: userInfo.RealName,
-			Email:    userInfo.Profile.Email,
-		}
-		h.db.UpsertUser(user)
-
 		// Increment karma
-		reason := fmt.Sprintf("Karma given in #%s", getChannelName(event.Channel))
-		err = h.db.IncrementKarma(targetUserID, userInfo.Name, event.User, reason, event.Channel)
+		reason := fmt.Sprintf("Karma given in #%s", h.directory.ChannelName(event.Channel))
+		given, err := h.db.IncrementKarma(targetUserID, userInfo.Username, event.User, reason, event.Channel, event.TimeStamp)
 		if err != nil {
 			log.Printf("Error incrementing karma: %v", err)
 			h.sendThreadedMessage(event.Channel, event.TimeStamp, "Oops! Something went wrong with the karma system. 🤖💥")
 			continue
 		}
+		if !given {
+			// This message already gave them karma
+			continue
+		}
+		metrics.KarmaGiven.Inc()
 
 		// Get karma count
 		karma, err := h.db.GetKarma(targetUserID)
@@ -243,22 +423,12 @@ func (h *SlackHandler) handleThankYou(event *slackevents.MessageEvent) {
 		return
 	}
 
-	// Get user info for the person saying thanks
-	userInfo, err := h.client.GetUserInfo(event.User)
-	if err != nil {
+	// Record the person saying thanks
+	if _, err := h.directory.User(event.User); err != nil {
 		log.Printf("Error getting user info for %s: %v", event.User, err)
 		return
 	}
 
-	// Store/update user in database
-	user := &models.User{
-		ID:       userInfo.ID,
-		Username: userInfo.Name,
-		RealName: userInfo.RealName,
-		Email:    userInfo.Profile.Email,
-	}
-	h.db.UpsertUser(user)
-
 	// Send sassy response suggesting they give karma instead
 	sassyResponse, err := h.db.GetRandomSassyResponse("thank_you_no_karma")
 	var response string
@@ -274,6 +444,10 @@ func (h *SlackHandler) handleThankYou(event *slackevents.MessageEvent) {
 
 // handleSlashCommand handles slash commands
 func (h *SlackHandler) handleSlashCommand(cmd slack.SlashCommand) {
+	metrics.EventsHandled.Inc(string(socketmode.EventTypeSlashCommand))
+	command := cmd.Command
+	defer func() { metrics.SlashCommands.Inc(command) }()
+
 	switch cmd.Command {
 	case "/top-karma":
 		h.handleTopKarmaCommand(cmd)
@@ -285,15 +459,30 @@ func (h *SlackHandler) handleSlashCommand(cmd slack.SlashCommand) {
 		h.handleMyKarmaCommand(cmd)
 	case "/fambot-help":
 		h.handleHelpCommand(cmd)
+	case "/fambot-jobs":
+		h.handleFambotJobsCommand(cmd)
 	case "/connect-whoop":
-		h.handleConnectWHOOPCommand(cmd)
+		h.handleConnectDeviceCommand(cmd, whoop.ProviderName)
+	case "/connect-oura":
+		h.handleConnectDeviceCommand(cmd, oura.ProviderName)
 	case "/whoop-status":
 		h.handleWHOOPStatusCommand(cmd)
 	case "/morning-report":
 		h.handleMorningReportCommand(cmd)
 	case "/disconnect-whoop":
 		h.handleDisconnectWHOOPCommand(cmd)
+	case "/whoop-trends":
+		h.handleWHOOPTrendsCommand(cmd)
+	case "/whoop-alerts":
+		h.handleWHOOPAlertsCommand(cmd)
+	case "/whoop-privacy":
+		h.handleWHOOPPrivacyCommand(cmd)
+	case "/whoop-challenge":
+		h.handleWHOOPChallengeCommand(cmd)
+	case "/wellness-import":
+		h.handleWellnessImportCommand(cmd)
 	default:
+		command = "unknown"
 		h.respondToSlashCommand(cmd, "Unknown command! Use `/fambot-help` to see available commands.")
 	}
 }
@@ -371,7 +560,7 @@ func (h *SlackHandler) handleSetBirthdayCommand(cmd slack.SlashCommand) {
 	}
 
 	// Get user info
-	userInfo, err := h.client.GetUserInfo(cmd.UserID)
+	userInfo, err := h.directory.User(cmd.UserID)
 	if err != nil {
 		h.respondToSlashCommand(cmd, "Error getting your user info! 😅")
 		return
@@ -379,7 +568,7 @@ func (h *SlackHandler) handleSetBirthdayCommand(cmd slack.SlashCommand) {
 
 	birthday := &models.Birthday{
 		UserID:   cmd.UserID,
-		Username: userInfo.Name,
+		Username: userInfo.Username,
 		Month:    month,
 		Day:      day,
 		Year:     year,
@@ -432,7 +621,7 @@ func (h *SlackHandler) handleSetAnniversaryCommand(cmd slack.SlashCommand) {
 	}
 
 	// Get user info
-	userInfo, err := h.client.GetUserInfo(cmd.UserID)
+	userInfo, err := h.directory.User(cmd.UserID)
 	if err != nil {
 		h.respondToSlashCommand(cmd, "Error getting your user info! 😅")
 		return
@@ -440,7 +629,7 @@ func (h *SlackHandler) handleSetAnniversaryCommand(cmd slack.SlashCommand) {
 
 	anniversary := &models.Anniversary{
 		UserID:   cmd.UserID,
-		Username: userInfo.Name,
+		Username: userInfo.Username,
 		Month:    month,
 		Day:      day,
 		Year:     year,
@@ -484,7 +673,8 @@ I'm a sassy bot with a heart of gold! 💫✨`
 
 // SendBirthdayReminder sends birthday reminders to the people channel
 func (h *SlackHandler) SendBirthdayReminder() {
-	birthdays, err := h.db.GetTodaysBirthdays()
+	now := h.now()
+	birthdays, err := h.db.GetTodaysBirthdays(now)
 	if err != nil {
 		log.Printf("Error getting today's birthdays: %v", err)
 		return
@@ -493,7 +683,7 @@ func (h *SlackHandler) SendBirthdayReminder() {
 	for _, birthday := range birthdays {
 		var message string
 		if birthday.Year > 1970 {
-			age := time.Now().Year() - birthday.Year
+			age := now.Year() - birthday.Year
 			message = fmt.Sprintf("🎂 Happy Birthday <@%s>! 🎉\nAnother year older, another year wiser! Hope your %d%s year is absolutely amazing! 🎊✨",
 				birthday.UserID, age, getOrdinalSuffix(age))
 		} else {
@@ -507,14 +697,15 @@ func (h *SlackHandler) SendBirthdayReminder() {
 
 // SendAnniversaryReminder sends anniversary reminders to the people channel
 func (h *SlackHandler) SendAnniversaryReminder() {
-	anniversaries, err := h.db.GetTodaysAnniversaries()
+	now := h.now()
+	anniversaries, err := h.db.GetTodaysAnniversaries(now)
 	if err != nil {
 		log.Printf("Error getting today's anniversaries: %v", err)
 		return
 	}
 
 	for _, anniversary := range anniversaries {
-		yearsWorked := time.Now().Year() - anniversary.Year
+		yearsWorked := now.Year() - anniversary.Year
 		message := fmt.Sprintf("🎉 Happy Work Anniversary <@%s>! 🎊\n%d years of awesomeness! Thanks for being part of our amazing team! 🚀✨",
 			anniversary.UserID, yearsWorked)
 
@@ -524,23 +715,38 @@ func (h *SlackHandler) SendAnniversaryReminder() {
 
 // Helper methods
 func (h *SlackHandler) sendMessage(channel, text string) {
-	_, _, err := h.client.PostMessage(channel, slack.MsgOptionText(text, false))
-	if err != nil {
-		log.Printf("Error sending message: %v", err)
-	}
+	h.postMessage(channel, "", text)
+}
+
+// SendDirectMessage sends a direct message to a user from the bot
+func (h *SlackHandler) SendDirectMessage(userID, text string) {
+	h.sendMessage(userID, text)
 }
 
 // sendThreadedMessage sends a message as a reply in a thread
 func (h *SlackHandler) sendThreadedMessage(channel, threadTS, text string) {
-	_, _, err := h.client.PostMessage(channel,
-		slack.MsgOptionText(text, false),
-		slack.MsgOptionTS(threadTS))
-	if err != nil {
-		log.Printf("Error sending threaded message: %v", err)
-	}
+	h.postMessage(channel, threadTS, text)
 }
 
+// postMessage queues a message on the outbox, which retries it through rate
+// limits and restarts, falling back to posting directly
+func (h *SlackHandler) postMessage(channel, threadTS, text string) {
+	if h.outbox != nil {
+		err := h.outbox.Enqueue(channel, threadTS, text)
+		if err == nil {
+			return
+		}
+		log.Printf("Error queueing message, posting directly: %v", err)
+	}
 
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+	if _, _, err := h.client.PostMessage(channel, options...); err != nil {
+		log.Printf("Error sending message: %v", err)
+	}
+}
 
 func (h *SlackHandler) postToGratefulChannelMultiple(userIDs []string, originalChannel, threadTS, parentThreadTS string) {
 	// Skip if grateful channel is not configured
@@ -554,7 +760,7 @@ func (h *SlackHandler) postToGratefulChannelMultiple(userIDs []string, originalC
 	}
 
 	// Get grateful channel ID by name
-	gratefulChannelID, err := h.getChannelIDByName(h.gratefulChannel)
+	gratefulChannelID, err := h.directory.ChannelID(h.gratefulChannel)
 	if err != nil {
 		log.Printf("Error getting grateful channel ID: %v", err)
 		return
@@ -596,35 +802,6 @@ func (h *SlackHandler) postToGratefulChannelMultiple(userIDs []string, originalC
 	h.sendMessage(gratefulChannelID, message)
 }
 
-// getChannelIDByName resolves a channel name to its ID
-func (h *SlackHandler) getChannelIDByName(channelName string) (string, error) {
-	// If it's already a channel ID (starts with C), return as-is
-	if strings.HasPrefix(channelName, "C") {
-		return channelName, nil
-	}
-
-	// Remove # prefix if present
-	channelName = strings.TrimPrefix(channelName, "#")
-
-	// Get list of channels
-	channels, _, err := h.client.GetConversationsForUser(&slack.GetConversationsForUserParameters{
-		Types: []string{"public_channel"},
-		Limit: 1000,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to get channels: %w", err)
-	}
-
-	// Find channel by name
-	for _, channel := range channels {
-		if channel.Name == channelName {
-			return channel.ID, nil
-		}
-	}
-
-	return "", fmt.Errorf("channel #%s not found", channelName)
-}
-
 func (h *SlackHandler) sendTopKarma(channel string) {
 	karmas, err := h.db.GetTopKarma(10)
 	if err != nil {
@@ -670,19 +847,18 @@ I'm here to spread good vibes and sass! 💫✨`
 }
 
 func (h *SlackHandler) respondToSlashCommand(cmd slack.SlashCommand, text string) {
-	_, _, err := h.client.PostMessage(cmd.ChannelID, slack.MsgOptionText(text, false))
+	h.sendMessage(cmd.ChannelID, text)
+}
+
+// respondEphemeral answers a slash command with a message only the caller can see
+func (h *SlackHandler) respondEphemeral(cmd slack.SlashCommand, text string) {
+	_, err := h.client.PostEphemeral(cmd.ChannelID, cmd.UserID, slack.MsgOptionText(text, false))
 	if err != nil {
 		log.Printf("Error responding to slash command: %v", err)
 	}
 }
 
 // Utility functions
-func getChannelName(channelID string) string {
-	// This is a simplified version. In a real implementation,
-	// you might want to cache channel names or fetch them from Slack API
-	return channelID
-}
-
 func getOrdinalSuffix(n int) string {
 	if n%100 >= 11 && n%100 <= 13 {
 		return "th"
@@ -701,69 +877,477 @@ func getOrdinalSuffix(n int) string {
 
 // WHOOP-related handlers
 
-// handleConnectWHOOPCommand handles the /connect-whoop slash command
-func (h *SlackHandler) handleConnectWHOOPCommand(cmd slack.SlashCommand) {
+// handleConnectDeviceCommand handles /connect-whoop and /connect-oura,
+// sending the user to the provider's OAuth page. The link is single-use and
+// tied to the caller, so only they see it.
+func (h *SlackHandler) handleConnectDeviceCommand(cmd slack.SlashCommand, providerName string) {
 	if h.whoopService == nil {
-		h.respondToSlashCommand(cmd, "WHOOP integration is not configured. Please contact your administrator.")
+		h.respondEphemeral(cmd, "Wearables integration is not configured. Please contact your administrator.")
+		return
+	}
+	provider, ok := h.whoopService.Provider(providerName)
+	if !ok {
+		h.respondEphemeral(cmd, fmt.Sprintf("%s integration is not configured. Please contact your administrator.", providerDisplayName(providerName)))
 		return
 	}
 
-	// Check if user is already connected
+	// Check if user is already connected; each user has one device at a time,
+	// though a device replaces file imports
 	connection, err := h.whoopService.GetConnectionStatus(cmd.UserID)
-	if err == nil && connection != nil {
-		h.respondToSlashCommand(cmd, "🔗 You're already connected to WHOOP! Use `/whoop-status` to see your stats or `/disconnect-whoop` to disconnect.")
+	if err == nil && connection != nil && connection.ProviderName() != models.ImportProvider {
+		h.respondEphemeral(cmd, fmt.Sprintf("🔗 You're already connected to %s! Use `/whoop-status` to see your stats or `/disconnect-whoop` to disconnect before switching devices.",
+			providerDisplayName(connection.ProviderName())))
 		return
 	}
 
+	// Record the user so they appear in standups and their Slack timezone
+	// can date records that don't carry one
+	if _, err := h.directory.User(cmd.UserID); err != nil {
+		log.Printf("Error getting user info for %s: %v", cmd.UserID, err)
+	}
+
 	// Generate auth URL
-	authURL := h.whoopService.GetAuthURL(cmd.UserID)
-	
-	response := fmt.Sprintf("🚀 *Connect Your WHOOP Account*\n\n" +
-		"Click the link below to authorize FamBot to access your WHOOP data:\n\n" +
-		"<%s|🔗 Connect WHOOP Account>\n\n" +
-		"_This will allow the bot to show your sleep, recovery, and strain data in morning standups!_", authURL)
-	
+	authURL, err := h.whoopService.GetAuthURL(provider.Name(), cmd.UserID)
+	if err != nil {
+		log.Printf("Failed to build %s auth URL for user %s: %v", provider.DisplayName(), cmd.UserID, err)
+		h.respondEphemeral(cmd, "❌ Failed to start the connection. Please try again later.")
+		return
+	}
+
+	response := fmt.Sprintf("🚀 *Connect Your %[1]s Account*\n\n"+
+		"Click the link below to authorize FamBot to access your %[1]s data:\n\n"+
+		"<%[2]s|🔗 Connect %[1]s Account>\n\n"+
+		"_This will allow the bot to show your sleep and recovery data in morning standups! The link works once and expires in %[3]d minutes._",
+		provider.DisplayName(), authURL, int(whoop.OAuthStateTTL.Minutes()))
+
 	h.respondToSlashCommand(cmd, response)
 }
 
+// providerDisplayName returns the user-facing name of a wearable provider
+func providerDisplayName(name string) string {
+	switch name {
+	case whoop.ProviderName:
+		return "WHOOP"
+	case oura.ProviderName:
+		return "Oura"
+	case models.ImportProvider:
+		return "file imports"
+	}
+	return name
+}
+
 // handleWHOOPStatusCommand handles the /whoop-status slash command
 func (h *SlackHandler) handleWHOOPStatusCommand(cmd slack.SlashCommand) {
 	if h.whoopService == nil {
-		h.respondToSlashCommand(cmd, "WHOOP integration is not configured. Please contact your administrator.")
+		h.respondEphemeral(cmd, "WHOOP integration is not configured. Please contact your administrator.")
 		return
 	}
 
 	// Check if user is connected
 	_, err := h.whoopService.GetConnectionStatus(cmd.UserID)
 	if err != nil {
-		h.respondToSlashCommand(cmd, "❌ You're not connected to WHOOP yet! Use `/connect-whoop` to link your account.")
+		h.respondEphemeral(cmd, notConnectedMessage)
 		return
 	}
 
 	// Sync user data first
-	if err := h.whoopService.SyncUserData(cmd.UserID); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), whoopSyncTimeout)
+	defer cancel()
+	if err := h.whoopService.SyncUserData(ctx, cmd.UserID); err != nil {
 		log.Printf("Failed to sync WHOOP data for user %s: %v", cmd.UserID, err)
-		h.respondToSlashCommand(cmd, "⚠️ Connected to WHOOP, but couldn't fetch latest data. Please try again later.")
+		h.respondEphemeral(cmd, "⚠️ Connected to WHOOP, but couldn't fetch latest data. Please try again later.")
 		return
 	}
 
 	// Get user's latest data
-	userData, err := h.whoopService.GetUserLatestData(cmd.UserID)
+	snapshot, err := h.whoopService.GetUserLatestData(cmd.UserID)
 	if err != nil {
-		h.respondToSlashCommand(cmd, "❌ Failed to retrieve your WHOOP data. Please try again later.")
+		log.Printf("Failed to get WHOOP data for user %s: %v", cmd.UserID, err)
+		h.respondEphemeral(cmd, "❌ Failed to retrieve your WHOOP data. Please try again later.")
 		return
 	}
 
-	// Add user info to data
-	userInfo, err := h.client.GetUserInfo(cmd.UserID)
-	if err == nil {
-		userData["username"] = userInfo.Name
-		userData["real_name"] = userInfo.RealName
+	entry := models.StandupEntry{UserID: cmd.UserID, Username: cmd.UserName, UserSnapshot: *snapshot}
+	if userInfo, err := h.directory.User(cmd.UserID); err == nil {
+		entry.Username = userInfo.Username
+		entry.RealName = userInfo.RealName
+	}
+
+	baseline, err := h.whoopService.GetUserAverages(cmd.UserID, whoop.BaselineDays)
+	if err != nil {
+		log.Printf("Failed to get WHOOP baseline for user %s: %v", cmd.UserID, err)
 	}
 
 	// Format the status message
-	message := h.whoopFormatter.FormatUserStatus(userData)
-	h.respondToSlashCommand(cmd, message)
+	message := h.whoopFormatter.FormatUserStatus(entry, baseline)
+	h.respondEphemeral(cmd, message)
+}
+
+// handleWHOOPTrendsCommand handles the /whoop-trends [days] slash command
+func (h *SlackHandler) handleWHOOPTrendsCommand(cmd slack.SlashCommand) {
+	if h.whoopService == nil {
+		h.respondEphemeral(cmd, "WHOOP integration is not configured. Please contact your administrator.")
+		return
+	}
+
+	days := defaultTrendDays
+	if text := strings.TrimSpace(cmd.Text); text != "" {
+		parsed, err := strconv.Atoi(text)
+		if err != nil || parsed < 1 || parsed > maxTrendDays {
+			h.respondEphemeral(cmd, fmt.Sprintf("Please give a number of days between 1 and %d.\nExample: `/whoop-trends 7` or `/whoop-trends 30`", maxTrendDays))
+			return
+		}
+		days = parsed
+	}
+
+	if _, err := h.whoopService.GetConnectionStatus(cmd.UserID); err != nil {
+		h.respondEphemeral(cmd, notConnectedMessage)
+		return
+	}
+
+	averages, err := h.whoopService.GetUserAverages(cmd.UserID, days)
+	if err != nil {
+		log.Printf("Failed to get WHOOP averages for user %s: %v", cmd.UserID, err)
+		h.respondEphemeral(cmd, "❌ Failed to calculate your WHOOP trends. Please try again later.")
+		return
+	}
+
+	baseline, err := h.whoopService.GetUserAverages(cmd.UserID, whoop.BaselineDays)
+	if err != nil {
+		log.Printf("Failed to get WHOOP baseline for user %s: %v", cmd.UserID, err)
+	}
+
+	team, err := h.whoopService.GetTeamAverages(days)
+	if err != nil {
+		log.Printf("Failed to get team WHOOP averages: %v", err)
+	}
+
+	displayName := cmd.UserName
+	if userInfo, err := h.directory.User(cmd.UserID); err == nil && userInfo.RealName != "" {
+		displayName = userInfo.RealName
+	}
+
+	h.respondEphemeral(cmd, h.whoopFormatter.FormatTrends(displayName, averages, baseline, team))
+}
+
+// whoopAlertsUsage explains the /whoop-alerts subcommands
+const whoopAlertsUsage = "*Available alerts:*\n" +
+	"• `recovery [percent]` - recovery drops below this (default 33%)\n" +
+	"• `hrv [percent]` - HRV stays this far below your 30-day average for 3 days (default 15%)\n" +
+	"• `sleep [hours]` - under this much sleep two nights in a row (default 6h)\n\n" +
+	"Turn one on with `/whoop-alerts on recovery 30`, off with `/whoop-alerts off recovery`, or everything off with `/whoop-alerts off all`."
+
+// handleWHOOPAlertsCommand handles the /whoop-alerts slash command
+func (h *SlackHandler) handleWHOOPAlertsCommand(cmd slack.SlashCommand) {
+	if h.whoopService == nil {
+		h.respondEphemeral(cmd, "WHOOP integration is not configured. Please contact your administrator.")
+		return
+	}
+
+	args := strings.Fields(strings.ToLower(cmd.Text))
+	if len(args) == 0 || args[0] == "list" {
+		rules, err := h.whoopService.GetAlertRules(cmd.UserID)
+		if err != nil {
+			log.Printf("Failed to get WHOOP alert rules for user %s: %v", cmd.UserID, err)
+			h.respondEphemeral(cmd, "❌ Failed to load your alerts. Please try again later.")
+			return
+		}
+
+		response := "🔔 *Your WHOOP alerts*\n\n"
+		if len(rules) == 0 {
+			response += "You haven't turned on any alerts yet. They're private and arrive as a DM after your data syncs.\n\n"
+		}
+		for _, rule := range rules {
+			response += fmt.Sprintf("• %s\n", describeAlertRule(rule))
+		}
+		if len(rules) > 0 {
+			response += "\n"
+		}
+		h.respondEphemeral(cmd, response+whoopAlertsUsage)
+		return
+	}
+
+	switch {
+	case args[0] == "on" && len(args) >= 2 && len(args) <= 3:
+		if _, err := h.whoopService.GetConnectionStatus(cmd.UserID); err != nil {
+			h.respondEphemeral(cmd, notConnectedMessage)
+			return
+		}
+
+		var threshold float64
+		if len(args) == 3 {
+			parsed, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSuffix(args[2], "%"), "h"), 64)
+			if err != nil || parsed <= 0 {
+				h.respondEphemeral(cmd, "Please give the threshold as a number, e.g. `/whoop-alerts on sleep 6.5`")
+				return
+			}
+			threshold = parsed
+		}
+
+		rule, err := h.whoopService.EnableAlert(cmd.UserID, args[1], threshold)
+		if err != nil {
+			h.respondEphemeral(cmd, fmt.Sprintf("❌ Couldn't turn that alert on: %v\n\n%s", err, whoopAlertsUsage))
+			return
+		}
+		h.respondEphemeral(cmd, fmt.Sprintf("🔔 Alert on! %s. I'll DM you a heads-up after your data syncs.", describeAlertRule(*rule)))
+
+	case args[0] == "off" && len(args) == 2:
+		rule := args[1]
+		if rule == "all" {
+			rule = ""
+		}
+		if err := h.whoopService.DisableAlert(cmd.UserID, rule); err != nil {
+			h.respondEphemeral(cmd, fmt.Sprintf("❌ Couldn't turn that alert off: %v\n\n%s", err, whoopAlertsUsage))
+			return
+		}
+		if rule == "" {
+			h.respondEphemeral(cmd, "🔕 All your WHOOP alerts are off.")
+			return
+		}
+		h.respondEphemeral(cmd, fmt.Sprintf("🔕 Your %s alert is off.", rule))
+
+	default:
+		h.respondEphemeral(cmd, whoopAlertsUsage)
+	}
+}
+
+// describeAlertRule renders an alert rule as a short sentence fragment
+func describeAlertRule(rule models.WHOOPAlertRule) string {
+	switch rule.Rule {
+	case models.AlertRuleRecovery:
+		return fmt.Sprintf("*Recovery* below %.0f%%", rule.Threshold)
+	case models.AlertRuleHRV:
+		return fmt.Sprintf("*HRV* %.0f%% below your 30-day average for 3 days", rule.Threshold)
+	case models.AlertRuleSleep:
+		return fmt.Sprintf("*Sleep* under %.1f hours two nights in a row", rule.Threshold)
+	default:
+		return rule.Rule
+	}
+}
+
+// whoopPrivacyLevels describes each standup privacy level, in display order
+var whoopPrivacyLevels = []struct{ level, description string }{
+	{models.PrivacyFull, "your scores, HRV and resting heart rate"},
+	{models.PrivacyColors, "just your recovery color and sleep emoji"},
+	{models.PrivacyParticipation, "only that you checked in"},
+	{models.PrivacyExcluded, "nothing; you're also left out of team averages"},
+}
+
+// handleWHOOPPrivacyCommand handles the /whoop-privacy slash command
+func (h *SlackHandler) handleWHOOPPrivacyCommand(cmd slack.SlashCommand) {
+	level := strings.ToLower(strings.TrimSpace(cmd.Text))
+
+	var usage strings.Builder
+	usage.WriteString("*Privacy levels:*\n")
+	for _, l := range whoopPrivacyLevels {
+		usage.WriteString(fmt.Sprintf("• `%s` - the team sees %s\n", l.level, l.description))
+	}
+	usage.WriteString("\nExample: `/whoop-privacy colors`")
+
+	if level == "" {
+		current := models.PrivacyFull
+		if privacy, err := h.db.GetWHOOPPrivacy(cmd.UserID); err == nil {
+			current = privacy.Level
+		}
+		h.respondEphemeral(cmd, fmt.Sprintf("🔒 Your morning standup privacy is `%s`.\n\n%s", current, usage.String()))
+		return
+	}
+
+	for _, l := range whoopPrivacyLevels {
+		if l.level != level {
+			continue
+		}
+		if err := h.db.SetWHOOPPrivacy(&models.WHOOPPrivacy{UserID: cmd.UserID, Level: level}); err != nil {
+			log.Printf("Failed to save WHOOP privacy for user %s: %v", cmd.UserID, err)
+			h.respondEphemeral(cmd, "❌ Failed to save your privacy level. Please try again later.")
+			return
+		}
+		h.respondEphemeral(cmd, fmt.Sprintf("🔒 Got it! From now on the team standup shows %s.", l.description))
+		return
+	}
+
+	h.respondEphemeral(cmd, fmt.Sprintf("Unknown privacy level `%s`.\n\n%s", level, usage.String()))
+}
+
+// whoopChallengeUsage explains the /whoop-challenge subcommands
+const whoopChallengeUsage = "*Challenge commands:*\n" +
+	"• `start sleep [days]` - highest average sleep\n" +
+	"• `start green [days]` - most days in the green recovery zone\n" +
+	"• `start strain [days] [goal]` - most days reaching a strain goal (default 10)\n" +
+	"• `join <id>` / `leave <id>` - opt in or out\n" +
+	"• `standings <id>` - see the leaderboard\n" +
+	"• `cancel <id>` - cancel a challenge you started\n\n" +
+	"Challenges run for 7 days unless you say otherwise, e.g. `/whoop-challenge start sleep 14`."
+
+// handleWHOOPChallengeCommand handles the /whoop-challenge slash command
+func (h *SlackHandler) handleWHOOPChallengeCommand(cmd slack.SlashCommand) {
+	if h.whoopService == nil {
+		h.respondToSlashCommand(cmd, "WHOOP integration is not configured. Please contact your administrator.")
+		return
+	}
+
+	args := strings.Fields(strings.ToLower(cmd.Text))
+	if len(args) == 0 || args[0] == "list" {
+		challenges, err := h.whoopService.GetActiveChallenges()
+		if err != nil {
+			log.Printf("Failed to get WHOOP challenges: %v", err)
+			h.respondEphemeral(cmd, "❌ Failed to load challenges. Please try again later.")
+			return
+		}
+
+		response := "🏁 *Active challenges*\n\n"
+		if len(challenges) == 0 {
+			response += "No challenges running right now. Start one!\n"
+		}
+		for i := range challenges {
+			standings, err := h.whoopService.GetChallengeStandings(&challenges[i])
+			if err != nil {
+				log.Printf("Failed to get standings for challenge %d: %v", challenges[i].ID, err)
+			}
+			response += "• " + h.whoopFormatter.FormatChallengeSummary(&challenges[i], len(standings)) + "\n"
+		}
+		h.respondEphemeral(cmd, response+"\n"+whoopChallengeUsage)
+		return
+	}
+
+	if args[0] == "start" {
+		h.startWHOOPChallenge(cmd, args[1:])
+		return
+	}
+
+	if len(args) != 2 {
+		h.respondEphemeral(cmd, whoopChallengeUsage)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+	if err != nil {
+		h.respondEphemeral(cmd, "Please give the challenge number, e.g. `/whoop-challenge join 3`")
+		return
+	}
+
+	switch args[0] {
+	case "join":
+		if _, err := h.whoopService.GetConnectionStatus(cmd.UserID); err != nil {
+			h.respondEphemeral(cmd, "❌ Connect a device first with `/connect-whoop` or `/connect-oura` so your progress can be tracked!")
+			return
+		}
+		challenge, err := h.whoopService.JoinChallenge(id, cmd.UserID)
+		if err != nil {
+			h.respondEphemeral(cmd, fmt.Sprintf("❌ Couldn't join challenge #%d: %v", id, err))
+			return
+		}
+		h.respondToSlashCommand(cmd, fmt.Sprintf("🙌 <@%s> joined challenge #%d! Good luck! 💪", cmd.UserID, challenge.ID))
+
+	case "leave":
+		left, err := h.whoopService.LeaveChallenge(id, cmd.UserID)
+		if err != nil {
+			h.respondEphemeral(cmd, fmt.Sprintf("❌ Couldn't leave challenge #%d: %v", id, err))
+			return
+		}
+		if !left {
+			h.respondEphemeral(cmd, fmt.Sprintf("You weren't part of challenge #%d.", id))
+			return
+		}
+		h.respondEphemeral(cmd, fmt.Sprintf("👋 You've left challenge #%d.", id))
+
+	case "standings":
+		challenge, err := h.whoopService.GetChallenge(id)
+		if err != nil {
+			h.respondEphemeral(cmd, fmt.Sprintf("❌ Challenge #%d not found.", id))
+			return
+		}
+		standings, err := h.whoopService.GetChallengeStandings(challenge)
+		if err != nil {
+			log.Printf("Failed to get standings for challenge %d: %v", id, err)
+			h.respondEphemeral(cmd, "❌ Failed to load the leaderboard. Please try again later.")
+			return
+		}
+		final := challenge.Status != models.ChallengeActive
+		h.respondToSlashCommand(cmd, h.whoopFormatter.FormatChallengeStandings(challenge, standings, final, time.Now()))
+
+	case "cancel":
+		if err := h.whoopService.CancelChallenge(id, cmd.UserID); err != nil {
+			h.respondEphemeral(cmd, fmt.Sprintf("❌ Couldn't cancel challenge #%d: %v", id, err))
+			return
+		}
+		h.respondToSlashCommand(cmd, fmt.Sprintf("🛑 Challenge #%d has been cancelled.", id))
+
+	default:
+		h.respondEphemeral(cmd, whoopChallengeUsage)
+	}
+}
+
+// startWHOOPChallenge handles /whoop-challenge start <type> [days] [goal]
+func (h *SlackHandler) startWHOOPChallenge(cmd slack.SlashCommand, args []string) {
+	if len(args) < 1 || len(args) > 3 {
+		h.respondEphemeral(cmd, whoopChallengeUsage)
+		return
+	}
+	if _, err := h.whoopService.GetConnectionStatus(cmd.UserID); err != nil {
+		h.respondEphemeral(cmd, "❌ Connect a device first with `/connect-whoop` or `/connect-oura` so your progress can be tracked!")
+		return
+	}
+
+	days := whoop.DefaultChallengeDays
+	if len(args) >= 2 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil {
+			h.respondEphemeral(cmd, fmt.Sprintf("Please give the length in days (1-%d), e.g. `/whoop-challenge start %s 7`", whoop.MaxChallengeDays, args[0]))
+			return
+		}
+		days = parsed
+	}
+
+	var goal float64
+	if len(args) == 3 {
+		parsed, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			h.respondEphemeral(cmd, "Please give the strain goal as a number, e.g. `/whoop-challenge start strain 7 12`")
+			return
+		}
+		goal = parsed
+	}
+
+	challenge, err := h.whoopService.CreateChallenge(cmd.UserID, args[0], days, goal)
+	if err != nil {
+		h.respondEphemeral(cmd, fmt.Sprintf("❌ Couldn't start the challenge: %v\n\n%s", err, whoopChallengeUsage))
+		return
+	}
+
+	h.respondToSlashCommand(cmd, fmt.Sprintf("🏁 <@%s> started a new challenge!\n%s\nRuns %s to %s. Progress is posted with the morning standup.\n\n_Join in with `/whoop-challenge join %d`_",
+		cmd.UserID, h.whoopFormatter.FormatChallengeSummary(challenge, 1), challenge.StartDate.Format("Mon Jan 2"), challenge.EndDate.Format("Mon Jan 2"), challenge.ID))
+}
+
+// SendChallengeUpdates posts daily progress for active challenges to the
+// standup channel, and the final leaderboard for challenges that have ended
+func (h *SlackHandler) SendChallengeUpdates() {
+	if h.whoopService == nil {
+		return
+	}
+
+	challenges, err := h.whoopService.GetActiveChallenges()
+	if err != nil {
+		log.Printf("Failed to get WHOOP challenges: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range challenges {
+		challenge := &challenges[i]
+		standings, err := h.whoopService.GetChallengeStandings(challenge)
+		if err != nil {
+			log.Printf("Failed to get standings for challenge %d: %v", challenge.ID, err)
+			continue
+		}
+
+		final := whoop.ChallengeOver(challenge, now)
+		h.sendMessage(h.standupChannel, h.whoopFormatter.FormatChallengeStandings(challenge, standings, final, now))
+		if final {
+			if err := h.whoopService.FinishChallenge(challenge.ID); err != nil {
+				log.Printf("Failed to finish challenge %d: %v", challenge.ID, err)
+			}
+		}
+	}
 }
 
 // handleMorningReportCommand handles the /morning-report slash command
@@ -773,11 +1357,15 @@ func (h *SlackHandler) handleMorningReportCommand(cmd slack.SlashCommand) {
 		return
 	}
 
-	// Sync all users' data first
-	if err := h.whoopService.SyncAllUsersData(); err != nil {
-		log.Printf("Failed to sync WHOOP data for morning report: %v", err)
-		h.respondToSlashCommand(cmd, "⚠️ Failed to sync WHOOP data. Showing last available data...")
-	}
+	// Render from cached data and refresh in the background, rather than
+	// making the command wait on every teammate's sync
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), whoopSyncTimeout)
+		defer cancel()
+		if _, err := h.whoopService.SyncAllUsersData(ctx); err != nil {
+			log.Printf("Failed to refresh WHOOP data after morning report: %v", err)
+		}
+	}()
 
 	// Get team data
 	teamData, err := h.db.GetTeamWHOOPDataForStandup()
@@ -791,27 +1379,189 @@ func (h *SlackHandler) handleMorningReportCommand(cmd slack.SlashCommand) {
 	h.respondToSlashCommand(cmd, message)
 }
 
-// handleDisconnectWHOOPCommand handles the /disconnect-whoop slash command
+// wellnessImportUsage explains how to import an exported file
+const wellnessImportUsage = "*Import sleep and recovery from an export file:*\n" +
+	"1. Upload the file to Slack, e.g. in a DM with me\n" +
+	"2. Run `/wellness-import` and I'll import your most recent upload\n\n" +
+	"*Supported files:*\n" +
+	"• Apple Health: the `export.zip` (or its `export.xml`) from Health → Profile → Export All Health Data\n" +
+	"• Fitbit and Google Fit CSV exports\n" +
+	"• A CSV with a header row using columns like " + wellness.CSVColumns + "\n\n" +
+	"_Files are parsed by FamBot itself; only daily sleep and recovery totals are kept._"
+
+// handleWellnessImportCommand handles the /wellness-import slash command,
+// importing the user's most recently uploaded export file
+func (h *SlackHandler) handleWellnessImportCommand(cmd slack.SlashCommand) {
+	if h.whoopService == nil {
+		h.respondEphemeral(cmd, "Wellness integration is not configured. Please contact your administrator.")
+		return
+	}
+	if strings.EqualFold(strings.TrimSpace(cmd.Text), "help") {
+		h.respondEphemeral(cmd, wellnessImportUsage)
+		return
+	}
+
+	if connection, err := h.whoopService.GetConnectionStatus(cmd.UserID); err == nil && connection.ProviderName() != models.ImportProvider {
+		h.respondEphemeral(cmd, fmt.Sprintf("🔗 Your %s already syncs automatically, so there's nothing to import. Use `/disconnect-whoop` first if you'd rather import files.",
+			providerDisplayName(connection.ProviderName())))
+		return
+	}
+
+	files, _, err := h.client.GetFiles(slack.GetFilesParameters{User: cmd.UserID, Count: 1})
+	if err != nil {
+		log.Printf("Failed to list files for user %s: %v", cmd.UserID, err)
+		h.respondEphemeral(cmd, "❌ Failed to find your upload. Please try again later.")
+		return
+	}
+	if len(files) == 0 {
+		h.respondEphemeral(cmd, "📂 I couldn't find a file you've uploaded.\n\n"+wellnessImportUsage)
+		return
+	}
+	file := files[0]
+	if file.Size > maxImportSize {
+		h.respondEphemeral(cmd, fmt.Sprintf("❌ `%s` is too large to import (limit %d MB).", file.Name, maxImportSize>>20))
+		return
+	}
+
+	h.respondEphemeral(cmd, fmt.Sprintf("⏳ Importing `%s`...", file.Name))
+
+	var content bytes.Buffer
+	if err := h.client.GetFile(file.URLPrivateDownload, &content); err != nil {
+		log.Printf("Failed to download file %s for user %s: %v", file.ID, cmd.UserID, err)
+		h.respondEphemeral(cmd, "❌ Failed to download your file. Please try again later.")
+		return
+	}
+
+	data, format, err := wellness.Parse(file.Name, content.Bytes())
+	if errors.Is(err, wellness.ErrUnsupportedFormat) || errors.Is(err, wellness.ErrNoData) || errors.Is(err, wellness.ErrTooLarge) {
+		h.respondEphemeral(cmd, fmt.Sprintf("❌ I couldn't import `%s`: %v\n\n%s", file.Name, err, wellnessImportUsage))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to parse %s for user %s: %v", file.Name, cmd.UserID, err)
+		h.respondEphemeral(cmd, fmt.Sprintf("❌ `%s` looks damaged or incomplete. Please export it again and retry.", file.Name))
+		return
+	}
+
+	// Record the user so they appear in standups
+	if _, err := h.directory.User(cmd.UserID); err != nil {
+		log.Printf("Error getting user info for %s: %v", cmd.UserID, err)
+	}
+
+	if err := h.whoopService.ImportData(cmd.UserID, data); err != nil {
+		log.Printf("Failed to import %s data for user %s: %v", format, cmd.UserID, err)
+		h.respondEphemeral(cmd, "❌ Failed to save your imported data. Please try again later.")
+		return
+	}
+
+	first, last := wellness.DateRange(data)
+	h.respondEphemeral(cmd, fmt.Sprintf("✅ Imported %d nights of sleep and %d recovery scores from your %s export (%s – %s).\n"+
+		"You'll show up in the morning standup! Run `/wellness-import` again whenever you have a newer export.",
+		len(data.Sleeps), len(data.Recoveries), format, first.Format("Jan 2"), last.Format("Jan 2, 2006")))
+}
+
+// deleteWellnessDataAction is the action ID of the "Delete my data" button
+// offered after /disconnect-whoop
+const deleteWellnessDataAction = "wellness_delete_data"
+
+// handleDisconnectWHOOPCommand handles the /disconnect-whoop [delete] slash
+// command, which disconnects whichever device the user has connected and
+// revokes FamBot's access with the provider. Stored data is kept for the
+// retention window unless the user asks for it to be deleted.
 func (h *SlackHandler) handleDisconnectWHOOPCommand(cmd slack.SlashCommand) {
 	if h.whoopService == nil {
-		h.respondToSlashCommand(cmd, "WHOOP integration is not configured. Please contact your administrator.")
+		h.respondEphemeral(cmd, "Wearables integration is not configured. Please contact your administrator.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), whoopSyncTimeout)
+	defer cancel()
+
+	if strings.EqualFold(strings.TrimSpace(cmd.Text), "delete") {
+		h.respondEphemeral(cmd, h.deleteWellnessData(ctx, cmd.UserID))
 		return
 	}
 
 	// Check if user is connected
-	_, err := h.whoopService.GetConnectionStatus(cmd.UserID)
+	connection, err := h.whoopService.GetConnectionStatus(cmd.UserID)
 	if err != nil {
-		h.respondToSlashCommand(cmd, "❌ You're not connected to WHOOP. Nothing to disconnect!")
+		h.respondEphemeral(cmd, "❌ You don't have a device connected. Nothing to disconnect!\n"+
+			"Use `/disconnect-whoop delete` to delete any data FamBot still has stored for you.")
 		return
 	}
+	device := providerDisplayName(connection.ProviderName())
 
 	// Disconnect user
-	if err := h.whoopService.DisconnectUser(cmd.UserID); err != nil {
-		h.respondToSlashCommand(cmd, "❌ Failed to disconnect your WHOOP account. Please try again later.")
+	if err := h.whoopService.DisconnectUser(ctx, cmd.UserID); err != nil {
+		log.Printf("Failed to disconnect %s for user %s: %v", device, cmd.UserID, err)
+		h.respondEphemeral(cmd, fmt.Sprintf("❌ Failed to disconnect your %s account. Please try again later.", device))
 		return
 	}
 
-	h.respondToSlashCommand(cmd, "✅ Successfully disconnected from WHOOP. Use `/connect-whoop` if you want to reconnect later!")
+	reconnect := "/connect-" + connection.ProviderName()
+	if connection.ProviderName() == models.ImportProvider {
+		reconnect = "/wellness-import"
+	}
+	text := fmt.Sprintf("✅ Successfully disconnected from %s and revoked FamBot's access. Use `%s` if you want to reconnect later!\n\n"+
+		"Your past sleep and recovery data is still stored. Want it gone too?", device, reconnect)
+
+	deleteButton := slack.NewButtonBlockElement(deleteWellnessDataAction, "delete",
+		slack.NewTextBlockObject(slack.PlainTextType, "🗑️ Delete my data", true, false))
+	deleteButton.Style = slack.StyleDanger
+	deleteButton.Confirm = slack.NewConfirmationBlockObject(
+		slack.NewTextBlockObject(slack.PlainTextType, "Delete your data?", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "This permanently deletes your stored sleep, recovery and strain history, alerts, privacy setting and challenge entries.", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "Delete", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "Keep it", false, false),
+	)
+
+	_, err = h.client.PostEphemeral(cmd.ChannelID, cmd.UserID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+			slack.NewActionBlock("", deleteButton),
+		))
+	if err != nil {
+		log.Printf("Error responding to slash command: %v", err)
+	}
+}
+
+// deleteWellnessData deletes a user's stored wellness data and returns the
+// message to show them
+func (h *SlackHandler) deleteWellnessData(ctx context.Context, userID string) string {
+	if err := h.whoopService.DeleteUserData(ctx, userID); err != nil {
+		log.Printf("Failed to delete wellness data for user %s: %v", userID, err)
+		return "❌ Failed to delete your data. Please try again later."
+	}
+	return "🗑️ Done! All of your sleep, recovery and strain data, alerts, privacy setting and challenge entries have been deleted."
+}
+
+// handleInteraction handles Block Kit interactions such as button clicks
+func (h *SlackHandler) handleInteraction(callback slack.InteractionCallback) {
+	metrics.EventsHandled.Inc(string(socketmode.EventTypeInteractive))
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		switch action.ActionID {
+		case deleteWellnessDataAction:
+			if h.whoopService == nil {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), whoopSyncTimeout)
+			text := h.deleteWellnessData(ctx, callback.User.ID)
+			cancel()
+
+			// Replace the ephemeral prompt so the button can't be clicked twice
+			_, _, err := h.client.PostMessage(callback.Channel.ID,
+				slack.MsgOptionText(text, false),
+				slack.MsgOptionReplaceOriginal(callback.ResponseURL))
+			if err != nil {
+				log.Printf("Error responding to interaction: %v", err)
+			}
+		}
+	}
 }
 
 // SendMorningStandup sends the morning standup message to the configured channel
@@ -822,10 +1572,18 @@ func (h *SlackHandler) SendMorningStandup() {
 	}
 
 	// Sync all users' data first
-	if err := h.whoopService.SyncAllUsersData(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), whoopSyncTimeout)
+	defer cancel()
+	result, err := h.whoopService.SyncAllUsersData(ctx)
+	if err != nil {
 		log.Printf("Failed to sync WHOOP data for morning standup: %v", err)
+	} else if len(result.Failed) > 0 || len(result.Skipped) > 0 {
+		log.Printf("Morning standup will use cached data for some users: %s", result)
 	}
 
+	// Challenge progress follows the standup, even on days it's skipped
+	defer h.SendChallengeUpdates()
+
 	// Get team data
 	teamData, err := h.db.GetTeamWHOOPDataForStandup()
 	if err != nil {
@@ -0,0 +1,114 @@
+// Package health serves liveness, readiness and metrics endpoints for
+// systemd, Docker and Prometheus probes
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
+)
+
+// checkTimeout bounds each readiness check so a hung dependency fails the
+// probe instead of stalling it
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable; a nil error means ready
+type Check func(ctx context.Context) error
+
+// namedCheck is a readiness check and the name it is reported under
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Server serves /healthz, /readyz and /metrics
+type Server struct {
+	mu     sync.Mutex
+	checks []namedCheck
+	server *http.Server
+}
+
+// NewServer creates a probe server listening on addr
+func NewServer(addr string) *Server {
+	s := &Server{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.Handle("/metrics", metrics.Handler())
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	return s
+}
+
+// AddCheck adds a readiness check reported under name
+func (s *Server) AddCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+}
+
+// Start serves until Shutdown is called, returning nil after a clean shutdown
+func (s *Server) Start() error {
+	log.Printf("Starting health and metrics server on %s", s.server.Addr)
+	err := s.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops the server, waiting for in-flight probes to finish or ctx
+// to expire
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// handleHealthz reports that the process is up and serving
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz runs every readiness check and lists their results, answering
+// 503 if any failed
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	checks := append([]namedCheck(nil), s.checks...)
+	s.mu.Unlock()
+
+	ready := true
+	var report strings.Builder
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		err := c.check(ctx)
+		cancel()
+
+		if err != nil {
+			ready = false
+			fmt.Fprintf(&report, "%s: %v\n", c.name, err)
+		} else {
+			fmt.Fprintf(&report, "%s: ok\n", c.name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(w, report.String())
+}
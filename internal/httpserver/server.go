@@ -0,0 +1,85 @@
+// Package httpserver runs FamBot's public HTTP server, which carries wearable
+// OAuth callbacks, WHOOP webhooks and, in HTTP mode, Slack requests
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Config configures the server. TLS is served when both TLSCertFile and
+// TLSKeyFile are set.
+type Config struct {
+	Addr         string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	TLSCertFile  string
+	TLSKeyFile   string
+}
+
+// Server is an http.Server with its own mux and hardened defaults
+type Server struct {
+	config Config
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// New creates a server; register routes on Mux before calling Start
+func New(config Config) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		config: config,
+		mux:    mux,
+		server: &http.Server{
+			Addr:              config.Addr,
+			Handler:           secureHeaders(mux),
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+		},
+	}
+}
+
+// Mux returns the mux routes are registered on
+func (s *Server) Mux() *http.ServeMux {
+	return s.mux
+}
+
+// Start serves until Shutdown is called, returning nil after a clean shutdown
+func (s *Server) Start() error {
+	var err error
+	if s.config.TLSCertFile != "" && s.config.TLSKeyFile != "" {
+		log.Printf("Starting HTTP server on %s (TLS)", s.config.Addr)
+		err = s.server.ListenAndServeTLS(s.config.TLSCertFile, s.config.TLSKeyFile)
+	} else {
+		log.Printf("Starting HTTP server on %s", s.config.Addr)
+		err = s.server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish or ctx to expire
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// secureHeaders stops browsers from caching responses or leaking OAuth
+// authorization codes in the Referer header
+func secureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "DENY")
+		next.ServeHTTP(w, r)
+	})
+}
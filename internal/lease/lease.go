@@ -0,0 +1,136 @@
+// Package lease elects a single leader among FamBot instances sharing a
+// database, so work such as scheduled jobs runs on only one of them
+package lease
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
+)
+
+// releaseTimeout bounds giving up the lease on shutdown
+const releaseTimeout = 5 * time.Second
+
+// Locker grants named, expiring leases. database.Database implements it with
+// a SQLite row; other backends such as Postgres advisory locks can implement
+// it too.
+type Locker interface {
+	// AcquireLease takes or renews the lease for holder until ttl from now,
+	// reporting whether holder has it
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// ReleaseLease gives up the lease if holder has it
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// Elector keeps trying to take a lease and renews it while held. The lease
+// is renewed every third of its ttl, and leadership is given up if it can't
+// be renewed for half of it, well before another instance could take it.
+type Elector struct {
+	locker Locker
+	name   string
+	holder string
+	ttl    time.Duration
+
+	leader     atomic.Bool
+	onElected  func()
+	lastRenew  time.Time
+	renewLimit time.Duration
+}
+
+// NewElector creates an elector for the named lease, held as holder
+func NewElector(locker Locker, name, holder string, ttl time.Duration) *Elector {
+	return &Elector{
+		locker:     locker,
+		name:       name,
+		holder:     holder,
+		ttl:        ttl,
+		renewLimit: ttl / 2,
+	}
+}
+
+// OnElected sets a function called when this instance becomes leader
+func (e *Elector) OnElected(fn func()) {
+	e.onElected = fn
+}
+
+// IsLeader reports whether this instance holds the lease
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns for the lease until ctx is done, then releases it
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		e.campaign(ctx)
+
+		select {
+		case <-ctx.Done():
+			if e.IsLeader() {
+				e.setLeader(false)
+				releaseCtx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+				if err := e.locker.ReleaseLease(releaseCtx, e.name, e.holder); err != nil {
+					log.Printf("Failed to release %s lease: %v", e.name, err)
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign makes one attempt to take or renew the lease
+func (e *Elector) campaign(ctx context.Context) {
+	held, err := e.locker.AcquireLease(ctx, e.name, e.holder, e.ttl)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Failed to renew %s lease: %v", e.name, err)
+		// Keep leading through a brief outage, but stop before the lease
+		// could expire and pass to someone else
+		if e.IsLeader() && time.Since(e.lastRenew) > e.renewLimit {
+			e.setLeader(false)
+		}
+		return
+	}
+
+	if held {
+		e.lastRenew = time.Now()
+	}
+	if held != e.IsLeader() {
+		e.setLeader(held)
+	}
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.leader.Store(leader)
+	if leader {
+		log.Printf("Became leader for %s as %s", e.name, e.holder)
+		metrics.Leader.Set(1, e.name)
+		if e.onElected != nil {
+			e.onElected()
+		}
+		return
+	}
+
+	log.Printf("No longer leader for %s", e.name)
+	metrics.Leader.Set(0, e.name)
+}
+
+// DefaultHolder identifies this process among instances, as hostname:pid
+func DefaultHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
@@ -0,0 +1,278 @@
+// Package metrics keeps FamBot's counters and latencies in memory and serves
+// them in the Prometheus text exposition format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FamBot's metrics. Label values must come from a small fixed set (event
+// types, command names, job names) so the number of series stays bounded.
+var (
+	EventsHandled = NewCounter("fambot_events_handled_total",
+		"Slack events handled, by event type.", "type")
+	KarmaGiven = NewCounter("fambot_karma_given_total",
+		"Karma points given.")
+	SlashCommands = NewCounter("fambot_slash_commands_total",
+		"Slash commands handled, by command.", "command")
+	APIRequests = NewHistogram("fambot_api_request_duration_seconds",
+		"Outbound API request latency, by service and endpoint.", defaultBuckets, "service", "endpoint")
+	APIErrors = NewCounter("fambot_api_errors_total",
+		"Outbound API requests that failed or returned an HTTP error status, by service and endpoint.", "service", "endpoint")
+	WorkerPanics = NewCounter("fambot_worker_panics_total",
+		"Event handling tasks that panicked.")
+	OutboundMessages = NewCounter("fambot_outbound_messages_total",
+		"Outbound Slack message attempts, by outcome.", "outcome")
+	OutboundQueueDepth = NewGauge("fambot_outbound_queue_depth",
+		"Slack messages waiting to be posted.")
+	Leader = NewGauge("fambot_leader",
+		"1 while this instance holds the lease, by lease name.", "lease")
+	CronRuns = NewCounter("fambot_cron_runs_total",
+		"Scheduled job runs, by job and outcome.", "job", "outcome")
+	CronLastRun = NewGauge("fambot_cron_last_run_timestamp_seconds",
+		"Unix time the job last finished, by job.", "job")
+)
+
+// defaultBuckets suit HTTP calls to Slack and wearable APIs, in seconds
+var defaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// collector is a metric family that can write itself out
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+}
+
+// Handler serves every registered metric
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
+
+// WriteTo writes every registered metric, sorted by name
+func WriteTo(w io.Writer) {
+	registryMu.Lock()
+	collectors := append([]collector(nil), registry...)
+	registryMu.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// family holds the series of one metric, keyed by their joined label values
+type family struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string][]string // key -> label values
+}
+
+func newFamily(name, help, kind string, labels []string) family {
+	return family{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		series:     make(map[string][]string),
+	}
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// key validates the label values and returns the series key, remembering
+// the values for output. The caller must hold f.mu.
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.metricName, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	if _, ok := f.series[key]; !ok {
+		f.series[key] = append([]string(nil), values...)
+	}
+	return key
+}
+
+// sortedKeys returns the series keys in a stable order. The caller must hold f.mu.
+func (f *family) sortedKeys() []string {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *family) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, f.help, f.metricName, f.kind)
+}
+
+// labelString formats label pairs, plus an optional extra pair such as a
+// histogram's le, as {a="x",b="y"}
+func labelString(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing value per label set
+type Counter struct {
+	family
+	values map[string]float64
+}
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, "counter", labels), values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+// Inc adds one to the series for labelValues
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series for labelValues
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[c.key(labelValues)] += v
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w)
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, labelString(c.labels, c.series[key]), formatFloat(c.values[key]))
+	}
+}
+
+// Gauge is a value per label set that can go up and down
+type Gauge struct {
+	family
+	values map[string]float64
+}
+
+// NewGauge registers a gauge with the given label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, "gauge", labels), values: make(map[string]float64)}
+	register(g)
+	return g
+}
+
+// Set sets the series for labelValues to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[g.key(labelValues)] = v
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(w)
+	for _, key := range g.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, labelString(g.labels, g.series[key]), formatFloat(g.values[key]))
+	}
+}
+
+// Histogram counts observations into cumulative buckets per label set
+type Histogram struct {
+	family
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds,
+// which must be sorted, and label names
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		family:  newFamily(name, help, "histogram", labels),
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+	register(h)
+	return h
+}
+
+// Observe records v in the series for labelValues
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := h.key(labelValues)
+	value := h.values[key]
+	if value == nil {
+		value = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = value
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		value.counts[i]++
+	}
+	value.count++
+	value.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range h.sortedKeys() {
+		labels, value := h.series[key], h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += value.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labelString(h.labels, labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labelString(h.labels, labels, "le", "+Inf"), value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labelString(h.labels, labels), formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labelString(h.labels, labels), value.count)
+	}
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Transport records latency and errors for every request sent to service,
+// then hands the request to next (http.DefaultTransport if nil)
+type Transport struct {
+	service string
+	next    http.RoundTripper
+}
+
+// NewTransport wraps next so requests to service are measured
+func NewTransport(service string, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{service: service, next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointLabel(req.URL.Path)
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	APIRequests.Observe(time.Since(start).Seconds(), t.service, endpoint)
+	if err != nil || resp.StatusCode >= 400 {
+		APIErrors.Inc(t.service, endpoint)
+	}
+	return resp, err
+}
+
+// endpointLabel turns a URL path into a low-cardinality label by replacing
+// record IDs with ":id", so /v1/activity/sleep/5f0c... becomes
+// /v1/activity/sleep/:id. Slack method paths like /api/chat.postMessage are
+// kept as they are.
+func endpointLabel(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if isID(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// isID reports whether a path segment looks like a numeric ID or a UUID
+// rather than part of the route. Short segments with digits, like "v1" or
+// "oauth2", are route names.
+func isID(segment string) bool {
+	digits := 0
+	for _, r := range segment {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+	return digits > 0 && (digits == len(segment) || len(segment) >= 16)
+}
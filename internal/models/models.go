@@ -6,10 +6,44 @@ import (
 
 // User represents a Slack user
 type User struct {
-	ID       string `db:"id"`
-	Username string `db:"username"`
-	RealName string `db:"real_name"`
-	Email    string `db:"email"`
+	ID       string    `db:"id"`
+	Username string    `db:"username"`
+	RealName string    `db:"real_name"`
+	Email    string    `db:"email"`
+	Timezone string    `db:"timezone"`  // IANA name from the Slack profile, e.g. "America/New_York"
+	SyncedAt time.Time `db:"synced_at"` // when the profile was last fetched from Slack
+}
+
+// Channel is a cached Slack channel
+type Channel struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	IsPrivate bool      `db:"is_private"`
+	SyncedAt  time.Time `db:"synced_at"`
+}
+
+// OutboundMessage is a Slack message waiting to be posted
+type OutboundMessage struct {
+	ID            int64     `db:"id"`
+	Channel       string    `db:"channel"`
+	ThreadTS      string    `db:"thread_ts"` // empty for a top-level message
+	Text          string    `db:"text"`
+	Attempts      int       `db:"attempts"`
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+	LastError     string    `db:"last_error"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// JobRun records one run of a scheduled job
+type JobRun struct {
+	ID           int64     `db:"id"`
+	Job          string    `db:"job"`
+	ScheduledFor time.Time `db:"scheduled_for"` // zero for manual runs
+	Trigger      string    `db:"trigger"`       // "schedule", "catchup" or "manual"
+	StartedAt    time.Time `db:"started_at"`
+	FinishedAt   time.Time `db:"finished_at"` // zero while running
+	Outcome      string    `db:"outcome"`
+	Error        string    `db:"error"`
 }
 
 // Karma represents a user's karma score
@@ -62,11 +96,13 @@ type SassyResponse struct {
 	Active   bool   `db:"active"`
 }
 
-// WHOOPConnection represents a user's WHOOP API connection
+// WHOOPConnection represents a user's wearable device connection. The name
+// predates other providers; WHOOPUserID holds the provider-side user ID.
 type WHOOPConnection struct {
 	ID           int       `db:"id"`
 	UserID       string    `db:"user_id"`       // Slack user ID
-	WHOOPUserID  string    `db:"whoop_user_id"` // WHOOP user ID
+	Provider     string    `db:"provider"`      // e.g. "whoop" or "oura"
+	WHOOPUserID  string    `db:"whoop_user_id"` // Provider user ID
 	AccessToken  string    `db:"access_token"`
 	RefreshToken string    `db:"refresh_token"`
 	ExpiresAt    time.Time `db:"expires_at"`
@@ -74,12 +110,35 @@ type WHOOPConnection struct {
 	Active       bool      `db:"active"`
 }
 
+// ImportProvider marks connections for users who share data by importing
+// exported files rather than through a device API
+const ImportProvider = "import"
+
+// ProviderName returns the connection's provider, defaulting to WHOOP for
+// connections made before other providers existed
+func (c *WHOOPConnection) ProviderName() string {
+	if c.Provider == "" {
+		return "whoop"
+	}
+	return c.Provider
+}
+
+// WHOOP score states. Only SCORED records carry meaningful scores; WHOOP may
+// still score a PENDING_SCORE record later, while UNSCORABLE is final.
+const (
+	ScoreStateScored     = "SCORED"
+	ScoreStatePending    = "PENDING_SCORE"
+	ScoreStateUnscorable = "UNSCORABLE"
+)
+
 // WHOOPRecovery represents daily recovery data from WHOOP
 type WHOOPRecovery struct {
 	ID          int       `db:"id"`
-	UserID      string    `db:"user_id"`      // Slack user ID
+	UserID      string    `db:"user_id"`       // Slack user ID
 	WHOOPUserID string    `db:"whoop_user_id"` // WHOOP user ID
-	Date        time.Time `db:"date"`          // Date of the recovery data
+	Date        time.Time `db:"date"`          // User's local calendar date of the recovery
+	ScoreState  string    `db:"score_state"`   // SCORED, PENDING_SCORE or UNSCORABLE
+	Calibrating bool      `db:"calibrating"`   // WHOOP is still learning the user's baselines
 	Score       int       `db:"score"`         // Recovery score (0-100)
 	HRV         float64   `db:"hrv"`           // Heart Rate Variability (ms)
 	RHR         int       `db:"rhr"`           // Resting Heart Rate (bpm)
@@ -88,26 +147,179 @@ type WHOOPRecovery struct {
 
 // WHOOPSleep represents daily sleep data from WHOOP
 type WHOOPSleep struct {
-	ID             int       `db:"id"`
-	UserID         string    `db:"user_id"`         // Slack user ID
-	WHOOPUserID    string    `db:"whoop_user_id"`   // WHOOP user ID
-	Date           time.Time `db:"date"`            // Date of sleep
-	DurationMS     int       `db:"duration_ms"`     // Total sleep duration in milliseconds
-	Efficiency     float64   `db:"efficiency"`      // Sleep efficiency percentage (0-100)
-	Score          int       `db:"score"`           // Sleep score (0-100)
-	StagesDeepMS   int       `db:"stages_deep_ms"`  // Deep sleep in milliseconds
-	StagesREMS     int       `db:"stages_rem_ms"`   // REM sleep in milliseconds
-	StagesLightMS  int       `db:"stages_light_ms"` // Light sleep in milliseconds
-	StagesWakeMS   int       `db:"stages_wake_ms"`  // Wake time in milliseconds
-	CreatedAt      time.Time `db:"created_at"`
+	ID            int       `db:"id"`
+	UserID        string    `db:"user_id"`         // Slack user ID
+	WHOOPUserID   string    `db:"whoop_user_id"`   // WHOOP user ID
+	Date          time.Time `db:"date"`            // User's local calendar date the sleep ended
+	ScoreState    string    `db:"score_state"`     // SCORED, PENDING_SCORE or UNSCORABLE
+	DurationMS    int       `db:"duration_ms"`     // Total sleep duration in milliseconds
+	Efficiency    float64   `db:"efficiency"`      // Sleep efficiency percentage (0-100)
+	Score         int       `db:"score"`           // Sleep score (0-100)
+	StagesDeepMS  int       `db:"stages_deep_ms"`  // Deep sleep in milliseconds
+	StagesREMS    int       `db:"stages_rem_ms"`   // REM sleep in milliseconds
+	StagesLightMS int       `db:"stages_light_ms"` // Light sleep in milliseconds
+	StagesWakeMS  int       `db:"stages_wake_ms"`  // Wake time in milliseconds
+	CreatedAt     time.Time `db:"created_at"`
 }
 
 // WHOOPStrain represents daily strain data from WHOOP
 type WHOOPStrain struct {
 	ID          int       `db:"id"`
-	UserID      string    `db:"user_id"`      // Slack user ID
+	UserID      string    `db:"user_id"`       // Slack user ID
 	WHOOPUserID string    `db:"whoop_user_id"` // WHOOP user ID
-	Date        time.Time `db:"date"`          // Date of strain
+	Date        time.Time `db:"date"`          // User's local calendar date the cycle started
+	ScoreState  string    `db:"score_state"`   // SCORED, PENDING_SCORE or UNSCORABLE
 	Score       float64   `db:"score"`         // Strain score (0-21)
 	CreatedAt   time.Time `db:"created_at"`
 }
+
+// Scored reports whether WHOOP has produced a score for the recovery.
+// Rows stored before score states were tracked have an empty state and were
+// always scored.
+func (r *WHOOPRecovery) Scored() bool {
+	return r.ScoreState == "" || r.ScoreState == ScoreStateScored
+}
+
+// Scored reports whether WHOOP has produced a score for the sleep
+func (s *WHOOPSleep) Scored() bool {
+	return s.ScoreState == "" || s.ScoreState == ScoreStateScored
+}
+
+// HasScore reports whether the sleep carries a sleep score. Sleep imported
+// from exported files may only have a duration.
+func (s *WHOOPSleep) HasScore() bool {
+	return s.Scored() && s.Score > 0
+}
+
+// Scored reports whether WHOOP has produced a score for the strain
+func (s *WHOOPStrain) Scored() bool {
+	return s.ScoreState == "" || s.ScoreState == ScoreStateScored
+}
+
+// Wellness alert rules a user can opt into with /whoop-alerts
+const (
+	AlertRuleRecovery = "recovery" // latest recovery below Threshold percent
+	AlertRuleHRV      = "hrv"      // HRV Threshold percent below baseline for 3 days running
+	AlertRuleSleep    = "sleep"    // under Threshold hours of sleep two nights in a row
+)
+
+// WHOOPAlertRule is a wellness alert a user has opted into
+type WHOOPAlertRule struct {
+	ID          int       `db:"id"`
+	UserID      string    `db:"user_id"`      // Slack user ID
+	Rule        string    `db:"rule"`         // One of the AlertRule constants
+	Threshold   float64   `db:"threshold"`    // Meaning depends on Rule
+	LastAlerted time.Time `db:"last_alerted"` // Date of the record that last triggered the alert
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// Team challenge metrics
+const (
+	ChallengeSleep  = "sleep"  // highest average sleep hours
+	ChallengeGreen  = "green"  // most days in the green recovery zone
+	ChallengeStrain = "strain" // most days reaching the strain goal
+)
+
+// Team challenge statuses
+const (
+	ChallengeActive    = "active"
+	ChallengeFinished  = "finished"
+	ChallengeCancelled = "cancelled"
+)
+
+// WHOOPChallenge is a time-boxed team wellness challenge
+type WHOOPChallenge struct {
+	ID        int       `db:"id"`
+	Metric    string    `db:"metric"`     // One of the Challenge metric constants
+	Goal      float64   `db:"goal"`       // Daily strain target for strain challenges
+	CreatedBy string    `db:"created_by"` // Slack user ID
+	StartDate time.Time `db:"start_date"` // First calendar day counted
+	EndDate   time.Time `db:"end_date"`   // Last calendar day counted
+	Status    string    `db:"status"`     // One of the Challenge status constants
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ChallengeStanding is a participant's progress in a challenge
+type ChallengeStanding struct {
+	UserID   string
+	Username string
+	RealName string
+	Score    float64 // Average hours for sleep challenges, otherwise qualifying days
+	Days     int     // Days with scored data in the challenge window
+}
+
+// DisplayName returns the real name when set, otherwise the username
+func (s ChallengeStanding) DisplayName() string {
+	if s.RealName != "" {
+		return s.RealName
+	}
+	return s.Username
+}
+
+// WHOOPAverages are mean values over a window of days, computed from scored
+// records only. A zero day count means there was no data for that metric.
+type WHOOPAverages struct {
+	Days         int     // Length of the window in days
+	Recovery     float64 // Recovery score (0-100)
+	HRV          float64 // Heart Rate Variability (ms)
+	RHR          float64 // Resting Heart Rate (bpm)
+	SleepHours   float64 // Sleep duration in hours
+	Strain       float64 // Strain score (0-21)
+	RecoveryDays int
+	SleepDays    int
+	StrainDays   int
+	// Users contributing to each average; 1 for a single user's averages
+	RecoveryUsers int
+	SleepUsers    int
+	StrainUsers   int
+}
+
+// UserSnapshot holds a user's most recent WHOOP records. A nil record means
+// nothing has been synced for it yet.
+type UserSnapshot struct {
+	Recovery *WHOOPRecovery
+	Sleep    *WHOOPSleep
+	Strain   *WHOOPStrain
+}
+
+// HasData reports whether any WHOOP record is available
+func (s UserSnapshot) HasData() bool {
+	return s.Recovery != nil || s.Sleep != nil || s.Strain != nil
+}
+
+// Calibrating reports whether WHOOP is still learning the user's baselines
+func (s UserSnapshot) Calibrating() bool {
+	return s.Recovery != nil && s.Recovery.Calibrating
+}
+
+// Privacy levels controlling what the team standup shows about a user
+const (
+	PrivacyFull          = "full"          // scores and metrics
+	PrivacyColors        = "colors"        // recovery/sleep emoji only
+	PrivacyParticipation = "participation" // only that they checked in
+	PrivacyExcluded      = "excluded"      // left out of the standup and team averages
+)
+
+// WHOOPPrivacy is a user's chosen standup privacy level
+type WHOOPPrivacy struct {
+	UserID    string    `db:"user_id"` // Slack user ID
+	Level     string    `db:"level"`   // One of the Privacy constants
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// StandupEntry is one connected team member's row in the morning standup
+type StandupEntry struct {
+	UserID   string
+	Username string
+	RealName string
+	Privacy  string // One of the Privacy constants
+	UserSnapshot
+}
+
+// DisplayName returns the real name when set, otherwise the username
+func (e StandupEntry) DisplayName() string {
+	if e.RealName != "" {
+		return e.RealName
+	}
+	return e.Username
+}
@@ -0,0 +1,349 @@
+// Package oura implements the wearables provider for the Oura Ring API
+package oura
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
+)
+
+const (
+	BaseURL         = "https://api.ouraring.com"
+	AuthURL         = "https://cloud.ouraring.com/oauth/authorize"
+	TokenURL        = "https://api.ouraring.com/oauth/token"
+	RevokeURL       = "https://api.ouraring.com/oauth/revoke"
+	PersonalInfoURL = "/v2/usercollection/personal_info"
+	ReadinessURL    = "/v2/usercollection/daily_readiness"
+	DailySleepURL   = "/v2/usercollection/daily_sleep"
+	SleepURL        = "/v2/usercollection/sleep"
+)
+
+// Client represents an Oura API client
+type Client struct {
+	httpClient   *http.Client
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	baseURL      string
+	tokenURL     string
+	revokeURL    string
+}
+
+// NewClient creates a new Oura API client
+func NewClient(clientID, clientSecret, redirectURL string) *Client {
+	return &Client{
+		httpClient:   &http.Client{Timeout: 30 * time.Second, Transport: metrics.NewTransport(ProviderName, nil)},
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		baseURL:      BaseURL,
+		tokenURL:     TokenURL,
+		revokeURL:    RevokeURL,
+	}
+}
+
+// SetEndpoints points the client at a different Oura deployment, such as a
+// local fake server
+func (c *Client) SetEndpoints(baseURL, tokenURL string) {
+	c.baseURL = baseURL
+	c.tokenURL = tokenURL
+}
+
+// GetAuthURL returns the Oura OAuth authorization URL
+func (c *Client) GetAuthURL(state string) string {
+	params := url.Values{
+		"client_id":     {c.clientID},
+		"redirect_uri":  {c.redirectURL},
+		"response_type": {"code"},
+		"scope":         {"personal daily"},
+		"state":         {state},
+	}
+	return fmt.Sprintf("%s?%s", AuthURL, params.Encode())
+}
+
+// TokenResponse represents the Oura OAuth token response
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ExchangeCodeForToken exchanges an authorization code for tokens
+func (c *Client) ExchangeCodeForToken(ctx context.Context, code string) (*TokenResponse, error) {
+	tokenResp, err := c.postToken(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+		"code":          {code},
+		"redirect_uri":  {c.redirectURL},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	return tokenResp, nil
+}
+
+// RefreshAccessToken refreshes an expired access token. Oura rotates the
+// refresh token, so the returned one replaces the old.
+func (c *Client) RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	tokenResp, err := c.postToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("token refresh failed: %w", err)
+	}
+	return tokenResp, nil
+}
+
+// postToken sends a form to the OAuth token endpoint
+func (c *Client) postToken(ctx context.Context, data url.Values) (*TokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseTokenError(resp)
+	}
+
+	var tokenResp TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	return &tokenResp, nil
+}
+
+// RevokeAccess revokes the access token's grant, so Oura stops sharing the
+// user's data with FamBot
+func (c *Client) RevokeAccess(ctx context.Context, accessToken string) error {
+	endpoint := c.revokeURL + "?" + url.Values{"access_token": {accessToken}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("token revoke failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Endpoint: "GET /oauth/revoke"}
+	}
+	return nil
+}
+
+// TokenError is returned when the Oura token endpoint rejects a request
+type TokenError struct {
+	StatusCode  int
+	Code        string // OAuth error code, e.g. "invalid_grant"
+	Description string
+}
+
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += fmt.Sprintf(" (%s)", e.Description)
+	}
+	return msg
+}
+
+// Is lets callers match revoked grants against wearables.ErrTokenRevoked and
+// rejected client credentials against wearables.ErrClientRejected
+func (e *TokenError) Is(target error) bool {
+	switch target {
+	case wearables.ErrTokenRevoked:
+		return e.Code == "invalid_grant" || e.Code == "invalid_token"
+	case wearables.ErrClientRejected:
+		return e.Code == "invalid_client" || e.Code == "unauthorized_client"
+	}
+	return false
+}
+
+// parseTokenError builds a TokenError from an OAuth error response without
+// keeping the raw body, which may contain token material
+func parseTokenError(resp *http.Response) *TokenError {
+	var oauthErr struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&oauthErr)
+
+	return &TokenError{
+		StatusCode:  resp.StatusCode,
+		Code:        oauthErr.Error,
+		Description: oauthErr.Description,
+	}
+}
+
+// APIError is returned for non-2xx responses from the Oura API
+type APIError struct {
+	StatusCode int
+	Endpoint   string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Endpoint, e.StatusCode, e.Body)
+}
+
+// Is lets callers match API errors against wearables.ErrUnauthorized and
+// wearables.ErrRateLimited
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case wearables.ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case wearables.ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// getJSON performs an authenticated GET against the Oura API and decodes
+// the JSON response into out
+func (c *Client) getJSON(ctx context.Context, accessToken, path string, params url.Values, out interface{}) error {
+	endpoint := c.baseURL + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return &APIError{StatusCode: resp.StatusCode, Endpoint: "GET " + path, Body: string(body)}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// PersonalInfo represents the Oura user's profile
+type PersonalInfo struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+// GetPersonalInfo fetches the user's profile
+func (c *Client) GetPersonalInfo(ctx context.Context, accessToken string) (*PersonalInfo, error) {
+	var info PersonalInfo
+	if err := c.getJSON(ctx, accessToken, PersonalInfoURL, nil, &info); err != nil {
+		return nil, fmt.Errorf("failed to get personal info: %w", err)
+	}
+	return &info, nil
+}
+
+// DailyReadiness is Oura's readiness score for a day
+type DailyReadiness struct {
+	ID    string `json:"id"`
+	Day   string `json:"day"`   // user's local date, "2006-01-02"
+	Score *int   `json:"score"` // null until Oura has scored the day
+}
+
+// DailySleep is Oura's sleep score for a day
+type DailySleep struct {
+	ID    string `json:"id"`
+	Day   string `json:"day"`
+	Score *int   `json:"score"`
+}
+
+// SleepPeriod is a single sleep session with its detailed measurements.
+// Durations are in seconds.
+type SleepPeriod struct {
+	ID                 string   `json:"id"`
+	Day                string   `json:"day"`
+	Type               string   `json:"type"` // "long_sleep" is the main sleep of the night
+	BedtimeEnd         string   `json:"bedtime_end"`
+	AverageHRV         *float64 `json:"average_hrv"`
+	LowestHeartRate    *int     `json:"lowest_heart_rate"`
+	Efficiency         *int     `json:"efficiency"`
+	TotalSleepDuration *int     `json:"total_sleep_duration"`
+	DeepSleepDuration  *int     `json:"deep_sleep_duration"`
+	REMSleepDuration   *int     `json:"rem_sleep_duration"`
+	LightSleepDuration *int     `json:"light_sleep_duration"`
+	AwakeTime          *int     `json:"awake_time"`
+}
+
+// GetDailyReadiness fetches readiness scores for days between start and end
+func (c *Client) GetDailyReadiness(ctx context.Context, accessToken string, start, end time.Time) ([]DailyReadiness, error) {
+	var records []DailyReadiness
+	if err := getCollection(ctx, c, accessToken, ReadinessURL, start, end, &records); err != nil {
+		return nil, fmt.Errorf("failed to get readiness data: %w", err)
+	}
+	return records, nil
+}
+
+// GetDailySleep fetches sleep scores for days between start and end
+func (c *Client) GetDailySleep(ctx context.Context, accessToken string, start, end time.Time) ([]DailySleep, error) {
+	var records []DailySleep
+	if err := getCollection(ctx, c, accessToken, DailySleepURL, start, end, &records); err != nil {
+		return nil, fmt.Errorf("failed to get daily sleep data: %w", err)
+	}
+	return records, nil
+}
+
+// GetSleepPeriods fetches sleep sessions for days between start and end
+func (c *Client) GetSleepPeriods(ctx context.Context, accessToken string, start, end time.Time) ([]SleepPeriod, error) {
+	var records []SleepPeriod
+	if err := getCollection(ctx, c, accessToken, SleepURL, start, end, &records); err != nil {
+		return nil, fmt.Errorf("failed to get sleep data: %w", err)
+	}
+	return records, nil
+}
+
+// getCollection fetches every page of a date-ranged collection endpoint
+func getCollection[T any](ctx context.Context, c *Client, accessToken, path string, start, end time.Time, records *[]T) error {
+	params := url.Values{
+		"start_date": {start.Format("2006-01-02")},
+		"end_date":   {end.AddDate(0, 0, 1).Format("2006-01-02")},
+	}
+	for {
+		var page struct {
+			Data      []T    `json:"data"`
+			NextToken string `json:"next_token"`
+		}
+		if err := c.getJSON(ctx, accessToken, path, params, &page); err != nil {
+			return err
+		}
+		*records = append(*records, page.Data...)
+		if page.NextToken == "" {
+			return nil
+		}
+		params.Set("next_token", page.NextToken)
+	}
+}
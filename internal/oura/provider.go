@@ -0,0 +1,180 @@
+package oura
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/models"
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
+)
+
+// ProviderName identifies Oura connections in the database
+const ProviderName = "oura"
+
+// Provider adapts the Oura client to wearables.Provider
+type Provider struct {
+	client *Client
+}
+
+// NewProvider creates an Oura provider backed by client
+func NewProvider(client *Client) *Provider {
+	return &Provider{client: client}
+}
+
+// Name returns the provider identifier
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// DisplayName returns the provider name shown to users
+func (p *Provider) DisplayName() string {
+	return "Oura"
+}
+
+// AuthURL returns the Oura OAuth authorization URL
+func (p *Provider) AuthURL(state string) string {
+	return p.client.GetAuthURL(state)
+}
+
+// ExchangeCode trades an authorization code for tokens
+func (p *Provider) ExchangeCode(ctx context.Context, code string) (*wearables.Token, error) {
+	tokenResp, err := p.client.ExchangeCodeForToken(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return newToken(tokenResp), nil
+}
+
+// RefreshToken trades a refresh token for new tokens
+func (p *Provider) RefreshToken(ctx context.Context, refreshToken string) (*wearables.Token, error) {
+	tokenResp, err := p.client.RefreshAccessToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return newToken(tokenResp), nil
+}
+
+// UserID returns the Oura user ID of the token's owner
+func (p *Provider) UserID(ctx context.Context, accessToken string) (string, error) {
+	info, err := p.client.GetPersonalInfo(ctx, accessToken)
+	if err != nil {
+		return "", err
+	}
+	return info.ID, nil
+}
+
+// Revoke revokes the user's grant with Oura
+func (p *Provider) Revoke(ctx context.Context, accessToken string) error {
+	return p.client.RevokeAccess(ctx, accessToken)
+}
+
+// Fetch returns Oura readiness and sleep between start and end. Oura already
+// reports each record's local day, so loc is only needed to pick the date
+// range. Readiness maps onto recovery, with HRV and resting heart rate taken
+// from the night's main sleep. Oura has nothing comparable to WHOOP strain,
+// so no activity is reported.
+func (p *Provider) Fetch(ctx context.Context, accessToken string, start, end time.Time, loc *time.Location) (*wearables.Data, error) {
+	data := &wearables.Data{}
+	start, end = start.In(loc), end.In(loc)
+
+	periods, err := p.client.GetSleepPeriods(ctx, accessToken, start, end)
+	if err != nil {
+		return data, err
+	}
+	mainSleeps := make(map[string]SleepPeriod)
+	for _, period := range periods {
+		if period.Type == "long_sleep" {
+			mainSleeps[period.Day] = period
+		}
+	}
+
+	var errs []error
+	sleepScores, err := p.client.GetDailySleep(ctx, accessToken, start, end)
+	if errors.Is(err, wearables.ErrUnauthorized) || errors.Is(err, wearables.ErrRateLimited) {
+		return data, err
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	for _, daily := range sleepScores {
+		date, err := time.Parse("2006-01-02", daily.Day)
+		if err != nil {
+			continue
+		}
+		data.Sleeps = append(data.Sleeps, normalizeSleep(date, daily.Score, mainSleeps[daily.Day]))
+	}
+
+	readiness, err := p.client.GetDailyReadiness(ctx, accessToken, start, end)
+	if errors.Is(err, wearables.ErrUnauthorized) || errors.Is(err, wearables.ErrRateLimited) {
+		return data, err
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	for _, daily := range readiness {
+		date, err := time.Parse("2006-01-02", daily.Day)
+		if err != nil {
+			continue
+		}
+		data.Recoveries = append(data.Recoveries, normalizeReadiness(date, daily.Score, mainSleeps[daily.Day]))
+	}
+
+	return data, errors.Join(errs...)
+}
+
+// newToken converts an Oura token response
+func newToken(tokenResp *TokenResponse) *wearables.Token {
+	return &wearables.Token{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}
+}
+
+// normalizeReadiness converts a day's readiness. A missing score means Oura
+// hasn't scored the day yet.
+func normalizeReadiness(date time.Time, score *int, sleep SleepPeriod) wearables.Recovery {
+	recovery := wearables.Recovery{
+		Date:       date,
+		ScoreState: models.ScoreStatePending,
+		HRV:        float64Value(sleep.AverageHRV),
+		RHR:        intValue(sleep.LowestHeartRate),
+	}
+	if score != nil {
+		recovery.ScoreState = models.ScoreStateScored
+		recovery.Score = *score
+	}
+	return recovery
+}
+
+// normalizeSleep converts a day's sleep score and its main sleep session
+func normalizeSleep(date time.Time, score *int, sleep SleepPeriod) wearables.Sleep {
+	result := wearables.Sleep{
+		Date:          date,
+		ScoreState:    models.ScoreStatePending,
+		DurationMS:    intValue(sleep.TotalSleepDuration) * 1000,
+		Efficiency:    float64(intValue(sleep.Efficiency)),
+		StagesDeepMS:  intValue(sleep.DeepSleepDuration) * 1000,
+		StagesREMMS:   intValue(sleep.REMSleepDuration) * 1000,
+		StagesLightMS: intValue(sleep.LightSleepDuration) * 1000,
+		StagesWakeMS:  intValue(sleep.AwakeTime) * 1000,
+	}
+	if score != nil {
+		result.ScoreState = models.ScoreStateScored
+		result.Score = *score
+	}
+	return result
+}
+
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func float64Value(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,262 @@
+// Package outbox posts Slack messages from a queue kept in the database, so
+// rate limits and outages delay messages instead of dropping them
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/pratikgajjar/fambot-go/internal/database"
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
+	"github.com/pratikgajjar/fambot-go/internal/models"
+)
+
+const (
+	// maxAttempts is how many failed posts a message gets before it is dropped.
+	// Rate limiting doesn't count as a failure.
+	maxAttempts = 8
+
+	// maxAge drops messages that could not be posted in time to still make
+	// sense, such as yesterday's standup after a long outage
+	maxAge = 24 * time.Hour
+
+	// Failed posts are retried after minBackoff, doubling up to maxBackoff
+	minBackoff = 2 * time.Second
+	maxBackoff = 5 * time.Minute
+
+	// sendTimeout bounds a single chat.postMessage call
+	sendTimeout = 30 * time.Second
+
+	// claimTTL is how long a message stays claimed by the instance posting
+	// it, long enough to post it and record the outcome
+	claimTTL = 2 * sendTimeout
+)
+
+// Attempt outcomes recorded in fambot_outbound_messages_total
+const (
+	outcomeSent        = "sent"
+	outcomeRateLimited = "rate_limited"
+	outcomeRetried     = "retried"
+	outcomeDropped     = "dropped"
+)
+
+// transientErrors are Slack API error codes worth retrying
+var transientErrors = map[string]bool{
+	"internal_error":      true,
+	"fatal_error":         true,
+	"service_unavailable": true,
+	"request_timeout":     true,
+	"ratelimited":         true,
+}
+
+// Queue posts messages one at a time, oldest first within each channel. A
+// channel whose head message is rate limited or failing waits without
+// holding up other channels. Messages are deleted only after Slack accepts
+// them, so a crash between the two can post a message twice. Replicas
+// sharing the database can run a queue each: every message is claimed
+// before it is posted, so only one of them posts it.
+type Queue struct {
+	client *slack.Client
+	db     *database.Database
+	holder string
+
+	wake     chan struct{}
+	stopping chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// New creates a queue that claims messages as holder, which must be unique
+// among replicas; call Start to begin posting
+func New(client *slack.Client, db *database.Database, holder string) *Queue {
+	return &Queue{
+		client:   client,
+		db:       db,
+		holder:   holder,
+		wake:     make(chan struct{}, 1),
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Enqueue stores a message for posting, in a thread when threadTS is set
+func (q *Queue) Enqueue(channel, threadTS, text string) error {
+	if _, err := q.db.EnqueueOutboundMessage(channel, threadTS, text); err != nil {
+		return fmt.Errorf("failed to queue message for %s: %w", channel, err)
+	}
+	q.notify()
+	return nil
+}
+
+// notify wakes the sender without blocking
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start posts queued messages in the background, including any left from a
+// previous run, until Shutdown
+func (q *Queue) Start() {
+	go q.run()
+}
+
+// Shutdown stops posting after the message in flight and waits for the
+// sender to exit, or for ctx to expire. Unsent messages stay queued for the
+// next start.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stopping) })
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	for {
+		select {
+		case <-q.stopping:
+			return
+		default:
+		}
+		wait := q.sendDue()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-q.stopping:
+			timer.Stop()
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// sendDue posts every channel's head message that is due, repeating until
+// none are, and returns how long to wait before the next one is
+func (q *Queue) sendDue() time.Duration {
+	for {
+		heads, err := q.db.GetOutboundHeads()
+		if err != nil {
+			log.Printf("Error loading outbound messages: %v", err)
+			return minBackoff
+		}
+		if count, err := q.db.CountOutboundMessages(); err == nil {
+			metrics.OutboundQueueDepth.Set(float64(count))
+		}
+
+		sent := false
+		wait := time.Hour
+		now := time.Now()
+		for _, message := range heads {
+			select {
+			case <-q.stopping:
+				return 0
+			default:
+			}
+
+			if until := message.NextAttemptAt.Sub(now); until > 0 {
+				wait = min(wait, until)
+				continue
+			}
+			claimed, err := q.db.ClaimOutboundMessage(message.ID, q.holder, time.Now().Add(claimTTL))
+			if err != nil {
+				log.Printf("Error claiming outbound message %d: %v", message.ID, err)
+				return minBackoff
+			}
+			if !claimed {
+				// Another replica is posting it; look again once it's done
+				wait = min(wait, minBackoff)
+				continue
+			}
+			if err := q.send(message); err != nil {
+				// Don't repost a message whose outcome couldn't be recorded
+				log.Printf("Error updating outbound message %d: %v", message.ID, err)
+				return minBackoff
+			}
+			sent = true
+		}
+		// A sent message may have uncovered the next one in its channel
+		if !sent {
+			return wait
+		}
+	}
+}
+
+// send posts one message and deletes or reschedules it, returning an error
+// only if the queue couldn't be updated
+func (q *Queue) send(message models.OutboundMessage) error {
+	if time.Since(message.CreatedAt) > maxAge {
+		return q.drop(message, fmt.Sprintf("not posted within %s, last error: %s", maxAge, message.LastError))
+	}
+
+	options := []slack.MsgOption{slack.MsgOptionText(message.Text, false)}
+	if message.ThreadTS != "" {
+		options = append(options, slack.MsgOptionTS(message.ThreadTS))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	_, _, err := q.client.PostMessageContext(ctx, message.Channel, options...)
+	cancel()
+
+	if err == nil {
+		metrics.OutboundMessages.Inc(outcomeSent)
+		return q.db.DeleteOutboundMessage(message.ID)
+	}
+
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		retryAfter := max(rateLimited.RetryAfter, time.Second)
+		log.Printf("Rate limited posting to %s, retrying in %s", message.Channel, retryAfter)
+		metrics.OutboundMessages.Inc(outcomeRateLimited)
+		return q.reschedule(message, message.Attempts, retryAfter, err)
+	}
+
+	attempts := message.Attempts + 1
+	if !retryable(err) || attempts >= maxAttempts {
+		return q.drop(message, fmt.Sprintf("after %d attempts: %v", attempts, err))
+	}
+
+	backoff := min(minBackoff<<(attempts-1), maxBackoff)
+	log.Printf("Error posting message to %s, retrying in %s: %v", message.Channel, backoff, err)
+	metrics.OutboundMessages.Inc(outcomeRetried)
+	return q.reschedule(message, attempts, backoff, err)
+}
+
+func (q *Queue) reschedule(message models.OutboundMessage, attempts int, after time.Duration, cause error) error {
+	return q.db.RescheduleOutboundMessage(message.ID, attempts, time.Now().Add(after), cause.Error())
+}
+
+// drop gives up on a message so the rest of its channel can be posted
+func (q *Queue) drop(message models.OutboundMessage, reason string) error {
+	log.Printf("Dropping message %d to %s %s", message.ID, message.Channel, reason)
+	metrics.OutboundMessages.Inc(outcomeDropped)
+	return q.db.DeleteOutboundMessage(message.ID)
+}
+
+// retryable reports whether a failed post might succeed later. Errors such
+// as channel_not_found or not_in_channel won't fix themselves.
+func retryable(err error) bool {
+	var apiErr slack.SlackErrorResponse
+	if errors.As(err, &apiErr) {
+		return transientErrors[apiErr.Err]
+	}
+	var statusErr slack.StatusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr.Retryable()
+	}
+	// Network errors and timeouts
+	return true
+}
@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/pratikgajjar/fambot-go/internal/database"
+)
+
+func TestReplicasPostEachMessageOnce(t *testing.T) {
+	// A fake Slack recording every chat.postMessage. Posts are slow enough
+	// that both replicas see the same heads while one is in flight.
+	var mu sync.Mutex
+	posted := make(map[string]int)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			http.NotFound(w, r)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		posted[r.FormValue("text")]++
+		mu.Unlock()
+		fmt.Fprintf(w, `{"ok":true,"channel":%q,"ts":"1700000000.000100"}`, r.FormValue("channel"))
+	}))
+	defer api.Close()
+
+	// Two replicas, each with its own connection to one database
+	path := filepath.Join(t.TempDir(), "fambot.db")
+	first, err := database.New(path)
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	defer first.Close()
+	second, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("database.Open: %v", err)
+	}
+	defer second.Close()
+
+	var texts []string
+	for i := 0; i < 20; i++ {
+		text := fmt.Sprintf("message %d", i)
+		if _, err := first.EnqueueOutboundMessage(fmt.Sprintf("C%d", i%3), "", text); err != nil {
+			t.Fatalf("EnqueueOutboundMessage: %v", err)
+		}
+		texts = append(texts, text)
+	}
+
+	client := slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/"))
+	queues := []*Queue{New(client, first, "replica-1"), New(client, second, "replica-2")}
+	for _, q := range queues {
+		q.Start()
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		count, err := first.CountOutboundMessages()
+		if err != nil {
+			t.Fatalf("CountOutboundMessages: %v", err)
+		}
+		if count == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages still queued", count)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, q := range queues {
+		if err := q.Shutdown(ctx); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, text := range texts {
+		if posted[text] != 1 {
+			t.Errorf("%q posted %d times, want once", text, posted[text])
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Package scheduler runs FamBot's recurring jobs on cron schedules, records
+// each run in the database and catches up on runs missed while the bot was
+// down
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/pratikgajjar/fambot-go/internal/database"
+	"github.com/pratikgajjar/fambot-go/internal/health"
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
+	"github.com/pratikgajjar/fambot-go/internal/models"
+)
+
+// Run outcomes, stored in job_runs and recorded in fambot_cron_runs_total
+const (
+	OutcomeRunning = "running"
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomePanic   = "panic"
+)
+
+// What started a run
+const (
+	TriggerSchedule = "schedule"
+	TriggerCatchUp  = "catchup"
+	TriggerManual   = "manual"
+)
+
+// runRetention is how long job_runs rows are kept
+const runRetention = 90 * 24 * time.Hour
+
+var (
+	// ErrUnknownJob is returned when triggering a job that isn't registered
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrJobRunning is returned when triggering a job that is already running
+	ErrJobRunning = errors.New("job is already running")
+)
+
+type job struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	catchUp  bool
+	fn       func() error
+	running  sync.Mutex // held while the job runs, so runs never overlap
+}
+
+// JobStatus describes a registered job for listing
+type JobStatus struct {
+	Name     string
+	Schedule string
+	Next     time.Time
+	Running  bool
+	LastRun  *models.JobRun // nil if the job has never run
+}
+
+// Scheduler runs registered jobs on their schedules in one time zone. Each
+// scheduled slot is claimed in job_runs before it runs, so a slot runs once
+// even if the bot restarts around it.
+type Scheduler struct {
+	db       *database.Database
+	cron     *cron.Cron
+	location *time.Location
+	started  time.Time
+	isLeader func() bool
+	lastRun  atomic.Int64 // unix nanoseconds of the last finished run of any job
+	runs     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// New creates a scheduler evaluating schedules in location
+func New(db *database.Database, location *time.Location) *Scheduler {
+	return &Scheduler{
+		db:       db,
+		cron:     cron.New(cron.WithLocation(location)),
+		location: location,
+		started:  time.Now(),
+		jobs:     make(map[string]*job),
+	}
+}
+
+// Location returns the time zone schedules are evaluated in
+func (s *Scheduler) Location() *time.Location {
+	return s.location
+}
+
+// SetLeaderCheck makes scheduled and catch-up runs happen only while
+// isLeader reports true, so replicas don't all run the same jobs. Manual
+// runs aren't affected. It must be called before Start; catching up is then
+// left to the caller, once this instance is elected.
+func (s *Scheduler) SetLeaderCheck(isLeader func() bool) {
+	s.isLeader = isLeader
+}
+
+// leading reports whether this instance should run scheduled jobs
+func (s *Scheduler) leading() bool {
+	return s.isLeader == nil || s.isLeader()
+}
+
+// Add registers fn as the named job on a standard five-field cron spec. If
+// catchUp is set and the bot starts after a slot earlier the same day
+// without it having run, that slot runs at startup. Daily jobs that act on
+// "today" should catch up; cleanup jobs that run again soon needn't.
+func (s *Scheduler) Add(name, spec string, catchUp bool, fn func() error) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for job %s: %w", spec, name, err)
+	}
+
+	j := &job{name: name, spec: spec, schedule: schedule, catchUp: catchUp, fn: fn}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %s is already registered", name)
+	}
+	s.jobs[name] = j
+
+	s.cron.Schedule(schedule, cron.FuncJob(func() {
+		now := time.Now().In(s.location)
+		s.runSlot(j, TriggerSchedule, lastSlot(j.schedule, now.Add(-time.Hour), now))
+	}))
+	return nil
+}
+
+// Start begins running jobs on their schedules and, without a leader check,
+// catches up on slots missed earlier today
+func (s *Scheduler) Start() {
+	if purged, err := s.db.PurgeJobRuns(time.Now().Add(-runRetention)); err != nil {
+		log.Printf("Error purging old job runs: %v", err)
+	} else if purged > 0 {
+		log.Printf("Purged %d old job runs", purged)
+	}
+
+	s.cron.Start()
+
+	if s.isLeader == nil {
+		s.CatchUp()
+	}
+}
+
+// Stop stops scheduling new runs and waits for running jobs to finish, or
+// for ctx to expire
+func (s *Scheduler) Stop(ctx context.Context) error {
+	<-s.cron.Stop().Done()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CatchUp runs, in the background, the latest slot of each catch-up job
+// that fell between midnight and now, unless it already ran. It is called
+// by Start, or when this instance becomes leader if there's a leader check.
+func (s *Scheduler) CatchUp() {
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.catchUp()
+	}()
+}
+
+func (s *Scheduler) catchUp() {
+	now := time.Now().In(s.location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
+
+	for _, j := range s.sortedJobs() {
+		if !j.catchUp {
+			continue
+		}
+		// Cron resolution is a second, so this includes a midnight slot
+		if slot := lastSlot(j.schedule, midnight.Add(-time.Second), now); !slot.IsZero() {
+			s.runSlot(j, TriggerCatchUp, slot)
+		}
+	}
+}
+
+// Trigger starts the named job now in the background, regardless of its
+// schedule
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return ErrUnknownJob
+	}
+	if !j.running.TryLock() {
+		return ErrJobRunning
+	}
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		defer j.running.Unlock()
+		s.execute(j, TriggerManual, time.Time{})
+	}()
+	return nil
+}
+
+// Jobs describes every registered job, sorted by name
+func (s *Scheduler) Jobs() ([]JobStatus, error) {
+	latest, err := s.db.GetLatestJobRuns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job runs: %w", err)
+	}
+
+	now := time.Now().In(s.location)
+	var statuses []JobStatus
+	for _, j := range s.sortedJobs() {
+		status := JobStatus{
+			Name:     j.name,
+			Schedule: j.spec,
+			Next:     j.schedule.Next(now),
+		}
+		if j.running.TryLock() {
+			j.running.Unlock()
+		} else {
+			status.Running = true
+		}
+		if run, ok := latest[j.name]; ok {
+			status.LastRun = &run
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Check fails when no job has finished within maxAge, which should be longer
+// than the gap between the most frequent job's runs. Instances that aren't
+// leading run no jobs and always pass.
+func (s *Scheduler) Check(maxAge time.Duration) health.Check {
+	return func(ctx context.Context) error {
+		if !s.leading() {
+			return nil
+		}
+		last := s.started
+		if nanos := s.lastRun.Load(); nanos != 0 {
+			last = time.Unix(0, nanos)
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("no scheduled job has run for %s", age.Round(time.Minute))
+		}
+		return nil
+	}
+}
+
+func (s *Scheduler) sortedJobs() []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].name < jobs[b].name })
+	return jobs
+}
+
+// runSlot runs a scheduled or catch-up slot unless another instance is
+// leading or the job is busy
+func (s *Scheduler) runSlot(j *job, trigger string, slot time.Time) {
+	if !s.leading() {
+		return
+	}
+	if !j.running.TryLock() {
+		log.Printf("Job %s is still running, skipping its %s run", j.name, trigger)
+		return
+	}
+	defer j.running.Unlock()
+
+	s.runs.Add(1)
+	defer s.runs.Done()
+	s.execute(j, trigger, slot)
+}
+
+// execute claims the run in job_runs, runs the job and records its outcome.
+// A panic is recovered and recorded so one bad run doesn't take the bot down.
+func (s *Scheduler) execute(j *job, trigger string, slot time.Time) {
+	id, claimed, err := s.db.StartJobRun(j.name, trigger, slot)
+	switch {
+	case err != nil && trigger == TriggerCatchUp:
+		// Without the record, catching up could post twice
+		log.Printf("Skipping catch-up of job %s: %v", j.name, err)
+		return
+	case err != nil:
+		log.Printf("Error recording run of job %s, running anyway: %v", j.name, err)
+	case !claimed:
+		log.Printf("Job %s already ran for %s, skipping", j.name, slot.In(s.location).Format(time.RFC3339))
+		return
+	}
+
+	log.Printf("Running job %s (%s)", j.name, trigger)
+	outcome, errMsg := OutcomeSuccess, ""
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", j.name, r)
+			outcome, errMsg = OutcomePanic, fmt.Sprint(r)
+		}
+		now := time.Now()
+		s.lastRun.Store(now.UnixNano())
+		metrics.CronRuns.Inc(j.name, outcome)
+		metrics.CronLastRun.Set(float64(now.Unix()), j.name)
+		if id != 0 {
+			if err := s.db.FinishJobRun(id, outcome, errMsg); err != nil {
+				log.Printf("Error recording outcome of job %s: %v", j.name, err)
+			}
+		}
+	}()
+
+	if err := j.fn(); err != nil {
+		log.Printf("Job %s failed: %v", j.name, err)
+		outcome, errMsg = OutcomeFailure, err.Error()
+	}
+}
+
+// lastSlot returns the latest time in (from, to] that schedule fires, or the
+// zero time if it doesn't fire in that range
+func lastSlot(schedule cron.Schedule, from, to time.Time) time.Time {
+	var last time.Time
+	for t := schedule.Next(from); !t.IsZero() && !t.After(to); t = schedule.Next(t) {
+		last = t
+	}
+	return last
+}
@@ -0,0 +1,132 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks a value produced by Keyring.Encrypt. The full format is
+// "enc:v1:<key id>:<base64(nonce || ciphertext)>".
+const sealedPrefix = "enc:v1:"
+
+var (
+	// ErrNotEncrypted is returned when decrypting a value that was never sealed
+	ErrNotEncrypted = errors.New("value is not encrypted")
+	// ErrUnknownKey is returned when a value was sealed with a key that is not in the keyring
+	ErrUnknownKey = errors.New("value was encrypted with an unknown key")
+)
+
+// Keyring holds the AES-256-GCM keys used to encrypt secrets at rest.
+// The first key is the active key used for new encryptions; the remaining
+// keys are only used to decrypt values written before a key rotation.
+type Keyring struct {
+	activeID string
+	aeads    map[string]cipher.AEAD
+}
+
+// ParseKeyring builds a keyring from a comma-separated list of "id:base64key"
+// entries, e.g. "k2:<new key>,k1:<old key>". Each key must decode to 32 bytes.
+func ParseKeyring(spec string) (*Keyring, error) {
+	keyring := &Keyring{aeads: make(map[string]cipher.AEAD)}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encodedKey, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || encodedKey == "" {
+			return nil, errors.New("invalid key entry: expected id:base64key")
+		}
+		if _, exists := keyring.aeads[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: not valid base64", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid key %q: expected 32 bytes, got %d", id, len(key))
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+
+		if keyring.activeID == "" {
+			keyring.activeID = id
+		}
+		keyring.aeads[id] = aead
+	}
+
+	if keyring.activeID == "" {
+		return nil, errors.New("no encryption keys configured")
+	}
+
+	return keyring, nil
+}
+
+// Encrypt seals plaintext with the active key
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	aead := k.aeads[k.activeID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.activeID))
+	return sealedPrefix + k.activeID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with whichever key sealed it
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", ErrNotEncrypted
+	}
+
+	id, payload, ok := strings.Cut(strings.TrimPrefix(value, sealedPrefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	aead, exists := k.aeads[id]
+	if !exists {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value with key %q: %w", id, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// IsCurrent reports whether value is already sealed with the active key
+func (k *Keyring) IsCurrent(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix+k.activeID+":")
+}
+
+// IsEncrypted reports whether value looks like the output of Keyring.Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
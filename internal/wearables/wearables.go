@@ -0,0 +1,103 @@
+// Package wearables defines the interface FamBot uses to talk to wearable
+// device APIs and the normalized data they report
+package wearables
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrUnauthorized is returned when a provider rejects the access token
+	ErrUnauthorized = errors.New("wearables: unauthorized")
+	// ErrRateLimited is returned when a provider keeps rate limiting after retries
+	ErrRateLimited = errors.New("wearables: rate limited")
+	// ErrTokenRevoked is returned when a refresh token is no longer valid and
+	// the user has to go through OAuth again
+	ErrTokenRevoked = errors.New("wearables: token revoked")
+	// ErrClientRejected is returned when a provider rejects FamBot's own
+	// client credentials, which an administrator has to fix
+	ErrClientRejected = errors.New("wearables: client credentials rejected")
+)
+
+// Provider is a wearable device API that FamBot can connect users to
+type Provider interface {
+	// Name is the stable identifier stored with connections, e.g. "whoop"
+	Name() string
+	// DisplayName is the name shown to users, e.g. "WHOOP"
+	DisplayName() string
+	// AuthURL returns the OAuth authorization URL carrying state
+	AuthURL(state string) string
+	// ExchangeCode trades an OAuth authorization code for tokens
+	ExchangeCode(ctx context.Context, code string) (*Token, error)
+	// RefreshToken trades a refresh token for new tokens
+	RefreshToken(ctx context.Context, refreshToken string) (*Token, error)
+	// UserID returns the provider-side ID of the token's owner
+	UserID(ctx context.Context, accessToken string) (string, error)
+	// Fetch returns the user's records between start and end. Dates are
+	// resolved to the user's local day, using loc when the provider doesn't
+	// say which timezone a record was taken in. A partial result may be
+	// returned together with an error.
+	Fetch(ctx context.Context, accessToken string, start, end time.Time, loc *time.Location) (*Data, error)
+}
+
+// Revoker is implemented by providers that can revoke a user's grant, so a
+// disconnect also cuts off access on the provider's side
+type Revoker interface {
+	Revoke(ctx context.Context, accessToken string) error
+}
+
+// Token is an OAuth token pair issued by a provider
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// Recovery is a day's readiness to perform: WHOOP recovery, Oura readiness
+type Recovery struct {
+	Date        time.Time // user's local calendar date, as midnight UTC
+	ScoreState  string    // one of the models.ScoreState* values
+	Calibrating bool      // the device is still learning the user's baselines
+	Score       int       // 0-100
+	HRV         float64   // ms
+	RHR         int       // bpm
+}
+
+// Sleep is the main sleep ending on a day
+type Sleep struct {
+	Date          time.Time // user's local calendar date the sleep ended, as midnight UTC
+	ScoreState    string
+	DurationMS    int
+	Efficiency    float64 // 0-100
+	Score         int     // 0-100
+	StagesDeepMS  int
+	StagesREMMS   int
+	StagesLightMS int
+	StagesWakeMS  int
+}
+
+// Activity is a day's exertion on WHOOP's 0-21 strain scale
+type Activity struct {
+	Date       time.Time // user's local calendar date, as midnight UTC
+	ScoreState string
+	Strain     float64
+}
+
+// Data is everything a provider reported for a date range
+type Data struct {
+	Recoveries []Recovery
+	Sleeps     []Sleep
+	Activities []Activity
+}
+
+// LocalDate returns the calendar day t falls on in loc, as midnight UTC so
+// it can be stored and compared as a plain date
+func LocalDate(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
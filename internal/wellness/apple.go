@@ -0,0 +1,201 @@
+package wellness
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/models"
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
+)
+
+// appleDateLayout is how Apple Health writes record timestamps
+const appleDateLayout = "2006-01-02 15:04:05 -0700"
+
+// Apple Health sleep analysis values. Older exports only distinguish in bed
+// from asleep; watchOS 9 and later record sleep stages.
+const (
+	appleSleepRecord      = "HKCategoryTypeIdentifierSleepAnalysis"
+	appleInBed            = "HKCategoryValueSleepAnalysisInBed"
+	appleAsleep           = "HKCategoryValueSleepAnalysisAsleep"
+	appleAsleepUnspecific = "HKCategoryValueSleepAnalysisAsleepUnspecified"
+	appleAsleepCore       = "HKCategoryValueSleepAnalysisAsleepCore"
+	appleAsleepDeep       = "HKCategoryValueSleepAnalysisAsleepDeep"
+	appleAsleepREM        = "HKCategoryValueSleepAnalysisAsleepREM"
+	appleAwake            = "HKCategoryValueSleepAnalysisAwake"
+)
+
+// appleSessionGap is the longest break between sleep segments that still
+// counts as the same night, such as getting up once in the small hours
+const appleSessionGap = time.Hour
+
+// appleSegment is one sleep analysis record
+type appleSegment struct {
+	start, end time.Time
+	value      string
+}
+
+// appleNight accumulates one source's sleep segments for a night
+type appleNight struct {
+	inBed, asleep, deep, rem, light, awake time.Duration
+}
+
+// add counts a segment towards the night
+func (n *appleNight) add(segment appleSegment) {
+	duration := segment.end.Sub(segment.start)
+	switch segment.value {
+	case appleInBed:
+		n.inBed += duration
+	case appleAsleep, appleAsleepUnspecific:
+		n.asleep += duration
+	case appleAsleepCore:
+		n.asleep += duration
+		n.light += duration
+	case appleAsleepDeep:
+		n.asleep += duration
+		n.deep += duration
+	case appleAsleepREM:
+		n.asleep += duration
+		n.rem += duration
+	case appleAwake:
+		n.awake += duration
+	}
+}
+
+// parseAppleHealth streams an Apple Health export.xml, which is often
+// hundreds of megabytes, and totals each night's sleep. Each source's
+// segments are grouped into sessions, which break wherever there's a gap of
+// appleSessionGap or more, and a session is dated by the day of its final
+// wake, so a night from 23:00 to 07:00 counts once, for the morning. When
+// several sources (say a watch and a phone) logged the same night, the one
+// that recorded the most sleep wins so the night isn't counted twice. Apple
+// Health has no recovery score, so only sleep is imported.
+func parseAppleHealth(r io.Reader) (*wearables.Data, error) {
+	segments := make(map[string][]appleSegment)
+
+	decoder := xml.NewDecoder(r)
+	sawHealthData := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Apple Health export: %w", err)
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if element.Name.Local == "HealthData" {
+			sawHealthData = true
+			continue
+		}
+		if element.Name.Local != "Record" || attr(element, "type") != appleSleepRecord {
+			continue
+		}
+
+		start, err := time.Parse(appleDateLayout, attr(element, "startDate"))
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(appleDateLayout, attr(element, "endDate"))
+		if err != nil || !end.After(start) {
+			continue
+		}
+
+		source := attr(element, "sourceName")
+		segments[source] = append(segments[source], appleSegment{start: start, end: end, value: attr(element, "value")})
+	}
+	if !sawHealthData {
+		return nil, fmt.Errorf("%w: not an Apple Health export", ErrUnsupportedFormat)
+	}
+
+	nights := make(map[time.Time]map[string]*appleNight)
+	for source, sourceSegments := range segments {
+		for _, session := range appleSessions(sourceSegments) {
+			wake := session[0].end
+			for _, segment := range session {
+				if segment.end.After(wake) {
+					wake = segment.end
+				}
+			}
+			date := calendarDate(wake)
+			if nights[date] == nil {
+				nights[date] = make(map[string]*appleNight)
+			}
+			night := nights[date][source]
+			if night == nil {
+				night = &appleNight{}
+				nights[date][source] = night
+			}
+			for _, segment := range session {
+				night.add(segment)
+			}
+		}
+	}
+
+	data := &wearables.Data{}
+	for date, sources := range nights {
+		// Phones often log time in bed while the watch logs the sleep itself
+		var best *appleNight
+		var inBed time.Duration
+		for _, night := range sources {
+			if best == nil || night.asleep > best.asleep {
+				best = night
+			}
+			inBed = max(inBed, night.inBed)
+		}
+		if best.asleep == 0 {
+			continue
+		}
+
+		sleep := wearables.Sleep{
+			Date:          date,
+			ScoreState:    models.ScoreStateScored,
+			DurationMS:    int(best.asleep.Milliseconds()),
+			StagesDeepMS:  int(best.deep.Milliseconds()),
+			StagesREMMS:   int(best.rem.Milliseconds()),
+			StagesLightMS: int(best.light.Milliseconds()),
+			StagesWakeMS:  int(best.awake.Milliseconds()),
+		}
+		if inBed > 0 {
+			sleep.Efficiency = min(100, 100*best.asleep.Seconds()/inBed.Seconds())
+		}
+		data.Sleeps = append(data.Sleeps, sleep)
+	}
+	return data, nil
+}
+
+// appleSessions sorts one source's segments and splits them into sessions
+// wherever nothing was recorded for appleSessionGap or more
+func appleSessions(segments []appleSegment) [][]appleSegment {
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start.Before(segments[j].start) })
+
+	var sessions [][]appleSegment
+	var end time.Time
+	for _, segment := range segments {
+		if len(sessions) == 0 || segment.start.Sub(end) >= appleSessionGap {
+			sessions = append(sessions, nil)
+		}
+		last := len(sessions) - 1
+		sessions[last] = append(sessions[last], segment)
+		if segment.end.After(end) {
+			end = segment.end
+		}
+	}
+	return sessions
+}
+
+// attr returns the value of an element's attribute, or "" if it's missing
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package wellness
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAppleHealthDatesNightsByWake(t *testing.T) {
+	// Two nights in Berlin: the first starts before midnight and has a
+	// half-hour gap either side of it, the second starts after midnight. The
+	// phone logs time in bed while the watch logs stages.
+	export := `<?xml version="1.0" encoding="UTF-8"?>
+<HealthData locale="en_US">
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Phone" startDate="2024-03-04 22:45:00 +0100" endDate="2024-03-05 07:05:00 +0100" value="HKCategoryValueSleepAnalysisInBed"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2024-03-04 23:00:00 +0100" endDate="2024-03-04 23:50:00 +0100" value="HKCategoryValueSleepAnalysisAsleepCore"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2024-03-05 00:20:00 +0100" endDate="2024-03-05 02:00:00 +0100" value="HKCategoryValueSleepAnalysisAsleepDeep"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2024-03-05 02:00:00 +0100" endDate="2024-03-05 02:10:00 +0100" value="HKCategoryValueSleepAnalysisAwake"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2024-03-05 02:10:00 +0100" endDate="2024-03-05 04:00:00 +0100" value="HKCategoryValueSleepAnalysisAsleepREM"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2024-03-05 04:00:00 +0100" endDate="2024-03-05 07:00:00 +0100" value="HKCategoryValueSleepAnalysisAsleepCore"/>
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2024-03-06 00:30:00 +0100" endDate="2024-03-06 07:30:00 +0100" value="HKCategoryValueSleepAnalysisAsleepUnspecified"/>
+</HealthData>`
+
+	data, err := parseAppleHealth(strings.NewReader(export))
+	if err != nil {
+		t.Fatalf("parseAppleHealth: %v", err)
+	}
+	data, _, err = checkData(data, FormatAppleHealth, nil)
+	if err != nil {
+		t.Fatalf("checkData: %v", err)
+	}
+	if len(data.Sleeps) != 2 {
+		t.Fatalf("got %d sleeps, want 2: %+v", len(data.Sleeps), data.Sleeps)
+	}
+
+	first := data.Sleeps[0]
+	if got := first.Date.Format("2006-01-02"); got != "2024-03-05" {
+		t.Errorf("first night dated %s, want 2024-03-05", got)
+	}
+	asleep := 50*time.Minute + 100*time.Minute + 110*time.Minute + 3*time.Hour
+	if first.DurationMS != int(asleep.Milliseconds()) {
+		t.Errorf("first night slept %s, want %s", time.Duration(first.DurationMS)*time.Millisecond, asleep)
+	}
+	if first.StagesDeepMS != int((100*time.Minute).Milliseconds()) || first.StagesWakeMS != int((10*time.Minute).Milliseconds()) {
+		t.Errorf("first night stages = %+v", first)
+	}
+	inBed := 8*time.Hour + 20*time.Minute
+	if want := 100 * asleep.Seconds() / inBed.Seconds(); first.Efficiency != want {
+		t.Errorf("first night efficiency = %.1f, want %.1f", first.Efficiency, want)
+	}
+
+	second := data.Sleeps[1]
+	if got := second.Date.Format("2006-01-02"); got != "2024-03-06" {
+		t.Errorf("second night dated %s, want 2024-03-06", got)
+	}
+	if second.DurationMS != int((7 * time.Hour).Milliseconds()) {
+		t.Errorf("second night slept %s, want 7h", time.Duration(second.DurationMS)*time.Millisecond)
+	}
+}
@@ -0,0 +1,279 @@
+package wellness
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/models"
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
+)
+
+// CSVColumns documents the generic CSV layout. Column names are matched
+// case-insensitively ignoring spaces and punctuation, and the Fitbit and
+// Google Fit spellings of the same values are accepted too.
+const CSVColumns = "`date`, `sleep_hours`, `sleep_score`, `efficiency`, `deep_minutes`, `rem_minutes`, " +
+	"`light_minutes`, `awake_minutes`, `recovery`, `hrv`, `rhr`"
+
+// csvField is a value we know how to read from a CSV column
+type csvField int
+
+const (
+	fieldDate csvField = iota
+	fieldSleepHours
+	fieldSleepMinutes
+	fieldSleepMS
+	fieldInBedMinutes
+	fieldDeepMinutes
+	fieldREMMinutes
+	fieldLightMinutes
+	fieldAwakeMinutes
+	fieldSleepScore
+	fieldEfficiency
+	fieldRecovery
+	fieldHRV
+	fieldRHR
+)
+
+// csvAliases maps each field to the normalized column names it may appear
+// under, in order of preference. Fitbit's sleep export names the columns
+// "Minutes Asleep", "End Time" and so on; its sleep_score.csv uses
+// "timestamp" and "overall_score"; Google Fit daily summaries carry
+// "Sleep duration (ms)".
+var csvAliases = map[csvField][]string{
+	fieldDate:         {"date", "day", "dateofsleep", "endtime", "sleepend", "timestamp", "starttime"},
+	fieldSleepHours:   {"sleephours", "hoursasleep"},
+	fieldSleepMinutes: {"minutesasleep", "sleepminutes", "totalsleepminutes"},
+	fieldSleepMS:      {"sleepdurationms"},
+	fieldInBedMinutes: {"timeinbed", "timeinbedminutes"},
+	fieldDeepMinutes:  {"deepminutes", "minutesdeepsleep", "deepsleepinminutes", "deepsleepminutes"},
+	fieldREMMinutes:   {"remminutes", "minutesremsleep", "remsleepminutes"},
+	fieldLightMinutes: {"lightminutes", "minuteslightsleep", "lightsleepminutes"},
+	fieldAwakeMinutes: {"awakeminutes", "minutesawake"},
+	fieldSleepScore:   {"sleepscore", "overallscore"},
+	fieldEfficiency:   {"efficiency", "sleepefficiency"},
+	fieldRecovery:     {"recovery", "recoveryscore", "readiness", "readinessscore"},
+	fieldHRV:          {"hrv", "hrvms", "rmssd"},
+	fieldRHR:          {"rhr", "restingheartrate", "restingheartratebpm"},
+}
+
+// csvDateLayouts are the date formats seen in exports, tried in order
+var csvDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02 3:04PM",
+	"2006-01-02 3:04 PM",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.000",
+	"2006/01/02",
+	"01-02-2006",
+	"1/2/2006",
+	"1/2/2006 15:04",
+	"1/2/2006 3:04 PM",
+}
+
+// csvDay accumulates the rows for one date; Fitbit lists naps separately
+type csvDay struct {
+	sleep    wearables.Sleep
+	inBedMS  int
+	recovery *wearables.Recovery
+}
+
+// parseCSV reads every table in a CSV file that has a date column and at
+// least one known metric. Fitbit's account export stacks several titled
+// tables in one file, so a one-cell row ends the current table and the next
+// recognizable header starts a new one.
+func parseCSV(r io.Reader) (*wearables.Data, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	days := make(map[time.Time]*csvDay)
+	var columns map[csvField]int
+	sawTable := false
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && !sawTable {
+				return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+			}
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		if len(row) < 2 {
+			columns = nil
+			continue
+		}
+		if columns == nil {
+			columns = csvHeader(row)
+			sawTable = sawTable || columns != nil
+			continue
+		}
+
+		date, ok := parseCSVDate(cell(row, columns, fieldDate))
+		if !ok {
+			continue
+		}
+		day := days[date]
+		if day == nil {
+			day = &csvDay{sleep: wearables.Sleep{Date: date, ScoreState: models.ScoreStateScored}}
+			days[date] = day
+		}
+		addCSVRow(day, row, columns)
+	}
+	if !sawTable {
+		return nil, fmt.Errorf("%w: no header with a date column and known metrics (expected columns like %s)", ErrUnsupportedFormat, CSVColumns)
+	}
+
+	data := &wearables.Data{}
+	for _, day := range days {
+		if day.sleep.DurationMS > 0 {
+			if day.sleep.Efficiency == 0 && day.inBedMS > 0 {
+				day.sleep.Efficiency = min(100, 100*float64(day.sleep.DurationMS)/float64(day.inBedMS))
+			}
+			data.Sleeps = append(data.Sleeps, day.sleep)
+		}
+		if day.recovery != nil {
+			data.Recoveries = append(data.Recoveries, *day.recovery)
+		}
+	}
+	return data, nil
+}
+
+// addCSVRow folds one row into its day. Durations add up across rows, while
+// scores keep the latest value seen.
+func addCSVRow(day *csvDay, row []string, columns map[csvField]int) {
+	sleepMS := 0
+	if hours, ok := number(row, columns, fieldSleepHours); ok {
+		sleepMS = int(hours * float64(time.Hour/time.Millisecond))
+	} else if minutes, ok := number(row, columns, fieldSleepMinutes); ok {
+		sleepMS = minutesToMS(minutes)
+	} else if ms, ok := number(row, columns, fieldSleepMS); ok {
+		sleepMS = int(ms)
+	}
+	day.sleep.DurationMS += sleepMS
+
+	if minutes, ok := number(row, columns, fieldInBedMinutes); ok {
+		day.inBedMS += minutesToMS(minutes)
+	}
+	if minutes, ok := number(row, columns, fieldDeepMinutes); ok {
+		day.sleep.StagesDeepMS += minutesToMS(minutes)
+	}
+	if minutes, ok := number(row, columns, fieldREMMinutes); ok {
+		day.sleep.StagesREMMS += minutesToMS(minutes)
+	}
+	if minutes, ok := number(row, columns, fieldLightMinutes); ok {
+		day.sleep.StagesLightMS += minutesToMS(minutes)
+	}
+	if minutes, ok := number(row, columns, fieldAwakeMinutes); ok {
+		day.sleep.StagesWakeMS += minutesToMS(minutes)
+	}
+	if score, ok := number(row, columns, fieldSleepScore); ok && score > 0 && score <= 100 {
+		day.sleep.Score = int(score)
+	}
+	if efficiency, ok := number(row, columns, fieldEfficiency); ok && efficiency > 0 && efficiency <= 100 {
+		day.sleep.Efficiency = efficiency
+	}
+
+	// Without a recovery or readiness score there's nothing to show for the
+	// day's recovery, so HRV and resting heart rate alone are not imported
+	score, ok := number(row, columns, fieldRecovery)
+	if !ok || score < 0 || score > 100 {
+		return
+	}
+	recovery := &wearables.Recovery{
+		Date:       day.sleep.Date,
+		ScoreState: models.ScoreStateScored,
+		Score:      int(score),
+	}
+	if hrv, ok := number(row, columns, fieldHRV); ok {
+		recovery.HRV = hrv
+	}
+	if rhr, ok := number(row, columns, fieldRHR); ok {
+		recovery.RHR = int(rhr)
+	}
+	day.recovery = recovery
+}
+
+// csvHeader maps known fields to their column index, or returns nil if the
+// row isn't a header with a date and at least one metric
+func csvHeader(row []string) map[csvField]int {
+	names := make(map[string]int, len(row))
+	for i, name := range row {
+		if _, exists := names[normalizeColumn(name)]; !exists {
+			names[normalizeColumn(name)] = i
+		}
+	}
+
+	columns := make(map[csvField]int)
+	for field, aliases := range csvAliases {
+		for _, alias := range aliases {
+			if i, ok := names[alias]; ok {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	if _, ok := columns[fieldDate]; !ok || len(columns) < 2 {
+		return nil
+	}
+	return columns
+}
+
+// normalizeColumn lowercases a column name and drops everything but letters
+// and digits, so "Minutes Asleep" and "minutes_asleep" match
+func normalizeColumn(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// cell returns a row's trimmed value for field, or "" if the column is absent
+func cell(row []string, columns map[csvField]int, field csvField) string {
+	i, ok := columns[field]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// number parses a numeric cell, allowing thousands separators
+func number(row []string, columns map[csvField]int, field csvField) (float64, bool) {
+	value := strings.ReplaceAll(cell(row, columns, field), ",", "")
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseCSVDate parses a date cell in any of the known layouts
+func parseCSVDate(value string) (time.Time, bool) {
+	for _, layout := range csvDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return calendarDate(t), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func minutesToMS(minutes float64) int {
+	return int(minutes * float64(time.Minute/time.Millisecond))
+}
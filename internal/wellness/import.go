@@ -0,0 +1,139 @@
+// Package wellness parses wellness data exported from devices and apps so
+// people without API access can still share their sleep and recovery
+package wellness
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
+)
+
+// Export formats understood by Parse
+const (
+	FormatAppleHealth = "Apple Health"
+	FormatCSV         = "CSV"
+)
+
+// ErrUnsupportedFormat is returned for files that are neither an Apple Health
+// export nor a CSV with recognizable columns
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// ErrNoData is returned when a file parses but holds no sleep or recovery
+var ErrNoData = errors.New("no sleep or recovery data found")
+
+// ErrTooLarge is returned for a zipped export.xml that would decompress to
+// more than maxExportSize
+var ErrTooLarge = errors.New("export too large")
+
+// maxExportSize bounds a zipped export.xml once decompressed. Years of
+// watch data make exports of several hundred megabytes, but a small zip
+// can decompress to far more than that.
+const maxExportSize = 2 << 30
+
+// Parse detects the format of an exported file and normalizes its contents.
+// Apple Health exports are accepted as export.xml or the export.zip the
+// Health app produces; anything else is read as CSV, which covers Fitbit
+// and Google Fit exports as well as the generic layout described in
+// CSVColumns.
+func Parse(filename string, content []byte) (*wearables.Data, string, error) {
+	name := strings.ToLower(filename)
+
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		export, err := appleExportFromZip(content)
+		if err != nil {
+			return nil, "", err
+		}
+		defer export.Close()
+		data, err := parseAppleHealth(export)
+		return checkData(data, FormatAppleHealth, err)
+
+	case strings.HasSuffix(name, ".xml") || bytes.HasPrefix(bytes.TrimSpace(content), []byte("<?xml")):
+		data, err := parseAppleHealth(bytes.NewReader(content))
+		return checkData(data, FormatAppleHealth, err)
+
+	default:
+		data, err := parseCSV(bytes.NewReader(content))
+		return checkData(data, FormatCSV, err)
+	}
+}
+
+// checkData rejects successfully parsed files that held nothing useful
+func checkData(data *wearables.Data, format string, err error) (*wearables.Data, string, error) {
+	if err != nil {
+		return nil, format, err
+	}
+	if len(data.Sleeps) == 0 && len(data.Recoveries) == 0 {
+		return nil, format, ErrNoData
+	}
+	sort.Slice(data.Sleeps, func(i, j int) bool { return data.Sleeps[i].Date.Before(data.Sleeps[j].Date) })
+	sort.Slice(data.Recoveries, func(i, j int) bool { return data.Recoveries[i].Date.Before(data.Recoveries[j].Date) })
+	return data, format, nil
+}
+
+// appleExportFromZip opens export.xml in the Health app's export.zip for
+// streaming; the caller must close it. Reads stop at the size the zip
+// declares, which the zip reader checks against what it decompresses.
+func appleExportFromZip(content []byte) (io.ReadCloser, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %w", err)
+	}
+	for _, file := range archive.File {
+		if path.Base(file.Name) != "export.xml" {
+			continue
+		}
+		if file.UncompressedSize64 > maxExportSize {
+			return nil, fmt.Errorf("%w: %s is %d MB uncompressed, over the %d MB limit",
+				ErrTooLarge, file.Name, file.UncompressedSize64>>20, maxExportSize>>20)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+		}
+		return limitedReadCloser{io.LimitReader(rc, int64(file.UncompressedSize64)), rc}, nil
+	}
+	return nil, fmt.Errorf("%w: zip has no export.xml", ErrUnsupportedFormat)
+}
+
+// limitedReadCloser reads through a limit and closes the underlying reader
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// DateRange returns the first and last day covered by imported data
+func DateRange(data *wearables.Data) (first, last time.Time) {
+	for _, sleep := range data.Sleeps {
+		first, last = widen(first, last, sleep.Date)
+	}
+	for _, recovery := range data.Recoveries {
+		first, last = widen(first, last, recovery.Date)
+	}
+	return first, last
+}
+
+func widen(first, last, date time.Time) (time.Time, time.Time) {
+	if first.IsZero() || date.Before(first) {
+		first = date
+	}
+	if last.IsZero() || date.After(last) {
+		last = date
+	}
+	return first, last
+}
+
+// calendarDate returns the date t was written with as midnight UTC. Exports
+// record times in the wearer's local time, which is the day we want.
+func calendarDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,64 @@
+package wellness
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// zipExport returns a zip holding export.xml with the given content
+func zipExport(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.Create("apple_health_export/export.xml")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseZippedAppleExport(t *testing.T) {
+	content := zipExport(t, `<?xml version="1.0" encoding="UTF-8"?>
+<HealthData locale="en_US">
+ <Record type="HKCategoryTypeIdentifierSleepAnalysis" sourceName="Watch" startDate="2024-03-04 23:00:00 +0100" endDate="2024-03-05 07:00:00 +0100" value="HKCategoryValueSleepAnalysisAsleepUnspecified"/>
+</HealthData>`)
+
+	data, format, err := Parse("export.zip", content)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if format != FormatAppleHealth || len(data.Sleeps) != 1 {
+		t.Fatalf("Parse = %s with %d sleeps, want one Apple Health sleep", format, len(data.Sleeps))
+	}
+}
+
+func TestParseRejectsOversizedZipEntry(t *testing.T) {
+	// A stored entry whose header claims more than the limit, without
+	// actually writing that much
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	_, err := archive.CreateRaw(&zip.FileHeader{
+		Name:               "export.xml",
+		Method:             zip.Store,
+		UncompressedSize64: maxExportSize + 1,
+		CompressedSize64:   0,
+	})
+	if err != nil {
+		t.Fatalf("CreateRaw: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, _, err := Parse("export.zip", buf.Bytes()); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Parse error = %v, want ErrTooLarge", err)
+	}
+}
@@ -0,0 +1,186 @@
+package whoop
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/models"
+)
+
+const (
+	// hrvDropDays is how many consecutive days HRV must stay low to alert
+	hrvDropDays = 3
+	// shortSleepNights is how many nights in a row sleep must be short to alert
+	shortSleepNights = 2
+)
+
+// DefaultAlertThresholds apply when a user turns an alert on without giving one
+var DefaultAlertThresholds = map[string]float64{
+	models.AlertRuleRecovery: 33, // percent
+	models.AlertRuleHRV:      15, // percent below baseline
+	models.AlertRuleSleep:    6,  // hours
+}
+
+// alertThresholdLimits bounds the thresholds users may choose per rule
+var alertThresholdLimits = map[string][2]float64{
+	models.AlertRuleRecovery: {1, 100},
+	models.AlertRuleHRV:      {1, 90},
+	models.AlertRuleSleep:    {1, 14},
+}
+
+// EnableAlert turns on an alert rule for a user, replacing any previous
+// threshold. A zero threshold selects the rule's default.
+func (s *Service) EnableAlert(userID, rule string, threshold float64) (*models.WHOOPAlertRule, error) {
+	limits, ok := alertThresholdLimits[rule]
+	if !ok {
+		return nil, fmt.Errorf("unknown alert rule %q", rule)
+	}
+	if threshold == 0 {
+		threshold = DefaultAlertThresholds[rule]
+	}
+	if threshold < limits[0] || threshold > limits[1] {
+		return nil, fmt.Errorf("%s threshold must be between %.0f and %.0f", rule, limits[0], limits[1])
+	}
+
+	alertRule := &models.WHOOPAlertRule{UserID: userID, Rule: rule, Threshold: threshold}
+	if err := s.db.UpsertWHOOPAlertRule(alertRule); err != nil {
+		return nil, fmt.Errorf("failed to save alert rule: %w", err)
+	}
+	return alertRule, nil
+}
+
+// DisableAlert turns off one of a user's alert rules, or all of them when rule is empty
+func (s *Service) DisableAlert(userID, rule string) error {
+	if _, ok := alertThresholdLimits[rule]; rule != "" && !ok {
+		return fmt.Errorf("unknown alert rule %q", rule)
+	}
+	return s.db.DeleteWHOOPAlertRule(userID, rule)
+}
+
+// GetAlertRules returns the alert rules a user has turned on
+func (s *Service) GetAlertRules(userID string) ([]models.WHOOPAlertRule, error) {
+	return s.db.GetWHOOPAlertRules(userID)
+}
+
+// CheckAlerts evaluates a user's alert rules against their stored history and
+// DMs them about any that trigger. Each rule alerts at most once per record
+// date, so repeated syncs of the same day stay quiet.
+func (s *Service) CheckAlerts(userID string) {
+	rules, err := s.db.GetWHOOPAlertRules(userID)
+	if err != nil {
+		log.Printf("Failed to get WHOOP alert rules for user %s: %v", userID, err)
+		return
+	}
+
+	for _, rule := range rules {
+		date, message, err := s.evaluateAlert(rule)
+		if err != nil {
+			log.Printf("Failed to evaluate WHOOP %s alert for user %s: %v", rule.Rule, userID, err)
+			continue
+		}
+		if message == "" || !date.After(rule.LastAlerted) {
+			continue
+		}
+
+		s.notify(userID, message)
+		if err := s.db.MarkWHOOPAlertSent(userID, rule.Rule, date); err != nil {
+			log.Printf("Failed to record WHOOP %s alert for user %s: %v", rule.Rule, userID, err)
+		}
+	}
+}
+
+// evaluateAlert returns the date of the newest record behind a triggered rule
+// and the message to send, or an empty message when the rule doesn't trigger
+func (s *Service) evaluateAlert(rule models.WHOOPAlertRule) (time.Time, string, error) {
+	switch rule.Rule {
+	case models.AlertRuleRecovery:
+		return s.evaluateRecoveryAlert(rule)
+	case models.AlertRuleHRV:
+		return s.evaluateHRVAlert(rule)
+	case models.AlertRuleSleep:
+		return s.evaluateSleepAlert(rule)
+	default:
+		return time.Time{}, "", fmt.Errorf("unknown alert rule %q", rule.Rule)
+	}
+}
+
+// evaluateRecoveryAlert triggers when the latest recovery is below the threshold
+func (s *Service) evaluateRecoveryAlert(rule models.WHOOPAlertRule) (time.Time, string, error) {
+	recoveries, err := s.db.GetRecentWHOOPRecoveries(rule.UserID, 1)
+	if err != nil || len(recoveries) == 0 {
+		return time.Time{}, "", err
+	}
+	latest := recoveries[0]
+	if float64(latest.Score) >= rule.Threshold {
+		return time.Time{}, "", nil
+	}
+
+	message := fmt.Sprintf("💙 Gentle heads-up: your latest recovery is %d%%, below your alert level of %.0f%%.", latest.Score, rule.Threshold)
+	if baseline, err := s.GetUserAverages(rule.UserID, BaselineDays); err == nil && baseline.RecoveryDays > 1 {
+		message += fmt.Sprintf(" Your %d-day average is %.0f%%, so your body may still be catching up.", BaselineDays, baseline.Recovery)
+	}
+	message += " Maybe keep today's intensity light and get to bed a little earlier. 🌙"
+	return latest.Date, message, nil
+}
+
+// evaluateHRVAlert triggers when HRV has been the threshold percentage below
+// the personal baseline for hrvDropDays consecutive days
+func (s *Service) evaluateHRVAlert(rule models.WHOOPAlertRule) (time.Time, string, error) {
+	recoveries, err := s.db.GetRecentWHOOPRecoveries(rule.UserID, hrvDropDays)
+	if err != nil || len(recoveries) < hrvDropDays || !consecutiveDays(recoveries[0].Date, recoveries[hrvDropDays-1].Date, hrvDropDays) {
+		return time.Time{}, "", err
+	}
+
+	baseline, err := s.GetUserAverages(rule.UserID, BaselineDays)
+	if err != nil || baseline.RecoveryDays <= hrvDropDays || baseline.HRV <= 0 {
+		return time.Time{}, "", err
+	}
+
+	limit := baseline.HRV * (1 - rule.Threshold/100)
+	readings := make([]string, 0, len(recoveries))
+	for i := len(recoveries) - 1; i >= 0; i-- {
+		if recoveries[i].HRV > limit {
+			return time.Time{}, "", nil
+		}
+		readings = append(readings, fmt.Sprintf("%.0fms", recoveries[i].HRV))
+	}
+
+	message := fmt.Sprintf("💙 Gentle heads-up: your HRV has been at least %.0f%% below your %d-day average of %.0fms for %d days running (%s). "+
+		"That can be a sign of stress, illness or too little rest, so a lighter day or an early night might help. 🌿",
+		rule.Threshold, BaselineDays, baseline.HRV, hrvDropDays, strings.Join(readings, ", "))
+	return recoveries[0].Date, message, nil
+}
+
+// evaluateSleepAlert triggers when the last shortSleepNights sleeps were all
+// shorter than the threshold
+func (s *Service) evaluateSleepAlert(rule models.WHOOPAlertRule) (time.Time, string, error) {
+	sleeps, err := s.db.GetRecentWHOOPSleeps(rule.UserID, shortSleepNights)
+	if err != nil || len(sleeps) < shortSleepNights || !consecutiveDays(sleeps[0].Date, sleeps[shortSleepNights-1].Date, shortSleepNights) {
+		return time.Time{}, "", err
+	}
+
+	hours := make([]string, 0, len(sleeps))
+	for i := len(sleeps) - 1; i >= 0; i-- {
+		slept := float64(sleeps[i].DurationMS) / (1000 * 60 * 60)
+		if slept >= rule.Threshold {
+			return time.Time{}, "", nil
+		}
+		hours = append(hours, fmt.Sprintf("%.1fh", slept))
+	}
+
+	message := fmt.Sprintf("💙 Gentle heads-up: you've slept under %.1f hours %d nights in a row (%s).", rule.Threshold, shortSleepNights, strings.Join(hours, ", "))
+	if baseline, err := s.GetUserAverages(rule.UserID, BaselineDays); err == nil && baseline.SleepDays > shortSleepNights {
+		message += fmt.Sprintf(" You usually average %.1f hours.", baseline.SleepHours)
+	}
+	message += " Protecting some extra sleep tonight could make tomorrow a lot easier. 😴"
+	return sleeps[0].Date, message, nil
+}
+
+// consecutiveDays reports whether count records dated from oldest to newest
+// fit within count calendar days; with one record per date that means there
+// are no gaps between them
+func consecutiveDays(newest, oldest time.Time, count int) bool {
+	return newest.Sub(oldest) <= time.Duration(count-1)*24*time.Hour
+}
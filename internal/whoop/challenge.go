@@ -0,0 +1,132 @@
+package whoop
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/models"
+)
+
+const (
+	// GreenRecoveryThreshold is the recovery score where WHOOP's green zone starts
+	GreenRecoveryThreshold = 67
+	// DefaultChallengeDays is how long a challenge runs when no length is given
+	DefaultChallengeDays = 7
+	// MaxChallengeDays bounds how long a challenge may run
+	MaxChallengeDays = 30
+	// DefaultStrainGoal is the daily strain target for strain challenges
+	DefaultStrainGoal = 10
+)
+
+// ErrChallengeNotActive is returned when joining or leaving a challenge that
+// has already finished or been cancelled
+var ErrChallengeNotActive = errors.New("challenge is not active")
+
+// CreateChallenge starts a challenge today that runs for days calendar days,
+// with its creator as the first participant
+func (s *Service) CreateChallenge(createdBy, metric string, days int, goal float64) (*models.WHOOPChallenge, error) {
+	switch metric {
+	case models.ChallengeSleep, models.ChallengeGreen:
+		goal = 0
+	case models.ChallengeStrain:
+		if goal == 0 {
+			goal = DefaultStrainGoal
+		}
+		if goal < 1 || goal > 21 {
+			return nil, fmt.Errorf("strain goal must be between 1 and 21")
+		}
+	default:
+		return nil, fmt.Errorf("unknown challenge type %q", metric)
+	}
+	if days < 1 || days > MaxChallengeDays {
+		return nil, fmt.Errorf("challenges can run for 1 to %d days", MaxChallengeDays)
+	}
+
+	start := windowStart(1)
+	challenge := &models.WHOOPChallenge{
+		Metric:    metric,
+		Goal:      goal,
+		CreatedBy: createdBy,
+		StartDate: start,
+		EndDate:   start.AddDate(0, 0, days-1),
+		Status:    models.ChallengeActive,
+	}
+	if err := s.db.CreateWHOOPChallenge(challenge); err != nil {
+		return nil, fmt.Errorf("failed to create challenge: %w", err)
+	}
+	if err := s.db.JoinWHOOPChallenge(challenge.ID, createdBy); err != nil {
+		return nil, fmt.Errorf("failed to join challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// GetChallenge returns a challenge by ID
+func (s *Service) GetChallenge(id int) (*models.WHOOPChallenge, error) {
+	return s.db.GetWHOOPChallenge(id)
+}
+
+// GetActiveChallenges returns challenges that haven't been finalized yet
+func (s *Service) GetActiveChallenges() ([]models.WHOOPChallenge, error) {
+	return s.db.GetActiveWHOOPChallenges()
+}
+
+// JoinChallenge opts a user into an active challenge
+func (s *Service) JoinChallenge(id int, userID string) (*models.WHOOPChallenge, error) {
+	challenge, err := s.activeChallenge(id)
+	if err != nil {
+		return nil, err
+	}
+	return challenge, s.db.JoinWHOOPChallenge(id, userID)
+}
+
+// LeaveChallenge opts a user out of an active challenge, reporting whether
+// they were taking part
+func (s *Service) LeaveChallenge(id int, userID string) (bool, error) {
+	if _, err := s.activeChallenge(id); err != nil {
+		return false, err
+	}
+	return s.db.LeaveWHOOPChallenge(id, userID)
+}
+
+// CancelChallenge stops an active challenge without a final leaderboard.
+// Only the creator may cancel it.
+func (s *Service) CancelChallenge(id int, userID string) error {
+	challenge, err := s.activeChallenge(id)
+	if err != nil {
+		return err
+	}
+	if challenge.CreatedBy != userID {
+		return fmt.Errorf("only <@%s> can cancel this challenge", challenge.CreatedBy)
+	}
+	return s.db.SetWHOOPChallengeStatus(id, models.ChallengeCancelled)
+}
+
+// FinishChallenge marks a challenge as finished once its leaderboard is posted
+func (s *Service) FinishChallenge(id int) error {
+	return s.db.SetWHOOPChallengeStatus(id, models.ChallengeFinished)
+}
+
+// GetChallengeStandings returns each participant's progress, best first
+func (s *Service) GetChallengeStandings(challenge *models.WHOOPChallenge) ([]models.ChallengeStanding, error) {
+	return s.db.GetWHOOPChallengeStandings(challenge, GreenRecoveryThreshold)
+}
+
+// ChallengeOver reports whether every day of the challenge is in the past
+func ChallengeOver(challenge *models.WHOOPChallenge, now time.Time) bool {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return today.After(challenge.EndDate)
+}
+
+// activeChallenge loads a challenge and checks it is still running
+func (s *Service) activeChallenge(id int) (*models.WHOOPChallenge, error) {
+	challenge, err := s.db.GetWHOOPChallenge(id)
+	if err != nil {
+		return nil, fmt.Errorf("challenge #%d not found", id)
+	}
+	if challenge.Status != models.ChallengeActive {
+		return nil, ErrChallengeNotActive
+	}
+	return challenge, nil
+}
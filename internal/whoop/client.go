@@ -1,22 +1,29 @@
 package whoop
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
 )
 
 const (
-	BaseURL       = "https://api.prod.whoop.com/developer"
-	AuthURL       = "https://api.prod.whoop.com/oauth/oauth2/auth"
-	TokenURL      = "https://api.prod.whoop.com/oauth/oauth2/token"
+	BaseURL        = "https://api.prod.whoop.com/developer"
+	AuthURL        = "https://api.prod.whoop.com/oauth/oauth2/auth"
+	TokenURL       = "https://api.prod.whoop.com/oauth/oauth2/token"
 	UserProfileURL = "/v1/user/profile/basic"
-	RecoveryURL   = "/v1/recovery"
-	SleepURL      = "/v1/activity/sleep"
-	WorkoutURL    = "/v1/activity/workout"
+	UserAccessURL  = "/v1/user/access"
+	RecoveryURL    = "/v1/recovery"
+	SleepURL       = "/v1/activity/sleep"
+	WorkoutURL     = "/v1/activity/workout"
+	CycleURL       = "/v1/cycle"
 )
 
 // Client represents a WHOOP API client
@@ -25,25 +32,41 @@ type Client struct {
 	clientID     string
 	clientSecret string
 	redirectURL  string
+	baseURL      string
+	tokenURL     string
+	limiters     []*tokenBucket
 }
 
 // NewClient creates a new WHOOP API client
 func NewClient(clientID, clientSecret, redirectURL string) *Client {
 	return &Client{
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		httpClient:   &http.Client{Timeout: 30 * time.Second, Transport: metrics.NewTransport(ProviderName, nil)},
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		redirectURL:  redirectURL,
+		baseURL:      BaseURL,
+		tokenURL:     TokenURL,
+		limiters: []*tokenBucket{
+			newTokenBucket(requestsPerMinute, time.Minute),
+			newTokenBucket(requestsPerDay, 24*time.Hour),
+		},
 	}
 }
 
+// SetEndpoints points the client at a different WHOOP deployment, such as a
+// local fake server
+func (c *Client) SetEndpoints(baseURL, tokenURL string) {
+	c.baseURL = baseURL
+	c.tokenURL = tokenURL
+}
+
 // GetAuthURL returns the WHOOP OAuth authorization URL
 func (c *Client) GetAuthURL(state string) string {
 	params := url.Values{
 		"client_id":     {c.clientID},
 		"redirect_uri":  {c.redirectURL},
 		"response_type": {"code"},
-		"scope":         {"read:recovery read:sleep read:profile read:workout"},
+		"scope":         {"read:recovery read:sleep read:profile read:workout read:cycles"},
 		"state":         {state},
 	}
 	return fmt.Sprintf("%s?%s", AuthURL, params.Encode())
@@ -60,7 +83,7 @@ type TokenResponse struct {
 }
 
 // ExchangeCodeForToken exchanges authorization code for access token
-func (c *Client) ExchangeCodeForToken(code string) (*TokenResponse, error) {
+func (c *Client) ExchangeCodeForToken(ctx context.Context, code string) (*TokenResponse, error) {
 	data := url.Values{
 		"grant_type":    {"authorization_code"},
 		"client_id":     {c.clientID},
@@ -69,31 +92,15 @@ func (c *Client) ExchangeCodeForToken(code string) (*TokenResponse, error) {
 		"redirect_uri":  {c.redirectURL},
 	}
 
-	resp, err := c.httpClient.PostForm(TokenURL, data)
+	tokenResp, err := c.postToken(ctx, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("token exchange failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var tokenResp TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to decode token response: %w", err)
+		return nil, fmt.Errorf("token exchange failed: %w", err)
 	}
-
-	// Debug logging
-	fmt.Printf("[DEBUG] Token response: AccessToken=%.10s..., ExpiresIn=%d, RefreshToken=%.10s..., UserID=%s\n", 
-		tokenResp.AccessToken, tokenResp.ExpiresIn, tokenResp.RefreshToken, tokenResp.UserID)
-
-	return &tokenResp, nil
+	return tokenResp, nil
 }
 
 // RefreshAccessToken refreshes an expired access token
-func (c *Client) RefreshAccessToken(refreshToken string) (*TokenResponse, error) {
+func (c *Client) RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
 	data := url.Values{
 		"grant_type":    {"refresh_token"},
 		"client_id":     {c.clientID},
@@ -101,15 +108,34 @@ func (c *Client) RefreshAccessToken(refreshToken string) (*TokenResponse, error)
 		"refresh_token": {refreshToken},
 	}
 
-	resp, err := c.httpClient.PostForm(TokenURL, data)
+	tokenResp, err := c.postToken(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("token refresh failed: %w", err)
+	}
+	return tokenResp, nil
+}
+
+// postToken sends a form to the OAuth token endpoint. Rate limiting and
+// server errors are retried with backoff, but a request whose response was
+// lost is not: retrying a refresh that went through would spend the rotated
+// refresh token again and look like a revoked grant. Such refreshes are
+// retried by the service on a later sync instead.
+func (c *Client) postToken(ctx context.Context, data url.Values) (*TokenResponse, error) {
+	resp, err := c.sendToken(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to refresh token: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, parseTokenError(resp)
 	}
 
 	var tokenResp TokenResponse
@@ -120,49 +146,120 @@ func (c *Client) RefreshAccessToken(refreshToken string) (*TokenResponse, error)
 	return &tokenResp, nil
 }
 
+// TokenError is returned when the WHOOP token endpoint rejects a request
+type TokenError struct {
+	StatusCode  int
+	Code        string // OAuth error code, e.g. "invalid_grant"
+	Description string
+}
+
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += fmt.Sprintf(" (%s)", e.Description)
+	}
+	return msg
+}
+
+// Revoked reports whether the grant itself is no longer valid, meaning the
+// user has to go through OAuth again
+func (e *TokenError) Revoked() bool {
+	switch e.Code {
+	case "invalid_grant", "invalid_token":
+		return true
+	}
+	return false
+}
+
+// ClientRejected reports whether WHOOP rejected the client ID or secret,
+// which no user can fix by reconnecting
+func (e *TokenError) ClientRejected() bool {
+	switch e.Code {
+	case "invalid_client", "unauthorized_client":
+		return true
+	}
+	return false
+}
+
+// Is lets callers match revoked grants against wearables.ErrTokenRevoked and
+// rejected credentials against wearables.ErrClientRejected
+func (e *TokenError) Is(target error) bool {
+	switch target {
+	case wearables.ErrTokenRevoked:
+		return e.Revoked()
+	case wearables.ErrClientRejected:
+		return e.ClientRejected()
+	}
+	return false
+}
+
+// parseTokenError builds a TokenError from an OAuth error response without
+// keeping the raw body, which may contain token material
+func parseTokenError(resp *http.Response) *TokenError {
+	var oauthErr struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&oauthErr)
+
+	return &TokenError{
+		StatusCode:  resp.StatusCode,
+		Code:        oauthErr.Error,
+		Description: oauthErr.Description,
+	}
+}
+
 // UserProfile represents basic user profile from WHOOP
 type UserProfile struct {
-	UserID    int64  `json:"user_id"`    // WHOOP returns user_id as a number
+	UserID    int64  `json:"user_id"` // WHOOP returns user_id as a number
 	Email     string `json:"email"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 }
 
 // GetUserProfile fetches the user's basic profile information
-func (c *Client) GetUserProfile(accessToken string) (*UserProfile, error) {
-	req, err := http.NewRequest("GET", BaseURL+UserProfileURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+func (c *Client) GetUserProfile(ctx context.Context, accessToken string) (*UserProfile, error) {
+	var profile UserProfile
+	if err := c.getJSON(ctx, accessToken, UserProfileURL, nil, &profile); err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
 	}
+	return &profile, nil
+}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	resp, err := c.httpClient.Do(req)
+// RevokeAccess revokes the access token's grant, so WHOOP stops sharing the
+// user's data with FamBot
+func (c *Client) RevokeAccess(ctx context.Context, accessToken string) error {
+	resp, err := c.send(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.baseURL+UserAccessURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user profile: %w", err)
+		return fmt.Errorf("DELETE %s: %w", UserAccessURL, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("get user profile failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var profile UserProfile
-	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
-		return nil, fmt.Errorf("failed to decode user profile: %w", err)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError("DELETE "+UserAccessURL, resp)
 	}
-
-	return &profile, nil
+	return nil
 }
 
 // RecoveryData represents WHOOP recovery data
 type RecoveryData struct {
-	UserID    int64     `json:"user_id"` // WHOOP returns numeric user_id
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	ScoreState string   `json:"score_state"`
-	Score struct {
+	CycleID    int64     `json:"cycle_id"`
+	SleepID    int64     `json:"sleep_id"`
+	UserID     int64     `json:"user_id"` // WHOOP returns numeric user_id
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ScoreState string    `json:"score_state"`
+	Score      struct {
 		UserCalibrating bool    `json:"user_calibrating"`
 		RecoveryScore   float64 `json:"recovery_score"` // WHOOP returns float recovery score
 		HRVRmssd        float64 `json:"hrv_rmssd_milli"`
@@ -172,71 +269,58 @@ type RecoveryData struct {
 
 // RecoveryResponse represents the API response for recovery data
 type RecoveryResponse struct {
-	Records    []RecoveryData `json:"records"`
-	NextToken  string         `json:"next_token"`
+	Records   []RecoveryData `json:"records"`
+	NextToken string         `json:"next_token"`
 }
 
 // GetRecovery fetches recovery data for a date range
-func (c *Client) GetRecovery(accessToken string, start, end time.Time) (*RecoveryResponse, error) {
-	params := url.Values{
-		"start": {start.Format("2006-01-02T15:04:05.000Z")},
-		"end":   {end.Format("2006-01-02T15:04:05.000Z")},
-	}
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s?%s", BaseURL, RecoveryURL, params.Encode()), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+func (c *Client) GetRecovery(ctx context.Context, accessToken string, start, end time.Time) (*RecoveryResponse, error) {
+	var result RecoveryResponse
+	if err := c.getJSON(ctx, accessToken, RecoveryURL, dateRangeParams(start, end), &result); err != nil {
 		return nil, fmt.Errorf("failed to get recovery data: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("get recovery failed with status %d: %s", resp.StatusCode, string(body))
-	}
+	return &result, nil
+}
 
-	var recoveryResp RecoveryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&recoveryResp); err != nil {
-		return nil, fmt.Errorf("failed to decode recovery response: %w", err)
+// GetCycleRecovery fetches the recovery belonging to a single physiological cycle
+func (c *Client) GetCycleRecovery(ctx context.Context, accessToken string, cycleID int64) (*RecoveryData, error) {
+	var result RecoveryData
+	if err := c.getJSON(ctx, accessToken, fmt.Sprintf("%s/%d/recovery", CycleURL, cycleID), nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get recovery for cycle %d: %w", cycleID, err)
 	}
-
-	return &recoveryResp, nil
+	return &result, nil
 }
 
 // SleepData represents WHOOP sleep data
 type SleepData struct {
-	ID        int64     `json:"id"`      // WHOOP returns numeric sleep record ID
-	UserID    int64     `json:"user_id"` // WHOOP returns numeric user_id
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Start     time.Time `json:"start"`
-	End       time.Time `json:"end"`
-	Score struct {
+	ID             int64     `json:"id"`      // WHOOP returns numeric sleep record ID
+	UserID         int64     `json:"user_id"` // WHOOP returns numeric user_id
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	TimezoneOffset string    `json:"timezone_offset"` // e.g. "-05:00"
+	ScoreState     string    `json:"score_state"`
+	Score          struct {
 		Stage_summary struct {
-			TotalInBedTimeMS    int `json:"total_in_bed_time_milli"`
-			TotalAwakeTimeMS    int `json:"total_awake_time_milli"`
-			TotalNoDataTimeMS   int `json:"total_no_data_time_milli"`
-			TotalLightSleepMS   int `json:"total_light_sleep_time_milli"`
+			TotalInBedTimeMS     int `json:"total_in_bed_time_milli"`
+			TotalAwakeTimeMS     int `json:"total_awake_time_milli"`
+			TotalNoDataTimeMS    int `json:"total_no_data_time_milli"`
+			TotalLightSleepMS    int `json:"total_light_sleep_time_milli"`
 			TotalSlowWaveSleepMS int `json:"total_slow_wave_sleep_time_milli"`
-			TotalRemSleepMS     int `json:"total_rem_sleep_time_milli"`
-			SleepCycleCount     int `json:"sleep_cycle_count"`
-			DisturbanceCount    int `json:"disturbance_count"`
+			TotalRemSleepMS      int `json:"total_rem_sleep_time_milli"`
+			SleepCycleCount      int `json:"sleep_cycle_count"`
+			DisturbanceCount     int `json:"disturbance_count"`
 		} `json:"stage_summary"`
 		SleepNeeded struct {
-			BaselineMS         int `json:"baseline_milli"`
-			NeedFromSleepDebtMS int `json:"need_from_sleep_debt_milli"`
+			BaselineMS             int `json:"baseline_milli"`
+			NeedFromSleepDebtMS    int `json:"need_from_sleep_debt_milli"`
 			NeedFromRecentStrainMS int `json:"need_from_recent_strain_milli"`
 			NeedFromRecentNapMS    int `json:"need_from_recent_nap_milli"`
 		} `json:"sleep_needed"`
-		SleepEfficiencyPercentage float64 `json:"sleep_efficiency_percentage"`
+		SleepEfficiencyPercentage  float64 `json:"sleep_efficiency_percentage"`
 		SleepConsistencyPercentage float64 `json:"sleep_consistency_percentage"`
-		SleepScore                int     `json:"sleep_score"`
+		SleepScore                 int     `json:"sleep_score"`
 	} `json:"score"`
 }
 
@@ -247,52 +331,38 @@ type SleepResponse struct {
 }
 
 // GetSleep fetches sleep data for a date range
-func (c *Client) GetSleep(accessToken string, start, end time.Time) (*SleepResponse, error) {
-	params := url.Values{
-		"start": {start.Format("2006-01-02T15:04:05.000Z")},
-		"end":   {end.Format("2006-01-02T15:04:05.000Z")},
-	}
-
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s?%s", BaseURL, SleepURL, params.Encode()), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+func (c *Client) GetSleep(ctx context.Context, accessToken string, start, end time.Time) (*SleepResponse, error) {
+	var result SleepResponse
+	if err := c.getJSON(ctx, accessToken, SleepURL, dateRangeParams(start, end), &result); err != nil {
 		return nil, fmt.Errorf("failed to get sleep data: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("get sleep failed with status %d: %s", resp.StatusCode, string(body))
-	}
+	return &result, nil
+}
 
-	var sleepResp SleepResponse
-	if err := json.NewDecoder(resp.Body).Decode(&sleepResp); err != nil {
-		return nil, fmt.Errorf("failed to decode sleep response: %w", err)
+// GetSleepByID fetches a single sleep record
+func (c *Client) GetSleepByID(ctx context.Context, accessToken string, sleepID int64) (*SleepData, error) {
+	var result SleepData
+	if err := c.getJSON(ctx, accessToken, fmt.Sprintf("%s/%d", SleepURL, sleepID), nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get sleep %d: %w", sleepID, err)
 	}
-
-	return &sleepResp, nil
+	return &result, nil
 }
 
 // WorkoutData represents WHOOP workout data
 type WorkoutData struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Start     time.Time `json:"start"`
-	End       time.Time `json:"end"`
-	Score struct {
-		Strain        float64 `json:"strain"`
-		AverageHR     int     `json:"average_heart_rate"`
-		MaxHR         int     `json:"max_heart_rate"`
-		Kilojoule     float64 `json:"kilojoule"`
-		PercentZone5  float64 `json:"percent_recorded"`
+	ID             int64     `json:"id"`
+	UserID         int64     `json:"user_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	TimezoneOffset string    `json:"timezone_offset"`
+	Score          struct {
+		Strain       float64 `json:"strain"`
+		AverageHR    int     `json:"average_heart_rate"`
+		MaxHR        int     `json:"max_heart_rate"`
+		Kilojoule    float64 `json:"kilojoule"`
+		PercentZone5 float64 `json:"percent_recorded"`
 	} `json:"score"`
 	Sport struct {
 		ID   int    `json:"id"`
@@ -307,34 +377,60 @@ type WorkoutResponse struct {
 }
 
 // GetWorkouts fetches workout/strain data for a date range
-func (c *Client) GetWorkouts(accessToken string, start, end time.Time) (*WorkoutResponse, error) {
-	params := url.Values{
-		"start": {start.Format("2006-01-02T15:04:05.000Z")},
-		"end":   {end.Format("2006-01-02T15:04:05.000Z")},
+func (c *Client) GetWorkouts(ctx context.Context, accessToken string, start, end time.Time) (*WorkoutResponse, error) {
+	var result WorkoutResponse
+	if err := c.getJSON(ctx, accessToken, WorkoutURL, dateRangeParams(start, end), &result); err != nil {
+		return nil, fmt.Errorf("failed to get workout data: %w", err)
 	}
+	return &result, nil
+}
 
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s?%s", BaseURL, WorkoutURL, params.Encode()), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// GetWorkoutByID fetches a single workout record
+func (c *Client) GetWorkoutByID(ctx context.Context, accessToken string, workoutID int64) (*WorkoutData, error) {
+	var result WorkoutData
+	if err := c.getJSON(ctx, accessToken, fmt.Sprintf("%s/%d", WorkoutURL, workoutID), nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get workout %d: %w", workoutID, err)
 	}
+	return &result, nil
+}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+// CycleData represents a WHOOP physiological cycle, which carries the day's strain
+type CycleData struct {
+	ID             int64     `json:"id"`
+	UserID         int64     `json:"user_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"` // zero while the cycle is still in progress
+	TimezoneOffset string    `json:"timezone_offset"`
+	ScoreState     string    `json:"score_state"`
+	Score          struct {
+		Strain    float64 `json:"strain"`
+		Kilojoule float64 `json:"kilojoule"`
+		AverageHR int     `json:"average_heart_rate"`
+		MaxHR     int     `json:"max_heart_rate"`
+	} `json:"score"`
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get workout data: %w", err)
-	}
-	defer resp.Body.Close()
+// CycleResponse represents the API response for cycle data
+type CycleResponse struct {
+	Records   []CycleData `json:"records"`
+	NextToken string      `json:"next_token"`
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("get workout failed with status %d: %s", resp.StatusCode, string(body))
+// GetCycles fetches physiological cycles for a date range
+func (c *Client) GetCycles(ctx context.Context, accessToken string, start, end time.Time) (*CycleResponse, error) {
+	var result CycleResponse
+	if err := c.getJSON(ctx, accessToken, CycleURL, dateRangeParams(start, end), &result); err != nil {
+		return nil, fmt.Errorf("failed to get cycle data: %w", err)
 	}
+	return &result, nil
+}
 
-	var workoutResp WorkoutResponse
-	if err := json.NewDecoder(resp.Body).Decode(&workoutResp); err != nil {
-		return nil, fmt.Errorf("failed to decode workout response: %w", err)
+// dateRangeParams builds the start/end query parameters WHOOP collection endpoints expect
+func dateRangeParams(start, end time.Time) url.Values {
+	return url.Values{
+		"start": {start.UTC().Format("2006-01-02T15:04:05.000Z")},
+		"end":   {end.UTC().Format("2006-01-02T15:04:05.000Z")},
 	}
-
-	return &workoutResp, nil
 }
@@ -0,0 +1,141 @@
+package whoop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
+)
+
+// newTokenTestClient returns a client whose token endpoint is handled by
+// respond, which is given the 1-based number of the request
+func newTokenTestClient(t *testing.T, respond func(w http.ResponseWriter, r *http.Request, n int)) (*Client, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(w, r, int(requests.Add(1)))
+	}))
+	t.Cleanup(api.Close)
+
+	client := NewClient("test-client", testClientSecret, "http://localhost/whoop/callback")
+	client.SetEndpoints(api.URL, api.URL+"/oauth/oauth2/token")
+	return client, &requests
+}
+
+// writeToken answers a token request with a fresh token pair
+func writeToken(w http.ResponseWriter) {
+	fmt.Fprint(w, `{"access_token":"new-access","refresh_token":"new-refresh","expires_in":3600,"token_type":"bearer"}`)
+}
+
+func TestRefreshRetriesDefinitiveFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		failure  func(w http.ResponseWriter)
+		minDelay time.Duration
+	}{
+		{"server error", func(w http.ResponseWriter) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}, 0},
+		{"rate limited", func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := newTokenTestClient(t, func(w http.ResponseWriter, r *http.Request, n int) {
+				if n == 1 {
+					tt.failure(w)
+					return
+				}
+				writeToken(w)
+			})
+
+			start := time.Now()
+			token, err := client.RefreshAccessToken(context.Background(), "old-refresh")
+			if err != nil {
+				t.Fatalf("RefreshAccessToken: %v", err)
+			}
+			if token.RefreshToken != "new-refresh" {
+				t.Errorf("refresh token = %q, want new-refresh", token.RefreshToken)
+			}
+			if n := requests.Load(); n != 2 {
+				t.Errorf("token endpoint got %d requests, want 2", n)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minDelay {
+				t.Errorf("retried after %s, want Retry-After of %s honored", elapsed, tt.minDelay)
+			}
+		})
+	}
+}
+
+func TestRefreshGivesUpAfterMaxAttempts(t *testing.T) {
+	client, requests := newTokenTestClient(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		http.Error(w, "unavailable", http.StatusBadGateway)
+	})
+
+	_, err := client.RefreshAccessToken(context.Background(), "old-refresh")
+	if err == nil {
+		t.Fatal("RefreshAccessToken succeeded, want an error")
+	}
+	if n := requests.Load(); n != maxAttempts {
+		t.Errorf("token endpoint got %d requests, want %d", n, maxAttempts)
+	}
+}
+
+func TestRefreshDoesNotRetryLostResponses(t *testing.T) {
+	// The refresh may have gone through before the connection dropped, so
+	// sending the old refresh token again could spend the rotated one
+	client, requests := newTokenTestClient(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n > 1 {
+			writeToken(w)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		conn.Close()
+	})
+
+	if _, err := client.RefreshAccessToken(context.Background(), "old-refresh"); err == nil {
+		t.Fatal("RefreshAccessToken succeeded, want the network error")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("token endpoint got %d requests, want 1", n)
+	}
+}
+
+func TestTokenErrorClassification(t *testing.T) {
+	tests := []struct {
+		code           string
+		revoked        bool
+		clientRejected bool
+	}{
+		{"invalid_grant", true, false},
+		{"invalid_token", true, false},
+		{"unauthorized_client", false, true},
+		{"invalid_client", false, true},
+		{"invalid_request", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			err := fmt.Errorf("token refresh failed: %w", &TokenError{StatusCode: http.StatusBadRequest, Code: tt.code})
+			if got := errors.Is(err, wearables.ErrTokenRevoked); got != tt.revoked {
+				t.Errorf("revoked = %v, want %v", got, tt.revoked)
+			}
+			if got := errors.Is(err, wearables.ErrClientRejected); got != tt.clientRejected {
+				t.Errorf("client rejected = %v, want %v", got, tt.clientRejected)
+			}
+		})
+	}
+}
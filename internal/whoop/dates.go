@@ -0,0 +1,50 @@
+package whoop
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// localDate returns the calendar day t falls on for the user, as midnight UTC
+// so it can be stored and compared as a plain date. WHOOP's timezone_offset
+// (e.g. "-05:00") is used when present since it reflects where the user
+// actually was; otherwise fallback (normally their Slack timezone) applies.
+func localDate(t time.Time, offset string, fallback *time.Location) time.Time {
+	loc := fallback
+	if offsetLoc, ok := parseOffset(offset); ok {
+		loc = offsetLoc
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// parseOffset converts a WHOOP timezone offset ("+05:30", "-08:00", "Z")
+// into a fixed-offset location
+func parseOffset(offset string) (*time.Location, bool) {
+	if offset == "Z" {
+		return time.UTC, true
+	}
+	if len(offset) != 6 || (offset[0] != '+' && offset[0] != '-') || offset[3] != ':' {
+		return nil, false
+	}
+
+	hours, err := strconv.Atoi(offset[1:3])
+	if err != nil || hours > 14 {
+		return nil, false
+	}
+	minutes, err := strconv.Atoi(offset[4:6])
+	if err != nil || minutes > 59 {
+		return nil, false
+	}
+
+	seconds := hours*3600 + minutes*60
+	if strings.HasPrefix(offset, "-") {
+		seconds = -seconds
+	}
+	return time.FixedZone("UTC"+offset, seconds), true
+}
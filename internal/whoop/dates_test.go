@@ -0,0 +1,91 @@
+package whoop
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // Slack time zones load without system zoneinfo
+)
+
+func TestLocalDate(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	honolulu, err := time.LoadLocation("Pacific/Honolulu")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both sides of UTC midnight on March 5
+	beforeMidnight := time.Date(2024, 3, 4, 23, 30, 0, 0, time.UTC)
+	afterMidnight := time.Date(2024, 3, 5, 0, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		t        time.Time
+		offset   string
+		fallback *time.Location
+		want     string
+	}{
+		{"Kiribati before UTC midnight", beforeMidnight, "+14:00", nil, "2024-03-05"},
+		{"Kiribati after UTC midnight", afterMidnight, "+14:00", nil, "2024-03-05"},
+		{"Samoa before UTC midnight", beforeMidnight, "-11:00", nil, "2024-03-04"},
+		{"Samoa after UTC midnight", afterMidnight, "-11:00", nil, "2024-03-04"},
+		{"India before UTC midnight", beforeMidnight, "+05:30", nil, "2024-03-05"},
+		{"India just before its midnight", time.Date(2024, 3, 4, 18, 29, 0, 0, time.UTC), "+05:30", nil, "2024-03-04"},
+		{"UTC before midnight", beforeMidnight, "Z", tokyo, "2024-03-04"},
+		{"offset wins over Slack time zone", afterMidnight, "-11:00", tokyo, "2024-03-04"},
+		{"empty offset uses Slack time zone", beforeMidnight, "", tokyo, "2024-03-05"},
+		{"empty offset uses Slack time zone west", afterMidnight, "", honolulu, "2024-03-04"},
+		{"malformed offset uses Slack time zone", beforeMidnight, "+0900", tokyo, "2024-03-05"},
+		{"out of range offset uses Slack time zone", afterMidnight, "+15:00", honolulu, "2024-03-04"},
+		{"no offset or Slack time zone uses UTC", beforeMidnight, "", nil, "2024-03-04"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := localDate(tt.t, tt.offset, tt.fallback)
+			if got.Format("2006-01-02") != tt.want {
+				t.Errorf("localDate(%s, %q) = %s, want %s", tt.t.Format(time.RFC3339), tt.offset, got.Format("2006-01-02"), tt.want)
+			}
+			if got.Location() != time.UTC || got.Hour() != 0 || got.Minute() != 0 {
+				t.Errorf("localDate returned %s, want midnight UTC", got)
+			}
+		})
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		offset  string
+		ok      bool
+		seconds int
+	}{
+		{"Z", true, 0},
+		{"+00:00", true, 0},
+		{"+14:00", true, 14 * 3600},
+		{"-11:00", true, -11 * 3600},
+		{"+05:30", true, 5*3600 + 30*60},
+		{"-09:30", true, -(9*3600 + 30*60)},
+		{"", false, 0},
+		{"05:30", false, 0},
+		{"+0530", false, 0},
+		{"+15:00", false, 0},
+		{"+05:60", false, 0},
+		{"+ab:cd", false, 0},
+		{"America/New_York", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.offset, func(t *testing.T) {
+			loc, ok := parseOffset(tt.offset)
+			if ok != tt.ok {
+				t.Fatalf("parseOffset(%q) ok = %v, want %v", tt.offset, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if _, seconds := time.Date(2024, 3, 5, 0, 0, 0, 0, loc).Zone(); seconds != tt.seconds {
+				t.Errorf("parseOffset(%q) offset = %ds, want %ds", tt.offset, seconds, tt.seconds)
+			}
+		})
+	}
+}
@@ -4,40 +4,79 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/models"
 )
+
 // MessageFormatter handles formatting WHOOP data for Slack messages
-type MessageFormatter struct{}
+type MessageFormatter struct {
+	minSharing int
+}
 
 // NewMessageFormatter creates a new message formatter
 func NewMessageFormatter() *MessageFormatter {
 	return &MessageFormatter{}
 }
 
+// SetMinSharing puts the standup in aggregate-only mode whenever fewer than n
+// teammates share individual stats; 0 always shows individual rows. Team
+// averages also need at least n, and never fewer than two, full sharers.
+func (f *MessageFormatter) SetMinSharing(n int) {
+	f.minSharing = n
+}
+
 // FormatMorningStandup creates a comprehensive morning standup message
-func (f *MessageFormatter) FormatMorningStandup(teamData []map[string]interface{}) string {
+func (f *MessageFormatter) FormatMorningStandup(teamData []models.StandupEntry) string {
 	if len(teamData) == 0 {
 		return "🌅 *Good Morning Team!* 🌅\n\nNo WHOOP data available yet. Connect your WHOOP accounts with `/connect-whoop` to see your daily stats! 🚀"
 	}
 
 	var message strings.Builder
-	
+
 	// Header
 	message.WriteString("🌅 *Good Morning Team! Here's how everyone's feeling today:* 🌅\n\n")
 
-	// Team summary stats
-	teamSummary := f.calculateTeamSummary(teamData)
-	message.WriteString(fmt.Sprintf("📊 *Team Overview:* %s\n", teamSummary.emoji))
-	message.WriteString(fmt.Sprintf("• Average Recovery: %s\n", teamSummary.avgRecovery))
-	message.WriteString(fmt.Sprintf("• Average Sleep Score: %s\n", teamSummary.avgSleep))
-	message.WriteString(fmt.Sprintf("• Team Sleep Hours: %s\n\n", teamSummary.totalSleep))
-
-	// Individual stats
-	message.WriteString("👥 *Individual Stats:*\n")
-	
-	for _, userData := range teamData {
-		userMsg := f.formatUserData(userData)
-		message.WriteString(userMsg)
-		message.WriteString("\n")
+	// Team summary stats, from full sharers only and only when enough of them
+	// have data that the averages can't be worked back to one person
+	fullSharers := f.fullSharers(teamData)
+	teamSummary := f.calculateTeamSummary(fullSharers)
+	if f.countWithData(fullSharers) >= f.minAggregate() {
+		message.WriteString(fmt.Sprintf("📊 *Team Overview:* %s\n", teamSummary.emoji))
+		message.WriteString(fmt.Sprintf("• Average Recovery: %s\n", teamSummary.avgRecovery))
+		message.WriteString(fmt.Sprintf("• Average Sleep Score: %s\n", teamSummary.avgSleep))
+		message.WriteString(fmt.Sprintf("• Team Sleep Hours: %s\n\n", teamSummary.totalSleep))
+	} else {
+		teamSummary = TeamSummary{}
+		message.WriteString("📊 *Team Overview:* Not enough teammates synced yet to share team averages.\n\n")
+	}
+
+	// Individual stats, respecting each user's privacy level
+	sharing := 0
+	for _, entry := range teamData {
+		if entry.Privacy == models.PrivacyFull || entry.Privacy == models.PrivacyColors {
+			sharing++
+		}
+	}
+
+	if sharing < f.minSharing {
+		message.WriteString(fmt.Sprintf("👥 *Individual Stats:* hidden until at least %d teammates share theirs. %d checked in today! 🙌\n",
+			f.minSharing, f.countWithData(teamData)))
+	} else {
+		message.WriteString("👥 *Individual Stats:*\n")
+
+		for _, entry := range teamData {
+			var userMsg string
+			switch entry.Privacy {
+			case models.PrivacyColors:
+				userMsg = f.formatUserColors(entry)
+			case models.PrivacyParticipation:
+				userMsg = f.formatUserParticipation(entry)
+			default:
+				userMsg = f.formatUserData(entry)
+			}
+			message.WriteString(userMsg)
+			message.WriteString("\n")
+		}
 	}
 
 	// Motivational footer
@@ -47,39 +86,52 @@ func (f *MessageFormatter) FormatMorningStandup(teamData []map[string]interface{
 	return message.String()
 }
 
+// minAggregate is the fewest full sharers team averages may be computed
+// from. With one, the average is their score; with more than one but fewer
+// than minSharing, it would reveal what minSharing is meant to hide.
+func (f *MessageFormatter) minAggregate() int {
+	return max(f.minSharing, 2)
+}
+
+// fullSharers returns the entries of users sharing their full stats, the
+// only ones whose numbers go into team averages
+func (f *MessageFormatter) fullSharers(teamData []models.StandupEntry) []models.StandupEntry {
+	var entries []models.StandupEntry
+	for _, entry := range teamData {
+		if entry.Privacy == models.PrivacyFull {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
 // TeamSummary holds aggregated team statistics
 type TeamSummary struct {
-	avgRecovery  string
-	avgSleep     string
-	totalSleep   string
-	emoji        string
-	recoveryNum  float64
-	sleepNum     float64
+	avgRecovery string
+	avgSleep    string
+	totalSleep  string
+	emoji       string
+	recoveryNum float64
+	sleepNum    float64
 }
 
 // calculateTeamSummary computes team-wide statistics
-func (f *MessageFormatter) calculateTeamSummary(teamData []map[string]interface{}) TeamSummary {
+func (f *MessageFormatter) calculateTeamSummary(teamData []models.StandupEntry) TeamSummary {
 	var recoveryScores []float64
 	var sleepScores []float64
 	var totalSleepHours float64
 
-	for _, userData := range teamData {
-		if recoveryScore, ok := userData["recovery_score"]; ok && recoveryScore != nil {
-			if score, ok := recoveryScore.(int64); ok {
-				recoveryScores = append(recoveryScores, float64(score))
-			}
-		}
-		
-		if sleepScore, ok := userData["sleep_score"]; ok && sleepScore != nil {
-			if score, ok := sleepScore.(int64); ok {
-				sleepScores = append(sleepScores, float64(score))
-			}
+	// Unscored records hold placeholder zeros, so they stay out of the averages
+	for _, entry := range teamData {
+		if entry.Recovery != nil && entry.Recovery.Scored() {
+			recoveryScores = append(recoveryScores, float64(entry.Recovery.Score))
 		}
 
-		if durationMS, ok := userData["duration_ms"]; ok && durationMS != nil {
-			if duration, ok := durationMS.(int64); ok {
-				totalSleepHours += float64(duration) / (1000 * 60 * 60) // Convert ms to hours
+		if entry.Sleep != nil && entry.Sleep.Scored() {
+			if entry.Sleep.HasScore() {
+				sleepScores = append(sleepScores, float64(entry.Sleep.Score))
 			}
+			totalSleepHours += float64(entry.Sleep.DurationMS) / (1000 * 60 * 60) // Convert ms to hours
 		}
 	}
 
@@ -116,49 +168,38 @@ func (f *MessageFormatter) calculateTeamSummary(teamData []map[string]interface{
 }
 
 // formatUserData creates a formatted string for a single user's data
-func (f *MessageFormatter) formatUserData(userData map[string]interface{}) string {
-	username := f.getString(userData, "username")
-	realName := f.getString(userData, "real_name")
-	
-	// Use real name if available, otherwise username
-	displayName := realName
-	if displayName == "" {
-		displayName = username
-	}
-
+func (f *MessageFormatter) formatUserData(entry models.StandupEntry) string {
 	var parts []string
 
 	// Recovery data
-	if recoveryScore, exists := userData["recovery_score"]; exists && recoveryScore != nil {
-		score := int(f.getInt64(userData, "recovery_score"))
-		hrv := f.getInt64(userData, "hrv")
-		rhr := f.getInt64(userData, "rhr")
-		
-		recoveryEmoji := f.getRecoveryEmoji(score)
-		recoveryText := fmt.Sprintf("Recovery: %s %d%%", recoveryEmoji, score)
-		
-		if hrv > 0 && rhr > 0 {
-			recoveryText += fmt.Sprintf(" (HRV: %.1fms, RHR: %dbpm)", float64(hrv), rhr)
+	if recovery := entry.Recovery; recovery != nil && !recovery.Scored() {
+		parts = append(parts, "Recovery: "+f.unscoredText(recovery.ScoreState))
+	} else if recovery != nil {
+		recoveryEmoji := f.getRecoveryEmoji(recovery.Score)
+		recoveryText := fmt.Sprintf("Recovery: %s %d%%", recoveryEmoji, recovery.Score)
+
+		if recovery.HRV > 0 && recovery.RHR > 0 {
+			recoveryText += fmt.Sprintf(" (HRV: %.1fms, RHR: %dbpm)", recovery.HRV, recovery.RHR)
 		}
-		
+
 		parts = append(parts, recoveryText)
 	}
 
 	// Sleep data
-	if sleepScore, exists := userData["sleep_score"]; exists && sleepScore != nil {
-		score := int(f.getInt64(userData, "sleep_score"))
-		durationMS := f.getInt64(userData, "duration_ms")
-		efficiency := f.getFloat64(userData, "efficiency")
-		
-		sleepEmoji := f.getSleepEmoji(score)
-		sleepHours := float64(durationMS) / (1000 * 60 * 60)
-		sleepText := fmt.Sprintf("Sleep: %s %d%% (%.1fh", sleepEmoji, score, sleepHours)
-		
-		if efficiency > 0 {
-			sleepText += fmt.Sprintf(", %.0f%% eff", efficiency)
+	if sleep := entry.Sleep; sleep != nil && !sleep.Scored() {
+		parts = append(parts, "Sleep: "+f.unscoredText(sleep.ScoreState))
+	} else if sleep != nil {
+		sleepHours := float64(sleep.DurationMS) / (1000 * 60 * 60)
+		sleepText := fmt.Sprintf("Sleep: 🛌 (%.1fh", sleepHours)
+		if sleep.HasScore() {
+			sleepText = fmt.Sprintf("Sleep: %s %d%% (%.1fh", f.getSleepEmoji(sleep.Score), sleep.Score, sleepHours)
+		}
+
+		if sleep.Efficiency > 0 {
+			sleepText += fmt.Sprintf(", %.0f%% eff", sleep.Efficiency)
 		}
 		sleepText += ")"
-		
+
 		parts = append(parts, sleepText)
 	}
 
@@ -169,36 +210,55 @@ func (f *MessageFormatter) formatUserData(userData map[string]interface{}) strin
 
 	// Combine all parts
 	dataText := strings.Join(parts, " • ")
-	
-	return fmt.Sprintf("• **%s:** %s", displayName, dataText)
+	if entry.Calibrating() {
+		dataText += " _(WHOOP is still calibrating)_"
+	}
+
+	return fmt.Sprintf("• **%s:** %s", entry.DisplayName(), dataText)
 }
 
-// Helper functions for data extraction
-func (f *MessageFormatter) getString(data map[string]interface{}, key string) string {
-	if val, exists := data[key]; exists && val != nil {
-		if str, ok := val.(string); ok {
-			return str
-		}
+// formatUserColors shows a user's recovery color and sleep emoji without any numbers
+func (f *MessageFormatter) formatUserColors(entry models.StandupEntry) string {
+	var parts []string
+	if recovery := entry.Recovery; recovery != nil && recovery.Scored() {
+		parts = append(parts, "Recovery: "+f.getRecoveryEmoji(recovery.Score))
 	}
-	return ""
+	if sleep := entry.Sleep; sleep != nil && sleep.HasScore() {
+		parts = append(parts, "Sleep: "+f.getSleepEmoji(sleep.Score))
+	}
+
+	if len(parts) == 0 {
+		parts = append(parts, "No recent data 📊")
+	}
+
+	return fmt.Sprintf("• **%s:** %s", entry.DisplayName(), strings.Join(parts, " • "))
 }
 
-func (f *MessageFormatter) getInt64(data map[string]interface{}, key string) int64 {
-	if val, exists := data[key]; exists && val != nil {
-		if num, ok := val.(int64); ok {
-			return num
-		}
+// formatUserParticipation only shows whether a user has recent data
+func (f *MessageFormatter) formatUserParticipation(entry models.StandupEntry) string {
+	if entry.HasData() {
+		return fmt.Sprintf("• **%s:** ✅ Checked in", entry.DisplayName())
 	}
-	return 0
+	return fmt.Sprintf("• **%s:** No recent data 📊", entry.DisplayName())
 }
 
-func (f *MessageFormatter) getFloat64(data map[string]interface{}, key string) float64 {
-	if val, exists := data[key]; exists && val != nil {
-		if num, ok := val.(float64); ok {
-			return num
+// countWithData counts entries contributing a scored recovery or sleep to the team summary
+func (f *MessageFormatter) countWithData(teamData []models.StandupEntry) int {
+	count := 0
+	for _, entry := range teamData {
+		if (entry.Recovery != nil && entry.Recovery.Scored()) || (entry.Sleep != nil && entry.Sleep.Scored()) {
+			count++
 		}
 	}
-	return 0.0
+	return count
+}
+
+// unscoredText describes a record WHOOP hasn't produced a score for
+func (f *MessageFormatter) unscoredText(scoreState string) string {
+	if scoreState == models.ScoreStateUnscorable {
+		return "⚪ couldn't be scored"
+	}
+	return "⏳ not yet scored"
 }
 
 // Emoji and formatting functions
@@ -230,7 +290,7 @@ func (f *MessageFormatter) getSleepEmoji(score int) string {
 
 func (f *MessageFormatter) getTeamMoodEmoji(avgRecovery, avgSleep float64) string {
 	avgScore := (avgRecovery + avgSleep) / 2
-	
+
 	switch {
 	case avgScore >= 75:
 		return "🔥 Team is ON FIRE!"
@@ -255,7 +315,7 @@ func (f *MessageFormatter) formatScore(score float64) string {
 func (f *MessageFormatter) generateMotivationalFooter(summary TeamSummary) string {
 	currentTime := time.Now()
 	weekday := currentTime.Weekday()
-	
+
 	var dayMessage string
 	switch weekday {
 	case time.Monday:
@@ -284,93 +344,227 @@ func (f *MessageFormatter) generateMotivationalFooter(summary TeamSummary) strin
 		performanceMsg = "Take it easy today and focus on recovery!"
 	}
 
-	footer := fmt.Sprintf("🌟 %s %s\n\n_💡 Pro tip: Use `/whoop-status` to check individual stats or `/morning-report` for a fresh update!_", 
+	footer := fmt.Sprintf("🌟 %s %s\n\n_💡 Pro tip: Use `/whoop-status` to check individual stats or `/morning-report` for a fresh update!_",
 		dayMessage, performanceMsg)
 
 	return footer
 }
 
-// FormatUserStatus creates a detailed status message for an individual user
-func (f *MessageFormatter) FormatUserStatus(userData map[string]interface{}) string {
-	username := f.getString(userData, "username")
-	realName := f.getString(userData, "real_name")
-	
-	displayName := realName
-	if displayName == "" {
-		displayName = username
+// FormatUserStatus creates a detailed status message for an individual user.
+// When baseline is set, each scored value is compared against it.
+func (f *MessageFormatter) FormatUserStatus(entry models.StandupEntry, baseline *models.WHOOPAverages) string {
+	if baseline == nil {
+		baseline = &models.WHOOPAverages{}
 	}
 
 	var message strings.Builder
-	message.WriteString(fmt.Sprintf("📊 *WHOOP Status for %s*\n\n", displayName))
+	message.WriteString(fmt.Sprintf("📊 *WHOOP Status for %s*\n\n", entry.DisplayName()))
 
 	// Recovery section
-	if recoveryScore, exists := userData["recovery_score"]; exists && recoveryScore != nil {
-		score := int(f.getInt64(userData, "recovery_score"))
-		hrv := f.getInt64(userData, "hrv")
-		rhr := f.getInt64(userData, "rhr")
-		recoveryDate := f.getString(userData, "recovery_date")
-		
-		recoveryEmoji := f.getRecoveryEmoji(score)
-		message.WriteString(fmt.Sprintf("🔋 *Recovery:* %s %d%%\n", recoveryEmoji, score))
-		if hrv > 0 {
-			message.WriteString(fmt.Sprintf("   • HRV: %.1fms\n", float64(hrv)))
-		}
-		if rhr > 0 {
-			message.WriteString(fmt.Sprintf("   • Resting HR: %d bpm\n", rhr))
-		}
-		if recoveryDate != "" {
-			message.WriteString(fmt.Sprintf("   • Date: %s\n", recoveryDate))
+	if recovery := entry.Recovery; recovery != nil {
+		if recovery.Scored() {
+			recoveryEmoji := f.getRecoveryEmoji(recovery.Score)
+			message.WriteString(fmt.Sprintf("🔋 *Recovery:* %s %d%%%s\n", recoveryEmoji, recovery.Score,
+				f.formatBaselineDelta(float64(recovery.Score), baseline.Recovery, baseline.RecoveryDays, baseline.Days)))
+			if recovery.HRV > 0 {
+				message.WriteString(fmt.Sprintf("   • HRV: %.1fms%s\n", recovery.HRV,
+					f.formatBaselineDelta(recovery.HRV, baseline.HRV, baseline.RecoveryDays, baseline.Days)))
+			}
+			if recovery.RHR > 0 {
+				message.WriteString(fmt.Sprintf("   • Resting HR: %d bpm%s\n", recovery.RHR,
+					f.formatBaselineDelta(float64(recovery.RHR), baseline.RHR, baseline.RecoveryDays, baseline.Days)))
+			}
+		} else {
+			message.WriteString(fmt.Sprintf("🔋 *Recovery:* %s\n", f.unscoredText(recovery.ScoreState)))
 		}
+		message.WriteString(fmt.Sprintf("   • Date: %s\n", recovery.Date.Format("2006-01-02")))
 		message.WriteString("\n")
 	}
 
 	// Sleep section
-	if sleepScore, exists := userData["sleep_score"]; exists && sleepScore != nil {
-		score := int(f.getInt64(userData, "sleep_score"))
-		durationMS := f.getInt64(userData, "duration_ms")
-		efficiency := f.getFloat64(userData, "efficiency")
-		sleepDate := f.getString(userData, "sleep_date")
-		
-		sleepEmoji := f.getSleepEmoji(score)
-		sleepHours := float64(durationMS) / (1000 * 60 * 60)
-		
-		message.WriteString(fmt.Sprintf("😴 *Sleep:* %s %d%%\n", sleepEmoji, score))
-		message.WriteString(fmt.Sprintf("   • Duration: %.1f hours\n", sleepHours))
-		if efficiency > 0 {
-			message.WriteString(fmt.Sprintf("   • Efficiency: %.0f%%\n", efficiency))
-		}
-		if sleepDate != "" {
-			message.WriteString(fmt.Sprintf("   • Date: %s\n", sleepDate))
+	if sleep := entry.Sleep; sleep != nil {
+		if sleep.Scored() {
+			sleepHours := float64(sleep.DurationMS) / (1000 * 60 * 60)
+
+			if sleep.HasScore() {
+				message.WriteString(fmt.Sprintf("😴 *Sleep:* %s %d%%\n", f.getSleepEmoji(sleep.Score), sleep.Score))
+			} else {
+				message.WriteString("😴 *Sleep:* 🛌\n")
+			}
+			message.WriteString(fmt.Sprintf("   • Duration: %.1f hours%s\n", sleepHours,
+				f.formatBaselineDelta(sleepHours, baseline.SleepHours, baseline.SleepDays, baseline.Days)))
+			if sleep.Efficiency > 0 {
+				message.WriteString(fmt.Sprintf("   • Efficiency: %.0f%%\n", sleep.Efficiency))
+			}
+		} else {
+			message.WriteString(fmt.Sprintf("😴 *Sleep:* %s\n", f.unscoredText(sleep.ScoreState)))
 		}
+		message.WriteString(fmt.Sprintf("   • Date: %s\n", sleep.Date.Format("2006-01-02")))
 		message.WriteString("\n")
 	}
 
 	// Strain section (if available)
-	if strainScore, exists := userData["strain_score"]; exists && strainScore != nil {
-		score := f.getFloat64(userData, "strain_score")
-		strainDate := f.getString(userData, "strain_date")
-		
-		message.WriteString(fmt.Sprintf("💪 *Strain:* %.1f\n", score))
-		if strainDate != "" {
-			message.WriteString(fmt.Sprintf("   • Date: %s\n", strainDate))
+	if strain := entry.Strain; strain != nil {
+		if strain.Scored() {
+			message.WriteString(fmt.Sprintf("💪 *Strain:* %.1f%s\n", strain.Score,
+				f.formatBaselineDelta(strain.Score, baseline.Strain, baseline.StrainDays, baseline.Days)))
+		} else {
+			message.WriteString(fmt.Sprintf("💪 *Strain:* %s\n", f.unscoredText(strain.ScoreState)))
 		}
+		message.WriteString(fmt.Sprintf("   • Date: %s\n", strain.Date.Format("2006-01-02")))
 		message.WriteString("\n")
 	}
 
 	// If no data
-	hasData := false
-	for _, key := range []string{"recovery_score", "sleep_score", "strain_score"} {
-		if _, exists := userData[key]; exists {
-			hasData = true
-			break
+	if !entry.HasData() {
+		message.WriteString("No WHOOP data available. Make sure your WHOOP account is connected!\n\n")
+	} else if entry.Calibrating() {
+		message.WriteString("🧪 _WHOOP is still calibrating to your baselines, so scores may shift over the first few weeks._\n\n")
+	}
+
+	message.WriteString("_Use `/whoop-trends` for your averages or `/morning-report` for team stats!_")
+
+	return message.String()
+}
+
+// formatBaselineDelta renders value relative to a days-long baseline average
+// built from count scored records, e.g. " (+8% vs 30-day avg)". It is empty
+// when there is no baseline data to compare against.
+func (f *MessageFormatter) formatBaselineDelta(value, avg float64, count, days int) string {
+	if count == 0 || avg <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.0f%% vs %d-day avg)", (value-avg)/avg*100, days)
+}
+
+// FormatTrends summarizes a user's averages over a window next to their
+// personal baseline and the team's averages for the same window
+func (f *MessageFormatter) FormatTrends(displayName string, user, baseline, team *models.WHOOPAverages) string {
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("📈 *WHOOP Trends for %s — last %d days*\n\n", displayName, user.Days))
+
+	// A baseline no longer than the window would only be compared with itself
+	if baseline == nil || baseline.Days <= user.Days {
+		baseline = &models.WHOOPAverages{}
+	}
+
+	if user.RecoveryDays == 0 && user.SleepDays == 0 && user.StrainDays == 0 {
+		message.WriteString("No scored WHOOP data in this period yet. Check back after a few synced days! 📊\n\n")
+	} else {
+		if user.RecoveryDays > 0 {
+			message.WriteString(fmt.Sprintf("🔋 *Recovery:* %s %.0f%%%s\n", f.getRecoveryEmoji(int(user.Recovery)), user.Recovery,
+				f.formatBaselineDelta(user.Recovery, baseline.Recovery, baseline.RecoveryDays, baseline.Days)))
+			message.WriteString(fmt.Sprintf("   • HRV: %.1fms%s\n", user.HRV,
+				f.formatBaselineDelta(user.HRV, baseline.HRV, baseline.RecoveryDays, baseline.Days)))
+			message.WriteString(fmt.Sprintf("   • Resting HR: %.0f bpm%s\n", user.RHR,
+				f.formatBaselineDelta(user.RHR, baseline.RHR, baseline.RecoveryDays, baseline.Days)))
+		}
+		if user.SleepDays > 0 {
+			message.WriteString(fmt.Sprintf("😴 *Sleep:* %.1f hours/night%s\n", user.SleepHours,
+				f.formatBaselineDelta(user.SleepHours, baseline.SleepHours, baseline.SleepDays, baseline.Days)))
+		}
+		if user.StrainDays > 0 {
+			message.WriteString(fmt.Sprintf("💪 *Strain:* %.1f%s\n", user.Strain,
+				f.formatBaselineDelta(user.Strain, baseline.Strain, baseline.StrainDays, baseline.Days)))
 		}
+		message.WriteString(fmt.Sprintf("_Based on %d recoveries, %d sleeps and %d strain days._\n\n",
+			user.RecoveryDays, user.SleepDays, user.StrainDays))
 	}
-	
-	if !hasData {
-		message.WriteString("No WHOOP data available. Make sure your WHOOP account is connected!\n\n")
+
+	// Each team average is shown only when enough full sharers contribute to it
+	if team != nil {
+		var parts []string
+		if team.RecoveryUsers >= f.minAggregate() {
+			parts = append(parts, fmt.Sprintf("Recovery %.0f%%", team.Recovery),
+				fmt.Sprintf("HRV %.1fms", team.HRV), fmt.Sprintf("RHR %.0fbpm", team.RHR))
+		}
+		if team.SleepUsers >= f.minAggregate() {
+			parts = append(parts, fmt.Sprintf("Sleep %.1fh", team.SleepHours))
+		}
+		if team.StrainUsers >= f.minAggregate() {
+			parts = append(parts, fmt.Sprintf("Strain %.1f", team.Strain))
+		}
+		if len(parts) > 0 {
+			message.WriteString(fmt.Sprintf("👥 *Team %d-day avg:* %s\n\n", team.Days, strings.Join(parts, " • ")))
+		}
 	}
 
-	message.WriteString("_Use `/connect-whoop` to link your account or `/morning-report` for team stats!_")
-	
+	message.WriteString("_Use `/whoop-trends 30` to look further back or `/whoop-status` for today!_")
+
 	return message.String()
-}
\ No newline at end of file
+}
+
+// describeChallenge names a challenge and what it rewards
+func (f *MessageFormatter) describeChallenge(challenge *models.WHOOPChallenge) string {
+	switch challenge.Metric {
+	case models.ChallengeSleep:
+		return fmt.Sprintf("😴 *Sleep Challenge #%d*: highest average sleep", challenge.ID)
+	case models.ChallengeGreen:
+		return fmt.Sprintf("🟢 *Green Zone Challenge #%d*: most days with %d%%+ recovery", challenge.ID, GreenRecoveryThreshold)
+	case models.ChallengeStrain:
+		return fmt.Sprintf("💪 *Strain Challenge #%d*: most days reaching %.1f strain", challenge.ID, challenge.Goal)
+	default:
+		return fmt.Sprintf("*Challenge #%d*", challenge.ID)
+	}
+}
+
+// formatStanding renders a participant's score in the challenge's units
+func (f *MessageFormatter) formatStanding(challenge *models.WHOOPChallenge, standing models.ChallengeStanding) string {
+	if standing.Days == 0 {
+		return "no synced data yet"
+	}
+	switch challenge.Metric {
+	case models.ChallengeSleep:
+		return fmt.Sprintf("%.1fh avg (%d nights)", standing.Score, standing.Days)
+	case models.ChallengeGreen:
+		return fmt.Sprintf("%.0f green days (of %d)", standing.Score, standing.Days)
+	default:
+		return fmt.Sprintf("%.0f days at goal (of %d)", standing.Score, standing.Days)
+	}
+}
+
+// FormatChallengeSummary describes an active challenge for listings
+func (f *MessageFormatter) FormatChallengeSummary(challenge *models.WHOOPChallenge, participants int) string {
+	return fmt.Sprintf("%s • %s to %s • %d joined",
+		f.describeChallenge(challenge), challenge.StartDate.Format("Jan 2"), challenge.EndDate.Format("Jan 2"), participants)
+}
+
+// FormatChallengeStandings creates a challenge leaderboard. Progress posts
+// show the day count and how to join; final posts crown the winner.
+func (f *MessageFormatter) FormatChallengeStandings(challenge *models.WHOOPChallenge, standings []models.ChallengeStanding, final bool, now time.Time) string {
+	var message strings.Builder
+
+	if final {
+		message.WriteString(fmt.Sprintf("🏆 *Final results!* %s\n\n", f.describeChallenge(challenge)))
+	} else {
+		totalDays := int(challenge.EndDate.Sub(challenge.StartDate).Hours()/24) + 1
+		year, month, date := now.Date()
+		today := time.Date(year, month, date, 0, 0, 0, 0, time.UTC)
+		day := int(today.Sub(challenge.StartDate).Hours()/24) + 1
+		day = max(1, min(day, totalDays))
+		message.WriteString(fmt.Sprintf("📣 %s\nDay %d of %d, ends %s\n\n", f.describeChallenge(challenge), day, totalDays, challenge.EndDate.Format("Mon Jan 2")))
+	}
+
+	if len(standings) == 0 {
+		message.WriteString("Nobody has joined yet!\n")
+	}
+
+	medals := []string{"🥇", "🥈", "🥉"}
+	for i, standing := range standings {
+		place := fmt.Sprintf("%d.", i+1)
+		if i < len(medals) && standing.Days > 0 {
+			place = medals[i]
+		}
+		message.WriteString(fmt.Sprintf("%s %s: %s\n", place, standing.DisplayName(), f.formatStanding(challenge, standing)))
+	}
+
+	if final {
+		if len(standings) > 0 && standings[0].Days > 0 {
+			message.WriteString(fmt.Sprintf("\n🎉 Congratulations <@%s>! Thanks to everyone who took part! 💪", standings[0].UserID))
+		}
+	} else {
+		message.WriteString(fmt.Sprintf("\n_Join in with `/whoop-challenge join %d`_", challenge.ID))
+	}
+
+	return message.String()
+}
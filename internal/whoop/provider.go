@@ -0,0 +1,186 @@
+package whoop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/models"
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
+)
+
+// ProviderName identifies WHOOP connections in the database
+const ProviderName = "whoop"
+
+// Provider adapts the WHOOP client to wearables.Provider
+type Provider struct {
+	client *Client
+}
+
+// NewProvider creates a WHOOP provider backed by client
+func NewProvider(client *Client) *Provider {
+	return &Provider{client: client}
+}
+
+// Client returns the underlying WHOOP API client
+func (p *Provider) Client() *Client {
+	return p.client
+}
+
+// Name returns the provider identifier
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// DisplayName returns the provider name shown to users
+func (p *Provider) DisplayName() string {
+	return "WHOOP"
+}
+
+// AuthURL returns the WHOOP OAuth authorization URL
+func (p *Provider) AuthURL(state string) string {
+	return p.client.GetAuthURL(state)
+}
+
+// ExchangeCode trades an authorization code for tokens
+func (p *Provider) ExchangeCode(ctx context.Context, code string) (*wearables.Token, error) {
+	tokenResp, err := p.client.ExchangeCodeForToken(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return newToken(tokenResp), nil
+}
+
+// RefreshToken trades a refresh token for new tokens
+func (p *Provider) RefreshToken(ctx context.Context, refreshToken string) (*wearables.Token, error) {
+	tokenResp, err := p.client.RefreshAccessToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return newToken(tokenResp), nil
+}
+
+// UserID returns the WHOOP user ID of the token's owner
+func (p *Provider) UserID(ctx context.Context, accessToken string) (string, error) {
+	profile, err := p.client.GetUserProfile(ctx, accessToken)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d", profile.UserID), nil
+}
+
+// Revoke revokes the user's grant with WHOOP
+func (p *Provider) Revoke(ctx context.Context, accessToken string) error {
+	return p.client.RevokeAccess(ctx, accessToken)
+}
+
+// Fetch returns WHOOP sleeps, recoveries and strain between start and end.
+// Recoveries carry no timezone of their own, so sleeps go first and their
+// offsets are reused for the recovery that follows each sleep. An
+// unauthorized or rate limited response stops the fetch; other failures are
+// joined and returned alongside whatever was fetched.
+func (p *Provider) Fetch(ctx context.Context, accessToken string, start, end time.Time, loc *time.Location) (*wearables.Data, error) {
+	data := &wearables.Data{}
+	var errs []error
+	fatal := func(err error) bool {
+		return errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrRateLimited)
+	}
+
+	sleepOffsets := make(map[int64]string)
+	sleepResp, err := p.client.GetSleep(ctx, accessToken, start, end)
+	if fatal(err) {
+		return data, err
+	} else if err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, sleep := range sleepResp.Records {
+			sleepOffsets[sleep.ID] = sleep.TimezoneOffset
+			data.Sleeps = append(data.Sleeps, normalizeSleep(sleep, loc))
+		}
+	}
+
+	recoveryResp, err := p.client.GetRecovery(ctx, accessToken, start, end)
+	if fatal(err) {
+		return data, err
+	} else if err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, recovery := range recoveryResp.Records {
+			data.Recoveries = append(data.Recoveries, normalizeRecovery(recovery, sleepOffsets[recovery.SleepID], loc))
+		}
+	}
+
+	cycleResp, err := p.client.GetCycles(ctx, accessToken, start, end)
+	if fatal(err) {
+		return data, err
+	} else if err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, cycle := range cycleResp.Records {
+			data.Activities = append(data.Activities, normalizeCycle(cycle, loc))
+		}
+	}
+
+	return data, errors.Join(errs...)
+}
+
+// newToken converts a WHOOP token response
+func newToken(tokenResp *TokenResponse) *wearables.Token {
+	return &wearables.Token{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}
+}
+
+// normalizeRecovery converts a WHOOP recovery. The offset comes from the
+// sleep the recovery was scored from; if it's unknown loc is used instead.
+func normalizeRecovery(recovery RecoveryData, offset string, loc *time.Location) wearables.Recovery {
+	return wearables.Recovery{
+		// A recovery is created when the user wakes up, so it belongs to that local day
+		Date:        localDate(recovery.CreatedAt, offset, loc),
+		ScoreState:  scoreState(recovery.ScoreState),
+		Calibrating: recovery.Score.UserCalibrating,
+		Score:       int(recovery.Score.RecoveryScore),
+		HRV:         recovery.Score.HRVRmssd,
+		RHR:         int(recovery.Score.RestingHR),
+	}
+}
+
+// normalizeSleep converts a WHOOP sleep
+func normalizeSleep(sleep SleepData, loc *time.Location) wearables.Sleep {
+	stages := sleep.Score.Stage_summary
+	return wearables.Sleep{
+		// A night's sleep belongs to the local day the user woke up on
+		Date:          localDate(sleep.End, sleep.TimezoneOffset, loc),
+		ScoreState:    scoreState(sleep.ScoreState),
+		DurationMS:    stages.TotalLightSleepMS + stages.TotalSlowWaveSleepMS + stages.TotalRemSleepMS,
+		Efficiency:    sleep.Score.SleepEfficiencyPercentage,
+		Score:         sleep.Score.SleepScore,
+		StagesDeepMS:  stages.TotalSlowWaveSleepMS,
+		StagesREMMS:   stages.TotalRemSleepMS,
+		StagesLightMS: stages.TotalLightSleepMS,
+		StagesWakeMS:  stages.TotalAwakeTimeMS,
+	}
+}
+
+// normalizeCycle converts a WHOOP cycle into the day's strain
+func normalizeCycle(cycle CycleData, loc *time.Location) wearables.Activity {
+	return wearables.Activity{
+		Date:       localDate(cycle.Start, cycle.TimezoneOffset, loc),
+		ScoreState: scoreState(cycle.ScoreState),
+		Strain:     cycle.Score.Strain,
+	}
+}
+
+// scoreState normalizes WHOOP's score_state, treating anything unrecognized
+// as pending so it is fetched again rather than shown as a real score
+func scoreState(state string) string {
+	switch state {
+	case models.ScoreStateScored, models.ScoreStateUnscorable:
+		return state
+	default:
+		return models.ScoreStatePending
+	}
+}
@@ -0,0 +1,199 @@
+package whoop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
+)
+
+const (
+	// maxAttempts is how many times a request is sent before giving up on
+	// rate limiting, server errors or network failures
+	maxAttempts = 4
+	// retryBaseDelay is the backoff before the first retry; it doubles each attempt
+	retryBaseDelay = 500 * time.Millisecond
+	// maxRetryAfter caps how long we are willing to wait on a 429's Retry-After
+	maxRetryAfter = 2 * time.Minute
+)
+
+var (
+	// ErrUnauthorized is returned when WHOOP rejects the access token
+	ErrUnauthorized = wearables.ErrUnauthorized
+	// ErrRateLimited is returned when WHOOP keeps answering 429 after retries
+	ErrRateLimited = wearables.ErrRateLimited
+)
+
+// APIError is returned for non-2xx responses from the WHOOP API
+type APIError struct {
+	StatusCode int
+	Endpoint   string
+	RetryAfter time.Duration // set for 429 responses that carried Retry-After
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Endpoint, e.StatusCode, e.Body)
+}
+
+// Is lets callers match API errors against ErrUnauthorized and ErrRateLimited
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// send executes the request produced by newRequest, waiting on the client-side
+// rate limiter before every attempt. Network errors, 429s and 5xx responses
+// are retried with exponential backoff and jitter (429s wait for Retry-After
+// when WHOOP provides one). The final response is returned whatever its
+// status; the caller must close its body.
+func (c *Client) send(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, error) {
+	return c.sendRetrying(ctx, true, newRequest)
+}
+
+// sendToken is send for token requests, which retries only 429s and 5xx
+// responses: WHOOP answered those without issuing a token. A network error
+// leaves the outcome unknown, and since WHOOP rotates the refresh token on
+// every successful refresh, resending it could spend the new one.
+func (c *Client) sendToken(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, error) {
+	return c.sendRetrying(ctx, false, newRequest)
+}
+
+// sendRetrying is send, retrying network errors only if retryNetwork is set
+func (c *Client) sendRetrying(ctx context.Context, retryNetwork bool, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, error) {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		for _, limiter := range c.limiters {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := newRequest(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		lastAttempt := attempt == maxAttempts
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || lastAttempt || !retryNetwork {
+				return nil, err
+			}
+			wait = jitter(delay)
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+			if lastAttempt || retryAfter > maxRetryAfter {
+				return resp, nil
+			}
+			wait = retryAfter
+			if wait == 0 {
+				wait = jitter(delay)
+			}
+		case resp.StatusCode >= 500:
+			if lastAttempt {
+				return resp, nil
+			}
+			wait = jitter(delay)
+		default:
+			return resp, nil
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// getJSON performs an authenticated GET against the WHOOP API and decodes
+// the JSON response into out
+func (c *Client) getJSON(ctx context.Context, accessToken, path string, params url.Values, out interface{}) error {
+	endpoint := c.baseURL + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	resp, err := c.send(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError("GET "+path, resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// newAPIError builds an APIError from a failed response
+func newAPIError(endpoint string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Endpoint:   endpoint,
+		Body:       string(body),
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	return apiErr
+}
+
+// parseRetryAfter understands both forms of the Retry-After header
+// (delay in seconds or an HTTP date); it returns 0 when absent or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// jitter spreads a backoff delay uniformly over [d/2, d)
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
@@ -1,64 +1,82 @@
 package whoop
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"log"
 	"net/http"
+
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
 )
 
-// OAuthServer handles WHOOP OAuth callbacks
+// maxWebhookBodySize bounds webhook payloads, which are a few hundred bytes
+const maxWebhookBodySize = 64 << 10
+
+// OAuthServer handles wearable OAuth callbacks and WHOOP webhooks
 type OAuthServer struct {
 	service *Service
-	port    string
-}
-
-// NewOAuthServer creates a new OAuth callback server
-func NewOAuthServer(service *Service, port string) *OAuthServer {
-	return &OAuthServer{
-		service: service,
-		port:    port,
-	}
 }
 
-// Start starts the HTTP server for OAuth callbacks
-func (s *OAuthServer) Start() error {
-	http.HandleFunc("/whoop/callback", s.handleCallback)
-	http.HandleFunc("/", s.handleRoot)
-	
-	log.Printf("Starting WHOOP OAuth callback server on port %s", s.port)
-	return http.ListenAndServe(":"+s.port, nil)
+// NewOAuthServer creates the OAuth callback and webhook handlers
+func NewOAuthServer(service *Service) *OAuthServer {
+	return &OAuthServer{service: service}
 }
 
-// handleCallback processes the OAuth callback
-func (s *OAuthServer) handleCallback(w http.ResponseWriter, r *http.Request) {
-	// Extract authorization code and state from query parameters
-	code := r.URL.Query().Get("code")
-	state := r.URL.Query().Get("state")
-	
-	if code == "" {
-		http.Error(w, "Missing authorization code", http.StatusBadRequest)
-		return
+// RegisterRoutes adds the OAuth routes to mux. Each registered provider gets
+// its own callback path, e.g. /whoop/callback and /oura/callback.
+func (s *OAuthServer) RegisterRoutes(mux *http.ServeMux) {
+	for _, provider := range s.service.Providers() {
+		mux.HandleFunc("/"+provider.Name()+"/callback", s.callbackHandler(provider))
 	}
-	
-	if state == "" {
-		http.Error(w, "Missing state parameter", http.StatusBadRequest)
-		return
+	if s.service.WebhooksEnabled() {
+		mux.HandleFunc("/whoop/webhook", s.handleWebhook)
 	}
-	
-	// Process the OAuth callback
-	connection, err := s.service.HandleOAuthCallback(code, state)
-	if err != nil {
-		log.Printf("OAuth callback error: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to connect WHOOP account: %v", err), http.StatusInternalServerError)
-		return
-	}
-	
-	// Send success response
-	successHTML := `
+	mux.HandleFunc("/", s.handleRoot)
+}
+
+// callbackHandler processes the OAuth callback for a provider
+func (s *OAuthServer) callbackHandler(provider wearables.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The user declined access on the provider's consent screen
+		if r.URL.Query().Get("error") != "" {
+			writeErrorPage(w, http.StatusBadRequest, provider.DisplayName()+" Not Connected",
+				fmt.Sprintf("Access wasn't granted, so nothing was connected. Run <code>/connect-%s</code> in Slack to try again.", provider.Name()))
+			return
+		}
+
+		// Extract authorization code and state from query parameters
+		code := r.URL.Query().Get("code")
+		state := r.URL.Query().Get("state")
+		if code == "" || state == "" {
+			writeErrorPage(w, http.StatusBadRequest, "Invalid Link",
+				fmt.Sprintf("This link is missing its authorization details. Run <code>/connect-%s</code> in Slack to get a new one.", provider.Name()))
+			return
+		}
+
+		// Process the OAuth callback. Internal errors are logged, never shown.
+		connection, err := s.service.HandleOAuthCallback(r.Context(), provider.Name(), code, state)
+		if errors.Is(err, ErrInvalidState) {
+			log.Printf("%s OAuth callback rejected: %v", provider.DisplayName(), err)
+			writeErrorPage(w, http.StatusBadRequest, "Invalid Link",
+				fmt.Sprintf("This link is invalid or has expired. Run <code>/connect-%s</code> in Slack to get a new one.", provider.Name()))
+			return
+		}
+		if err != nil {
+			log.Printf("%s OAuth callback error: %v", provider.DisplayName(), err)
+			writeErrorPage(w, http.StatusInternalServerError, "Connection Failed",
+				fmt.Sprintf("We couldn't connect your %s account. Please try again with <code>/connect-%s</code> in Slack.", provider.DisplayName(), provider.Name()))
+			return
+		}
+
+		// Send success response
+		successHTML := fmt.Sprintf(`
 <!DOCTYPE html>
 <html>
 <head>
-    <title>WHOOP Connected!</title>
+    <title>%[1]s Connected!</title>
     <style>
         body { font-family: Arial, sans-serif; text-align: center; padding: 50px; }
         .success { color: #28a745; }
@@ -67,35 +85,73 @@ func (s *OAuthServer) handleCallback(w http.ResponseWriter, r *http.Request) {
 </head>
 <body>
     <div class="container">
-        <h1 class="success">🎉 WHOOP Account Connected!</h1>
-        <p>Your WHOOP account has been successfully connected to FamBot.</p>
-        <p>You'll now see your sleep, recovery, and strain data in morning standups!</p>
+        <h1 class="success">🎉 %[1]s Account Connected!</h1>
+        <p>Your %[1]s account has been successfully connected to FamBot.</p>
+        <p>You'll now see your sleep and recovery data in morning standups!</p>
         <p><strong>You can close this window and return to Slack.</strong></p>
         <hr>
         <p><small>Use <code>/whoop-status</code> in Slack to check your stats or <code>/morning-report</code> for team data.</small></p>
     </div>
 </body>
-</html>`
-	
-	w.Header().Set("Content-Type", "text/html")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(successHTML))
-	
-	log.Printf("Successfully connected WHOOP account for user %s", connection.UserID)
+</html>`, provider.DisplayName())
+
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(successHTML))
+
+		log.Printf("Successfully connected %s account for user %s", provider.DisplayName(), connection.UserID)
+	}
+}
+
+// handleWebhook verifies and queues WHOOP webhook deliveries. Records are
+// fetched asynchronously so WHOOP gets its response right away.
+func (s *OAuthServer) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	err = s.service.VerifyWebhookSignature(body, r.Header.Get("X-WHOOP-Signature-Timestamp"), r.Header.Get("X-WHOOP-Signature"))
+	if err != nil {
+		log.Printf("Rejected WHOOP webhook: %v", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.service.EnqueueWebhook(event); err != nil {
+		log.Printf("Dropping WHOOP webhook %s for WHOOP user %d: %v", event.Type, event.UserID, err)
+		http.Error(w, "Busy, retry later", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // handleRoot provides basic information about the service
 func (s *OAuthServer) handleRoot(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
-		http.NotFound(w, r)
+		writeErrorPage(w, http.StatusNotFound, "Page Not Found", "There's nothing here. You can safely close this window.")
 		return
 	}
-	
+
 	infoHTML := `
 <!DOCTYPE html>
 <html>
 <head>
-    <title>FamBot WHOOP Integration</title>
+    <title>FamBot Wearables Integration</title>
     <style>
         body { font-family: Arial, sans-serif; text-align: center; padding: 50px; }
         .container { max-width: 500px; margin: 0 auto; }
@@ -103,16 +159,43 @@ func (s *OAuthServer) handleRoot(w http.ResponseWriter, r *http.Request) {
 </head>
 <body>
     <div class="container">
-        <h1>🤖 FamBot WHOOP Integration</h1>
-        <p>This is the OAuth callback endpoint for WHOOP integration.</p>
-        <p>To connect your WHOOP account, use the <code>/connect-whoop</code> command in Slack.</p>
+        <h1>🤖 FamBot Wearables Integration</h1>
+        <p>This is the OAuth callback endpoint for WHOOP and Oura integration.</p>
+        <p>To connect your device, use the <code>/connect-whoop</code> or <code>/connect-oura</code> command in Slack.</p>
         <hr>
         <p><small>If you're seeing this page unexpectedly, you can safely close it.</small></p>
     </div>
 </body>
 </html>`
-	
+
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(infoHTML))
-}
\ No newline at end of file
+}
+
+// writeErrorPage renders a browser-facing error. message may contain trusted
+// HTML; the title is escaped.
+func writeErrorPage(w http.ResponseWriter, status int, title, message string) {
+	errorHTML := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <title>%[1]s</title>
+    <style>
+        body { font-family: Arial, sans-serif; text-align: center; padding: 50px; }
+        .error { color: #dc3545; }
+        .container { max-width: 500px; margin: 0 auto; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1 class="error">%[1]s</h1>
+        <p>%[2]s</p>
+    </div>
+</body>
+</html>`, html.EscapeString(title), message)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(errorHTML))
+}
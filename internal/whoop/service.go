@@ -1,28 +1,125 @@
 package whoop
 
 import (
+	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/pratikgajjar/fambot-go/internal/database"
 	"github.com/pratikgajjar/fambot-go/internal/models"
+	"github.com/pratikgajjar/fambot-go/internal/wearables"
 )
 
-// Service handles WHOOP business logic and data synchronization
+const (
+	// tokenRefreshWindow is how long before expiry an access token gets refreshed
+	tokenRefreshWindow = time.Hour
+	// syncWorkers bounds how many users are synced concurrently
+	syncWorkers = 5
+	// pendingRefetchDays is how far back unscored records are re-fetched
+	// before we stop waiting on WHOOP to score them
+	pendingRefetchDays = 7
+	// BaselineDays is the window a user's personal baseline is averaged over
+	BaselineDays = 30
+	// userSyncTimeout bounds a single user's sync within a team-wide sync
+	userSyncTimeout = 45 * time.Second
+	// OAuthStateTTL is how long a connect link stays valid
+	OAuthStateTTL = 10 * time.Minute
+)
+
+// ErrReauthRequired is returned when a provider has revoked a user's grant
+// and the user must connect their device again
+var ErrReauthRequired = errors.New("wearable reauthorization required")
+
+// ErrDeviceConnected is returned when importing data for a user whose
+// device already syncs through a provider
+var ErrDeviceConnected = errors.New("a device is already connected")
+
+// ErrInvalidState is returned when an OAuth callback carries a state that
+// FamBot didn't issue for that provider, that has expired or that was
+// already used
+var ErrInvalidState = errors.New("invalid OAuth state")
+
+// Notifier delivers a direct message to a Slack user
+type Notifier func(userID, message string)
+
+// Service handles wearable business logic and data synchronization. Each
+// user has one connected device, served by the provider it was connected
+// through; WHOOP-specific features like webhooks need the WHOOP provider.
 type Service struct {
-	client *Client
-	db     *database.Database
+	providers map[string]wearables.Provider
+	client    *Client // WHOOP client for webhooks, nil unless WHOOP is registered
+	db        *database.Database
+	notifier  Notifier
+
+	// refreshLocks serializes token refreshes per user, since providers rotate
+	// the refresh token and a second concurrent refresh would spend a stale one
+	refreshMu    sync.Mutex
+	refreshLocks map[string]*sync.Mutex
+
+	syncMu       sync.Mutex
+	syncInFlight *syncCall
+
+	webhooks chan WebhookEvent
 }
 
-// NewService creates a new WHOOP service
-func NewService(client *Client, db *database.Database) *Service {
+// NewService creates a new wearables service with no providers registered
+func NewService(db *database.Database) *Service {
 	return &Service{
-		client: client,
-		db:     db,
+		providers:    make(map[string]wearables.Provider),
+		db:           db,
+		refreshLocks: make(map[string]*sync.Mutex),
+		webhooks:     make(chan WebhookEvent, webhookQueueSize),
+	}
+}
+
+// RegisterProvider makes a provider available for connecting and syncing
+func (s *Service) RegisterProvider(provider wearables.Provider) {
+	s.providers[provider.Name()] = provider
+	if whoopProvider, ok := provider.(*Provider); ok {
+		s.client = whoopProvider.Client()
+	}
+}
+
+// Provider returns the registered provider with the given name
+func (s *Service) Provider(name string) (wearables.Provider, bool) {
+	provider, ok := s.providers[name]
+	return provider, ok
+}
+
+// Providers returns the registered providers
+func (s *Service) Providers() []wearables.Provider {
+	providers := make([]wearables.Provider, 0, len(s.providers))
+	for _, provider := range s.providers {
+		providers = append(providers, provider)
 	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name() < providers[j].Name() })
+	return providers
+}
+
+// WebhooksEnabled reports whether WHOOP webhooks can be verified and processed
+func (s *Service) WebhooksEnabled() bool {
+	return s.client != nil
+}
+
+// providerFor returns the provider a connection was made through
+func (s *Service) providerFor(connection *models.WHOOPConnection) (wearables.Provider, error) {
+	provider, ok := s.providers[connection.ProviderName()]
+	if !ok {
+		return nil, fmt.Errorf("provider %q is not configured", connection.ProviderName())
+	}
+	return provider, nil
+}
+
+// SetNotifier sets the function used to DM users about their connection
+func (s *Service) SetNotifier(notifier Notifier) {
+	s.notifier = notifier
 }
 
 // GenerateState generates a random state string for OAuth
@@ -32,38 +129,46 @@ func (s *Service) GenerateState() string {
 	return hex.EncodeToString(bytes)
 }
 
-// GetAuthURL returns the WHOOP OAuth authorization URL with state
-func (s *Service) GetAuthURL(userID string) string {
-	state := fmt.Sprintf("%s:%s", userID, s.GenerateState())
-	return s.client.GetAuthURL(state)
+// GetAuthURL returns the named provider's OAuth authorization URL with a
+// state issued to userID. The state is stored so the callback can tell which
+// user is connecting; it expires after OAuthStateTTL and works once.
+func (s *Service) GetAuthURL(providerName, userID string) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", fmt.Errorf("provider %q is not configured", providerName)
+	}
+	state := s.GenerateState()
+	if err := s.db.CreateOAuthState(state, userID, provider.Name(), time.Now().Add(OAuthStateTTL)); err != nil {
+		return "", fmt.Errorf("failed to store OAuth state: %w", err)
+	}
+	return provider.AuthURL(state), nil
 }
 
-// HandleOAuthCallback processes the OAuth callback and stores the connection
-func (s *Service) HandleOAuthCallback(code, state string) (*models.WHOOPConnection, error) {
-	// Extract user ID from state (format: "userID:randomState")
-	if len(state) < 10 {
-		return nil, fmt.Errorf("invalid state parameter")
+// HandleOAuthCallback processes the OAuth callback and stores the connection,
+// replacing any device the user had connected before
+func (s *Service) HandleOAuthCallback(ctx context.Context, providerName, code, state string) (*models.WHOOPConnection, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, fmt.Errorf("provider %q is not configured", providerName)
 	}
-	
-	var userID string
-	for i, char := range state {
-		if char == ':' {
-			userID = state[:i]
-			break
-		}
+
+	// The state names the user only if we issued it, for this provider
+	userID, err := s.db.ConsumeOAuthState(state, provider.Name())
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidState
 	}
-	if userID == "" {
-		return nil, fmt.Errorf("invalid state format")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check OAuth state: %w", err)
 	}
 
 	// Exchange code for tokens
-	tokenResp, err := s.client.ExchangeCodeForToken(code)
+	token, err := provider.ExchangeCode(ctx, code)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
 
-	// Get user profile to verify connection
-	profile, err := s.client.GetUserProfile(tokenResp.AccessToken)
+	// Look up the device-side user to verify the connection
+	providerUserID, err := provider.UserID(ctx, token.AccessToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user profile: %w", err)
 	}
@@ -71,10 +176,11 @@ func (s *Service) HandleOAuthCallback(code, state string) (*models.WHOOPConnecti
 	// Create connection record
 	connection := &models.WHOOPConnection{
 		UserID:       userID,
-		WHOOPUserID:  fmt.Sprintf("%d", profile.UserID), // Convert numeric user ID to string
-		AccessToken:  tokenResp.AccessToken,
-		RefreshToken: tokenResp.RefreshToken,
-		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+		Provider:     provider.Name(),
+		WHOOPUserID:  providerUserID,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.ExpiresAt,
 		ConnectedAt:  time.Now(),
 		Active:       true,
 	}
@@ -82,179 +188,378 @@ func (s *Service) HandleOAuthCallback(code, state string) (*models.WHOOPConnecti
 	// Store in database
 	err = s.db.UpsertWHOOPConnection(connection)
 	if err != nil {
-		return nil, fmt.Errorf("failed to store WHOOP connection: %w", err)
+		return nil, fmt.Errorf("failed to store %s connection: %w", provider.DisplayName(), err)
 	}
 
-	log.Printf("Successfully connected WHOOP account for user %s (WHOOP ID: %d)", userID, profile.UserID)
+	log.Printf("Successfully connected %s account for user %s (%s ID: %s)", provider.DisplayName(), userID, provider.Name(), providerUserID)
 	return connection, nil
 }
 
-// RefreshTokenIfNeeded checks if token needs refresh and refreshes it
-func (s *Service) RefreshTokenIfNeeded(connection *models.WHOOPConnection) (*models.WHOOPConnection, error) {
-	// Check if token expires within next hour
-	if time.Until(connection.ExpiresAt) > time.Hour {
+// RefreshTokenIfNeeded checks if token needs refresh and refreshes it.
+// Transient failures leave the connection active; only a revoked grant
+// deactivates it, in which case the user is asked to reconnect.
+func (s *Service) RefreshTokenIfNeeded(ctx context.Context, connection *models.WHOOPConnection) (*models.WHOOPConnection, error) {
+	if time.Until(connection.ExpiresAt) > tokenRefreshWindow {
 		return connection, nil
 	}
+	return s.refreshToken(ctx, connection, false)
+}
 
-	log.Printf("Refreshing WHOOP token for user %s", connection.UserID)
+// refreshToken exchanges the connection's refresh token for a new access
+// token. With force set the refresh happens even if the stored token has not
+// reached the refresh window, e.g. after the provider rejected it early.
+func (s *Service) refreshToken(ctx context.Context, connection *models.WHOOPConnection, force bool) (*models.WHOOPConnection, error) {
+	unlock := s.lockRefresh(connection.UserID)
+	defer unlock()
 
-	// Refresh the token
-	tokenResp, err := s.client.RefreshAccessToken(connection.RefreshToken)
+	// Another sync may have refreshed the token while we waited for the lock,
+	// in which case the refresh token we were handed has already been spent
+	current, err := s.db.GetWHOOPConnection(connection.UserID)
 	if err != nil {
-		// If refresh fails, deactivate the connection
-		s.db.DeactivateWHOOPConnection(connection.UserID)
-		return nil, fmt.Errorf("failed to refresh token for user %s: %w", connection.UserID, err)
+		return nil, fmt.Errorf("failed to reload connection for user %s: %w", connection.UserID, err)
+	}
+	if current.AccessToken != connection.AccessToken {
+		return current, nil
+	}
+	if !force && time.Until(current.ExpiresAt) > tokenRefreshWindow {
+		return current, nil
+	}
+
+	provider, err := s.providerFor(current)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Refreshing %s token for user %s", provider.DisplayName(), current.UserID)
+
+	token, err := provider.RefreshToken(ctx, current.RefreshToken)
+	if err != nil {
+		if errors.Is(err, wearables.ErrTokenRevoked) {
+			log.Printf("%s grant for user %s was revoked, deactivating connection: %v", provider.DisplayName(), current.UserID, err)
+			if err := s.db.DeactivateWHOOPConnection(current.UserID); err != nil {
+				log.Printf("Failed to deactivate %s connection for user %s: %v", provider.DisplayName(), current.UserID, err)
+			}
+			s.notify(current.UserID, fmt.Sprintf("⚠️ Your %s connection has expired or was revoked, so I can't fetch your sleep and recovery data anymore.\n"+
+				"Run `/connect-%s` to reconnect your account.", provider.DisplayName(), provider.Name()))
+			return nil, fmt.Errorf("%w for user %s", ErrReauthRequired, current.UserID)
+		}
+		if errors.Is(err, wearables.ErrClientRejected) {
+			// A configuration problem rather than the user's: keep the
+			// connection for when the client ID and secret are fixed
+			err = fmt.Errorf("%s rejected the configured client ID or secret: %w", provider.DisplayName(), err)
+		}
+
+		// The current access token may still have some life left in it
+		if !force && time.Now().Before(current.ExpiresAt) {
+			log.Printf("Failed to refresh %s token for user %s, using existing token until it expires: %v", provider.DisplayName(), current.UserID, err)
+			return current, nil
+		}
+		return nil, fmt.Errorf("failed to refresh token for user %s: %w", current.UserID, err)
 	}
 
 	// Update connection with new token
-	connection.AccessToken = tokenResp.AccessToken
-	connection.RefreshToken = tokenResp.RefreshToken
-	connection.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	current.AccessToken = token.AccessToken
+	current.RefreshToken = token.RefreshToken
+	current.ExpiresAt = token.ExpiresAt
 
-	err = s.db.UpsertWHOOPConnection(connection)
+	err = s.db.UpsertWHOOPConnection(current)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update WHOOP connection: %w", err)
+		return nil, fmt.Errorf("failed to update %s connection: %w", provider.DisplayName(), err)
 	}
 
-	return connection, nil
+	return current, nil
+}
+
+// lockRefresh acquires the per-user refresh lock and returns its release func
+func (s *Service) lockRefresh(userID string) func() {
+	s.refreshMu.Lock()
+	lock, exists := s.refreshLocks[userID]
+	if !exists {
+		lock = &sync.Mutex{}
+		s.refreshLocks[userID] = lock
+	}
+	s.refreshMu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// userLocation returns the user's Slack timezone, falling back to UTC when
+// it is unknown or not a valid IANA zone
+func (s *Service) userLocation(userID string) *time.Location {
+	user, err := s.db.GetUser(userID)
+	if err != nil || user.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// notify sends a direct message to a user if a notifier is configured
+func (s *Service) notify(userID, message string) {
+	if s.notifier == nil {
+		return
+	}
+	s.notifier(userID, message)
 }
 
-// SyncUserData fetches and stores the latest WHOOP data for a user
-func (s *Service) SyncUserData(userID string) error {
-	// Get user's WHOOP connection
+// SyncUserData fetches and stores the latest data from a user's device
+func (s *Service) SyncUserData(ctx context.Context, userID string) error {
+	// Get user's device connection
 	connection, err := s.db.GetWHOOPConnection(userID)
 	if err != nil {
-		return fmt.Errorf("no WHOOP connection found for user %s: %w", userID, err)
+		return fmt.Errorf("no device connection found for user %s: %w", userID, err)
+	}
+
+	// Imported data has nothing to fetch
+	if connection.ProviderName() == models.ImportProvider {
+		s.CheckAlerts(userID)
+		return nil
+	}
+
+	provider, err := s.providerFor(connection)
+	if err != nil {
+		return err
 	}
 
 	// Refresh token if needed
-	connection, err = s.RefreshTokenIfNeeded(connection)
+	connection, err = s.RefreshTokenIfNeeded(ctx, connection)
 	if err != nil {
 		return fmt.Errorf("failed to refresh token: %w", err)
 	}
 
-	// Sync data for the last 2 days (to handle timezone differences)
+	// Sync data for the last 2 days (to handle timezone differences), reaching
+	// further back for records the device had not finished scoring last time
 	end := time.Now()
 	start := end.AddDate(0, 0, -2)
-
-	// Sync recovery data
-	if err := s.syncRecoveryData(connection, start, end); err != nil {
-		log.Printf("Failed to sync recovery data for user %s: %v", userID, err)
+	pending, err := s.db.GetOldestPendingWHOOPDate(userID, end.AddDate(0, 0, -pendingRefetchDays))
+	if err != nil {
+		log.Printf("Failed to look up pending records for user %s: %v", userID, err)
+	} else if !pending.IsZero() && pending.AddDate(0, 0, -1).Before(start) {
+		start = pending.AddDate(0, 0, -1)
 	}
 
-	// Sync sleep data
-	if err := s.syncSleepData(connection, start, end); err != nil {
-		log.Printf("Failed to sync sleep data for user %s: %v", userID, err)
+	loc := s.userLocation(userID)
+	data, err := provider.Fetch(ctx, connection.AccessToken, start, end, loc)
+	if errors.Is(err, wearables.ErrUnauthorized) {
+		// The provider rejected the token before its recorded expiry; refresh once and retry
+		connection, err = s.refreshToken(ctx, connection, true)
+		if err != nil {
+			return fmt.Errorf("failed to refresh rejected token: %w", err)
+		}
+		data, err = provider.Fetch(ctx, connection.AccessToken, start, end, loc)
+	}
+	if errors.Is(err, wearables.ErrRateLimited) {
+		return fmt.Errorf("rate limited while syncing user %s: %w", userID, err)
+	}
+	if err != nil {
+		log.Printf("Failed to sync some %s data for user %s: %v", provider.DisplayName(), userID, err)
 	}
 
+	s.storeData(connection, data)
+	s.CheckAlerts(userID)
 	return nil
 }
 
-// syncRecoveryData fetches and stores recovery data
-func (s *Service) syncRecoveryData(connection *models.WHOOPConnection, start, end time.Time) error {
-	recoveryResp, err := s.client.GetRecovery(connection.AccessToken, start, end)
-	if err != nil {
-		return fmt.Errorf("failed to get recovery data: %w", err)
+// ImportData stores records parsed from an exported file. Users without a
+// connected device get an import connection so they appear in standups;
+// users with one are refused so the import doesn't overwrite device data.
+func (s *Service) ImportData(userID string, data *wearables.Data) error {
+	connection, err := s.db.GetWHOOPConnection(userID)
+	switch {
+	case err == nil && connection.ProviderName() != models.ImportProvider:
+		return fmt.Errorf("%w: %s", ErrDeviceConnected, connection.ProviderName())
+	case errors.Is(err, sql.ErrNoRows):
+		connection = &models.WHOOPConnection{
+			UserID:      userID,
+			Provider:    models.ImportProvider,
+			ExpiresAt:   time.Now().AddDate(100, 0, 0), // there is no token to refresh
+			ConnectedAt: time.Now(),
+			Active:      true,
+		}
+		if err := s.db.UpsertWHOOPConnection(connection); err != nil {
+			return fmt.Errorf("failed to store import connection: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to look up connection for user %s: %w", userID, err)
 	}
 
-	for _, recovery := range recoveryResp.Records {
-		// Parse the date from CreatedAt (use the recovery date)
-		recoveryDate := recovery.CreatedAt.Truncate(24 * time.Hour)
+	s.storeData(connection, data)
+	s.CheckAlerts(userID)
+	return nil
+}
 
-		recoveryModel := &models.WHOOPRecovery{
-			UserID:      connection.UserID,
-			WHOOPUserID: fmt.Sprintf("%d", recovery.UserID), // Convert numeric to string
-			Date:        recoveryDate,
-			Score:       int(recovery.Score.RecoveryScore), // Convert float to int
-			HRV:         recovery.Score.HRVRmssd,
-			RHR:         int(recovery.Score.RestingHR), // Convert float to int
-			CreatedAt:   time.Now(),
+// storeData upserts everything a provider fetched for a connection
+func (s *Service) storeData(connection *models.WHOOPConnection, data *wearables.Data) {
+	if data == nil {
+		return
+	}
+	for _, sleep := range data.Sleeps {
+		if err := s.storeSleep(connection, sleep); err != nil {
+			log.Printf("Failed to store sleep data for user %s: %v", connection.UserID, err)
 		}
-
-		err := s.db.UpsertWHOOPRecovery(recoveryModel)
-		if err != nil {
+	}
+	for _, recovery := range data.Recoveries {
+		if err := s.storeRecovery(connection, recovery); err != nil {
 			log.Printf("Failed to store recovery data for user %s: %v", connection.UserID, err)
 		}
 	}
+	for _, activity := range data.Activities {
+		if err := s.storeStrain(connection, activity); err != nil {
+			log.Printf("Failed to store strain data for user %s: %v", connection.UserID, err)
+		}
+	}
+}
 
-	return nil
+// storeRecovery upserts a normalized recovery
+func (s *Service) storeRecovery(connection *models.WHOOPConnection, recovery wearables.Recovery) error {
+	return s.db.UpsertWHOOPRecovery(&models.WHOOPRecovery{
+		UserID:      connection.UserID,
+		WHOOPUserID: connection.WHOOPUserID,
+		Date:        recovery.Date,
+		ScoreState:  recovery.ScoreState,
+		Calibrating: recovery.Calibrating,
+		Score:       recovery.Score,
+		HRV:         recovery.HRV,
+		RHR:         recovery.RHR,
+		CreatedAt:   time.Now(),
+	})
 }
 
-// syncSleepData fetches and stores sleep data
-func (s *Service) syncSleepData(connection *models.WHOOPConnection, start, end time.Time) error {
-	sleepResp, err := s.client.GetSleep(connection.AccessToken, start, end)
-	if err != nil {
-		return fmt.Errorf("failed to get sleep data: %w", err)
-	}
-
-	for _, sleep := range sleepResp.Records {
-		// Use the sleep end date as the date for the sleep record
-		sleepDate := sleep.End.Truncate(24 * time.Hour)
-
-		// Calculate total sleep duration from stages
-		totalSleepMS := sleep.Score.Stage_summary.TotalLightSleepMS + 
-					   sleep.Score.Stage_summary.TotalSlowWaveSleepMS + 
-					   sleep.Score.Stage_summary.TotalRemSleepMS
-
-		// Handle sleep score - if 0, calculate based on efficiency and duration
-		sleepScore := sleep.Score.SleepScore
-		if sleepScore == 0 && sleep.Score.SleepEfficiencyPercentage > 0 {
-			// Estimate sleep score based on efficiency (this is a fallback)
-			// WHOOP's actual algorithm is more complex, but this gives a reasonable estimate
-			efficiencyFactor := sleep.Score.SleepEfficiencyPercentage / 100.0
-			durationHours := float64(totalSleepMS) / (1000 * 60 * 60)
-			if durationHours >= 7.5 && efficiencyFactor >= 0.85 {
-				sleepScore = int(75 + (efficiencyFactor-0.85)*100) // 75-90 range
-			} else if durationHours >= 6.5 && efficiencyFactor >= 0.75 {
-				sleepScore = int(60 + (efficiencyFactor-0.75)*150) // 60-75 range
-			} else {
-				sleepScore = int(efficiencyFactor * 60) // 0-60 range
-			}
-			log.Printf("Sleep score was 0, estimated as %d based on %.1f%% efficiency and %.1fh duration", 
-				sleepScore, sleep.Score.SleepEfficiencyPercentage, durationHours)
-		}
+// storeSleep upserts a normalized sleep
+func (s *Service) storeSleep(connection *models.WHOOPConnection, sleep wearables.Sleep) error {
+	return s.db.UpsertWHOOPSleep(&models.WHOOPSleep{
+		UserID:        connection.UserID,
+		WHOOPUserID:   connection.WHOOPUserID,
+		Date:          sleep.Date,
+		DurationMS:    sleep.DurationMS,
+		Efficiency:    sleep.Efficiency,
+		ScoreState:    sleep.ScoreState,
+		Score:         sleep.Score,
+		StagesDeepMS:  sleep.StagesDeepMS,
+		StagesREMS:    sleep.StagesREMMS,
+		StagesLightMS: sleep.StagesLightMS,
+		StagesWakeMS:  sleep.StagesWakeMS,
+		CreatedAt:     time.Now(),
+	})
+}
 
-		sleepModel := &models.WHOOPSleep{
-			UserID:        connection.UserID,
-			WHOOPUserID:   fmt.Sprintf("%d", sleep.UserID), // Convert numeric to string
-			Date:          sleepDate,
-			DurationMS:    totalSleepMS,
-			Efficiency:    sleep.Score.SleepEfficiencyPercentage,
-			Score:         sleepScore, // Use calculated or actual score
-			StagesDeepMS:  sleep.Score.Stage_summary.TotalSlowWaveSleepMS,
-			StagesREMS:    sleep.Score.Stage_summary.TotalRemSleepMS,
-			StagesLightMS: sleep.Score.Stage_summary.TotalLightSleepMS,
-			StagesWakeMS:  sleep.Score.Stage_summary.TotalAwakeTimeMS,
-			CreatedAt:     time.Now(),
-		}
+// storeStrain upserts a normalized day of activity as strain
+func (s *Service) storeStrain(connection *models.WHOOPConnection, activity wearables.Activity) error {
+	return s.db.UpsertWHOOPStrain(&models.WHOOPStrain{
+		UserID:      connection.UserID,
+		WHOOPUserID: connection.WHOOPUserID,
+		Date:        activity.Date,
+		ScoreState:  activity.ScoreState,
+		Score:       activity.Strain,
+		CreatedAt:   time.Now(),
+	})
+}
 
-		err := s.db.UpsertWHOOPSleep(sleepModel)
-		if err != nil {
-			log.Printf("Failed to store sleep data for user %s: %v", connection.UserID, err)
-		}
+// SyncResult summarizes a team-wide device sync by Slack user ID
+type SyncResult struct {
+	Succeeded []string
+	Failed    []string
+	Skipped   []string // needs reauthorization, or the sync ran out of time before reaching them
+}
+
+// String returns a one-line summary suitable for logs
+func (r *SyncResult) String() string {
+	return fmt.Sprintf("%d succeeded, %d failed, %d skipped", len(r.Succeeded), len(r.Failed), len(r.Skipped))
+}
+
+// syncCall tracks a team-wide sync in flight so concurrent callers share it
+type syncCall struct {
+	done   chan struct{}
+	result *SyncResult
+	err    error
+}
+
+// SyncAllUsersData syncs device data for all connected users across a bounded
+// pool of workers, giving each user their own timeout. If a team sync is
+// already running, the caller waits for it instead of starting another.
+func (s *Service) SyncAllUsersData(ctx context.Context) (*SyncResult, error) {
+	s.syncMu.Lock()
+	call := s.syncInFlight
+	if call == nil {
+		call = &syncCall{done: make(chan struct{})}
+		s.syncInFlight = call
+		s.syncMu.Unlock()
+
+		call.result, call.err = s.syncAllUsers(ctx)
+
+		s.syncMu.Lock()
+		s.syncInFlight = nil
+		s.syncMu.Unlock()
+		close(call.done)
+		return call.result, call.err
 	}
+	s.syncMu.Unlock()
 
-	return nil
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
-// SyncAllUsersData syncs WHOOP data for all connected users
-func (s *Service) SyncAllUsersData() error {
+// syncAllUsers fans user syncs out across syncWorkers goroutines
+func (s *Service) syncAllUsers(ctx context.Context) (*SyncResult, error) {
 	connections, err := s.db.GetAllActiveWHOOPConnections()
 	if err != nil {
-		return fmt.Errorf("failed to get WHOOP connections: %w", err)
+		return nil, fmt.Errorf("failed to get device connections: %w", err)
 	}
 
-	log.Printf("Syncing WHOOP data for %d users", len(connections))
+	log.Printf("Syncing wearable data for %d users", len(connections))
+
+	userIDs := make(chan string)
+	result := &SyncResult{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < min(syncWorkers, len(connections)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for userID := range userIDs {
+				userCtx, cancel := context.WithTimeout(ctx, userSyncTimeout)
+				err := s.SyncUserData(userCtx, userID)
+				cancel()
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					result.Succeeded = append(result.Succeeded, userID)
+				case errors.Is(err, ErrReauthRequired):
+					result.Skipped = append(result.Skipped, userID)
+				default:
+					log.Printf("Failed to sync data for user %s: %v", userID, err)
+					result.Failed = append(result.Failed, userID)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
 
 	for _, connection := range connections {
-		if err := s.SyncUserData(connection.UserID); err != nil {
-			log.Printf("Failed to sync data for user %s: %v", connection.UserID, err)
-			// Continue with other users
+		select {
+		case userIDs <- connection.UserID:
+		case <-ctx.Done():
+			// Out of time: whoever hasn't been handed to a worker is skipped
+			mu.Lock()
+			result.Skipped = append(result.Skipped, connection.UserID)
+			mu.Unlock()
 		}
 	}
+	close(userIDs)
+	wg.Wait()
 
-	log.Printf("Completed WHOOP data sync")
-	return nil
+	log.Printf("Completed wearable data sync: %s", result)
+	return result, nil
 }
 
 // GetConnectionStatus returns the connection status for a user
@@ -262,29 +567,95 @@ func (s *Service) GetConnectionStatus(userID string) (*models.WHOOPConnection, e
 	return s.db.GetWHOOPConnection(userID)
 }
 
-// DisconnectUser deactivates a user's WHOOP connection
-func (s *Service) DisconnectUser(userID string) error {
-	return s.db.DeactivateWHOOPConnection(userID)
+// DisconnectUser revokes a user's grant with their provider and removes the
+// connection and its tokens. Their stored data stays until DeleteUserData
+// or the retention purge removes it.
+func (s *Service) DisconnectUser(ctx context.Context, userID string) error {
+	connection, err := s.db.GetWHOOPConnection(userID)
+	if err != nil {
+		return fmt.Errorf("no device connection found for user %s: %w", userID, err)
+	}
+	s.revoke(ctx, connection)
+	return s.db.DeleteWHOOPConnection(userID)
+}
+
+// DeleteUserData disconnects the user's device if one is still connected and
+// deletes all of their stored wellness data
+func (s *Service) DeleteUserData(ctx context.Context, userID string) error {
+	if connection, err := s.db.GetWHOOPConnection(userID); err == nil {
+		s.revoke(ctx, connection)
+	}
+	if err := s.db.DeleteWHOOPUserData(userID); err != nil {
+		return fmt.Errorf("failed to delete data for user %s: %w", userID, err)
+	}
+	log.Printf("Deleted all wellness data for user %s", userID)
+	return nil
 }
 
-// GetUserLatestData returns the latest WHOOP data for a user
-func (s *Service) GetUserLatestData(userID string) (map[string]interface{}, error) {
-	data := make(map[string]interface{})
+// revoke asks the connection's provider to revoke the grant. It's best
+// effort: the connection is removed on our side either way.
+func (s *Service) revoke(ctx context.Context, connection *models.WHOOPConnection) {
+	provider, err := s.providerFor(connection)
+	if err != nil {
+		return
+	}
+	revoker, ok := provider.(wearables.Revoker)
+	if !ok {
+		return
+	}
+
+	current, err := s.RefreshTokenIfNeeded(ctx, connection)
+	if err != nil {
+		log.Printf("Skipping %s revocation for user %s: %v", provider.DisplayName(), connection.UserID, err)
+		return
+	}
+	if err := revoker.Revoke(ctx, current.AccessToken); err != nil {
+		log.Printf("Failed to revoke %s access for user %s: %v", provider.DisplayName(), connection.UserID, err)
+		return
+	}
+	log.Printf("Revoked %s access for user %s", provider.DisplayName(), connection.UserID)
+}
 
-	// Get latest recovery data
-	if recovery, err := s.db.GetLatestWHOOPRecovery(userID); err == nil {
-		data["recovery"] = recovery
+// PurgeExpiredData deletes daily records older than retentionDays, along
+// with deactivated connections
+func (s *Service) PurgeExpiredData(retentionDays int) (int64, error) {
+	purged, err := s.db.PurgeWHOOPDataBefore(windowStart(retentionDays))
+	if err != nil {
+		return 0, err
 	}
+	log.Printf("Purged %d wellness rows older than %d days", purged, retentionDays)
+	return purged, nil
+}
+
+// GetUserLatestData returns the latest device data for a user
+func (s *Service) GetUserLatestData(userID string) (*models.UserSnapshot, error) {
+	return s.db.GetUserWHOOPSnapshot(userID)
+}
 
-	// Get latest sleep data  
-	if sleep, err := s.db.GetLatestWHOOPSleep(userID); err == nil {
-		data["sleep"] = sleep
+// GetUserAverages returns a user's WHOOP averages over the last days days,
+// today included
+func (s *Service) GetUserAverages(userID string, days int) (*models.WHOOPAverages, error) {
+	averages, err := s.db.GetWHOOPAverages(userID, windowStart(days))
+	if err != nil {
+		return nil, err
 	}
+	averages.Days = days
+	return averages, nil
+}
 
-	// Get latest strain data
-	if strain, err := s.db.GetLatestWHOOPStrain(userID); err == nil {
-		data["strain"] = strain
+// GetTeamAverages returns WHOOP averages across all connected users over the
+// last days days, today included
+func (s *Service) GetTeamAverages(days int) (*models.WHOOPAverages, error) {
+	averages, err := s.db.GetTeamWHOOPAverages(windowStart(days))
+	if err != nil {
+		return nil, err
 	}
+	averages.Days = days
+	return averages, nil
+}
 
-	return data, nil
-}
\ No newline at end of file
+// windowStart returns the first calendar day of a window of days ending today
+func windowStart(days int) time.Time {
+	year, month, day := time.Now().Date()
+	return time.Date(year, month, day-(days-1), 0, 0, 0, 0, time.UTC)
+}
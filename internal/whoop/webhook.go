@@ -0,0 +1,176 @@
+package whoop
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// webhookQueueSize bounds how many webhook deliveries can wait for processing
+	webhookQueueSize = 256
+	// webhookMaxAge rejects deliveries whose signed timestamp is older than this
+	webhookMaxAge = 5 * time.Minute
+	// webhookFetchTimeout bounds fetching and storing the record behind one event
+	webhookFetchTimeout = time.Minute
+)
+
+// Webhook event types sent by WHOOP
+const (
+	WebhookRecoveryUpdated = "recovery.updated"
+	WebhookRecoveryDeleted = "recovery.deleted"
+	WebhookSleepUpdated    = "sleep.updated"
+	WebhookSleepDeleted    = "sleep.deleted"
+	WebhookWorkoutUpdated  = "workout.updated"
+	WebhookWorkoutDeleted  = "workout.deleted"
+)
+
+// ErrWebhookQueueFull is returned when webhook deliveries arrive faster than
+// they can be processed; WHOOP will redeliver after a non-2xx response
+var ErrWebhookQueueFull = errors.New("webhook queue is full")
+
+// WebhookEvent is the payload WHOOP posts to the webhook endpoint. ID refers
+// to the updated object: the cycle for recovery events, otherwise the sleep
+// or workout itself.
+type WebhookEvent struct {
+	UserID  int64  `json:"user_id"`
+	ID      int64  `json:"id"`
+	Type    string `json:"type"`
+	TraceID string `json:"trace_id"`
+}
+
+// VerifyWebhookSignature checks the X-WHOOP-Signature header, which is the
+// base64 HMAC-SHA256 of the timestamp header followed by the raw body, keyed
+// with the app's client secret
+func (c *Client) VerifyWebhookSignature(body []byte, timestamp, signature string) error {
+	if timestamp == "" || signature == "" {
+		return errors.New("missing signature headers")
+	}
+
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid signature timestamp")
+	}
+	if age := time.Since(time.UnixMilli(millis)); age > webhookMaxAge || age < -webhookMaxAge {
+		return errors.New("signature timestamp outside the allowed window")
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.clientSecret))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// VerifyWebhookSignature checks a webhook delivery against the client secret
+func (s *Service) VerifyWebhookSignature(body []byte, timestamp, signature string) error {
+	return s.client.VerifyWebhookSignature(body, timestamp, signature)
+}
+
+// EnqueueWebhook queues a verified webhook event for ProcessWebhooks
+func (s *Service) EnqueueWebhook(event WebhookEvent) error {
+	select {
+	case s.webhooks <- event:
+		return nil
+	default:
+		return ErrWebhookQueueFull
+	}
+}
+
+// ProcessWebhooks fetches and stores the records referenced by queued
+// webhook events until ctx is cancelled
+func (s *Service) ProcessWebhooks(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-s.webhooks:
+			eventCtx, cancel := context.WithTimeout(ctx, webhookFetchTimeout)
+			if err := s.handleWebhook(eventCtx, event); err != nil {
+				log.Printf("Failed to process WHOOP webhook %s for WHOOP user %d (trace %s): %v",
+					event.Type, event.UserID, event.TraceID, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// handleWebhook fetches the object a webhook refers to and updates stored data
+func (s *Service) handleWebhook(ctx context.Context, event WebhookEvent) error {
+	if strings.HasSuffix(event.Type, ".deleted") {
+		// Stored rows are keyed by day rather than WHOOP record ID, so there is
+		// nothing to delete precisely; the next sync overwrites the day anyway
+		log.Printf("Ignoring WHOOP webhook %s for record %d", event.Type, event.ID)
+		return nil
+	}
+
+	connection, err := s.db.GetWHOOPConnectionByWHOOPUserID(strconv.FormatInt(event.UserID, 10))
+	if err != nil {
+		return fmt.Errorf("no active connection for WHOOP user %d: %w", event.UserID, err)
+	}
+
+	connection, err = s.RefreshTokenIfNeeded(ctx, connection)
+	if err != nil {
+		return fmt.Errorf("failed to refresh token: %w", err)
+	}
+
+	loc := s.userLocation(connection.UserID)
+	switch event.Type {
+	case WebhookRecoveryUpdated:
+		recovery, err := s.client.GetCycleRecovery(ctx, connection.AccessToken, event.ID)
+		if err != nil {
+			return err
+		}
+		// The recovery's sleep tells us which timezone the user woke up in
+		offset := ""
+		if sleep, err := s.client.GetSleepByID(ctx, connection.AccessToken, recovery.SleepID); err == nil {
+			offset = sleep.TimezoneOffset
+		}
+		if err := s.storeRecovery(connection, normalizeRecovery(*recovery, offset, loc)); err != nil {
+			return err
+		}
+
+	case WebhookSleepUpdated:
+		sleep, err := s.client.GetSleepByID(ctx, connection.AccessToken, event.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.storeSleep(connection, normalizeSleep(*sleep, loc)); err != nil {
+			return err
+		}
+
+	case WebhookWorkoutUpdated:
+		// Workouts feed into the day's strain, which lives on the cycle
+		workout, err := s.client.GetWorkoutByID(ctx, connection.AccessToken, event.ID)
+		if err != nil {
+			return err
+		}
+		cycles, err := s.client.GetCycles(ctx, connection.AccessToken, workout.Start.Add(-24*time.Hour), workout.End)
+		if err != nil {
+			return err
+		}
+		for _, cycle := range cycles.Records {
+			if err := s.storeStrain(connection, normalizeCycle(cycle, loc)); err != nil {
+				return err
+			}
+		}
+
+	default:
+		log.Printf("Ignoring unknown WHOOP webhook type %q", event.Type)
+		return nil
+	}
+
+	s.CheckAlerts(connection.UserID)
+	return nil
+}
@@ -0,0 +1,197 @@
+package whoop
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pratikgajjar/fambot-go/internal/database"
+	"github.com/pratikgajjar/fambot-go/internal/models"
+	"github.com/pratikgajjar/fambot-go/internal/secrets"
+)
+
+const (
+	testClientSecret = "test-secret"
+	testAccessToken  = "test-access-token"
+	testWHOOPUserID  = 42
+)
+
+// newWebhookTestService returns a service backed by a temporary database,
+// with a WHOOP client pointed at api and one connected user, U1
+func newWebhookTestService(t *testing.T, api *httptest.Server) (*Service, *database.Database) {
+	t.Helper()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "fambot.db"))
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	keyring, err := secrets.ParseKeyring("test:" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+	db.SetTokenKeyring(keyring)
+
+	err = db.UpsertWHOOPConnection(&models.WHOOPConnection{
+		UserID:       "U1",
+		Provider:     ProviderName,
+		WHOOPUserID:  strconv.Itoa(testWHOOPUserID),
+		AccessToken:  testAccessToken,
+		RefreshToken: "test-refresh-token",
+		ExpiresAt:    time.Now().Add(24 * time.Hour),
+		ConnectedAt:  time.Now(),
+		Active:       true,
+	})
+	if err != nil {
+		t.Fatalf("UpsertWHOOPConnection: %v", err)
+	}
+
+	client := NewClient("test-client", testClientSecret, "http://localhost/whoop/callback")
+	if api != nil {
+		client.SetEndpoints(api.URL, api.URL+"/oauth/oauth2/token")
+	}
+	service := NewService(db)
+	service.RegisterProvider(NewProvider(client))
+	return service, db
+}
+
+// signedWebhook builds a webhook delivery signed the way WHOOP signs them
+func signedWebhook(body string, at time.Time, secret string) *http.Request {
+	timestamp := strconv.FormatInt(at.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte(body))
+
+	req := httptest.NewRequest(http.MethodPost, "/whoop/webhook", bytes.NewBufferString(body))
+	req.Header.Set("X-WHOOP-Signature-Timestamp", timestamp)
+	req.Header.Set("X-WHOOP-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+// deliver sends a webhook request through the OAuth server's routes
+func deliver(service *Service, req *http.Request) int {
+	mux := http.NewServeMux()
+	NewOAuthServer(service).RegisterRoutes(mux)
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func TestWebhookSignature(t *testing.T) {
+	body := fmt.Sprintf(`{"user_id":%d,"id":100,"type":"recovery.updated","trace_id":"trace-1"}`, testWHOOPUserID)
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		queued bool
+	}{
+		{"wrong secret", signedWebhook(body, time.Now(), "not-the-secret"), http.StatusUnauthorized, false},
+		{"stale timestamp", signedWebhook(body, time.Now().Add(-time.Hour), testClientSecret), http.StatusUnauthorized, false},
+		{"missing headers", httptest.NewRequest(http.MethodPost, "/whoop/webhook", bytes.NewBufferString(body)), http.StatusUnauthorized, false},
+		{"valid signature", signedWebhook(body, time.Now(), testClientSecret), http.StatusNoContent, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newWebhookTestService(t, nil)
+
+			if status := deliver(service, tt.req); status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+
+			select {
+			case event := <-service.webhooks:
+				if !tt.queued {
+					t.Fatalf("queued %+v, want nothing queued", event)
+				}
+				want := WebhookEvent{UserID: testWHOOPUserID, ID: 100, Type: WebhookRecoveryUpdated, TraceID: "trace-1"}
+				if event != want {
+					t.Errorf("queued %+v, want %+v", event, want)
+				}
+			default:
+				if tt.queued {
+					t.Fatal("nothing was queued")
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookFetchesAndStoresRecords(t *testing.T) {
+	// A fake WHOOP API serving one recovery and the sleep it belongs to. The
+	// user wakes up in Tokyo, so records dated by UTC would land a day early.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/cycle/100/recovery", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"cycle_id":100,"sleep_id":200,"user_id":%d,"created_at":"2024-03-04T22:30:00Z",
+			"score_state":"SCORED","score":{"user_calibrating":false,"recovery_score":72,
+			"hrv_rmssd_milli":55.5,"resting_heart_rate":52}}`, testWHOOPUserID)
+	})
+	mux.HandleFunc("/v1/activity/sleep/200", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":200,"user_id":%d,"start":"2024-03-04T14:00:00Z","end":"2024-03-04T22:00:00Z",
+			"timezone_offset":"+09:00","score_state":"SCORED","score":{"stage_summary":{
+			"total_light_sleep_time_milli":14400000,"total_slow_wave_sleep_time_milli":5400000,
+			"total_rem_sleep_time_milli":7200000,"total_awake_time_milli":1800000},
+			"sleep_efficiency_percentage":91,"sleep_score":84}}`, testWHOOPUserID)
+	})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer api.Close()
+
+	service, db := newWebhookTestService(t, api)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.ProcessWebhooks(ctx)
+
+	for _, body := range []string{
+		fmt.Sprintf(`{"user_id":%d,"id":100,"type":"recovery.updated","trace_id":"trace-1"}`, testWHOOPUserID),
+		fmt.Sprintf(`{"user_id":%d,"id":200,"type":"sleep.updated","trace_id":"trace-2"}`, testWHOOPUserID),
+	} {
+		if status := deliver(service, signedWebhook(body, time.Now(), testClientSecret)); status != http.StatusNoContent {
+			t.Fatalf("delivering %s: status %d", body, status)
+		}
+	}
+
+	var recovery *models.WHOOPRecovery
+	var sleep *models.WHOOPSleep
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		recovery, _ = db.GetLatestWHOOPRecovery("U1")
+		sleep, _ = db.GetLatestWHOOPSleep("U1")
+		if recovery != nil && sleep != nil {
+			break
+		}
+	}
+	if recovery == nil || sleep == nil {
+		t.Fatalf("records weren't stored: recovery %v, sleep %v", recovery, sleep)
+	}
+
+	// 22:30 UTC is 07:30 the next morning in Tokyo
+	if got := recovery.Date.Format("2006-01-02"); got != "2024-03-05" {
+		t.Errorf("recovery date = %s, want 2024-03-05", got)
+	}
+	if recovery.Score != 72 || recovery.HRV != 55.5 || recovery.RHR != 52 || recovery.ScoreState != models.ScoreStateScored {
+		t.Errorf("recovery = %+v", recovery)
+	}
+
+	if got := sleep.Date.Format("2006-01-02"); got != "2024-03-05" {
+		t.Errorf("sleep date = %s, want 2024-03-05", got)
+	}
+	if sleep.Score != 84 || sleep.DurationMS != 27000000 || sleep.Efficiency != 91 {
+		t.Errorf("sleep = %+v", sleep)
+	}
+}
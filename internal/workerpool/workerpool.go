@@ -0,0 +1,115 @@
+// Package workerpool runs tasks on a fixed set of workers with bounded
+// queues, keeping tasks that share a key in submission order
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"github.com/pratikgajjar/fambot-go/internal/metrics"
+)
+
+// ErrClosed is returned when submitting to a pool that is shutting down
+var ErrClosed = errors.New("worker pool is shut down")
+
+// Pool is a set of workers, each with its own queue. A task's key picks its
+// worker, so tasks with the same key run one at a time in order while
+// different keys run in parallel.
+type Pool struct {
+	queues   []chan func()
+	stopping chan struct{}
+	stopOnce sync.Once
+	mu       sync.RWMutex // held for reading while sending, for writing while closing queues
+	wg       sync.WaitGroup
+}
+
+// New starts a pool of workers, each queueing up to queueSize tasks
+func New(workers, queueSize int) *Pool {
+	p := &Pool{
+		queues:   make([]chan func(), max(workers, 1)),
+		stopping: make(chan struct{}),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), queueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+// Submit queues task behind earlier tasks with the same key. It blocks while
+// that worker's queue is full, until ctx is done or the pool shuts down.
+func (p *Pool) Submit(ctx context.Context, key string, task func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	select {
+	case <-p.stopping:
+		return ErrClosed
+	default:
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	queue := p.queues[h.Sum32()%uint32(len(p.queues))]
+
+	select {
+	case queue <- task:
+		return nil
+	case <-p.stopping:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops accepting tasks and waits for queued ones to finish, or
+// for ctx to expire
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		// Wake blocked submitters first so they release the read lock
+		close(p.stopping)
+		p.mu.Lock()
+		for _, queue := range p.queues {
+			close(queue)
+		}
+		p.mu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work runs a queue's tasks until it is closed and empty
+func (p *Pool) work(queue chan func()) {
+	defer p.wg.Done()
+	for task := range queue {
+		run(task)
+	}
+}
+
+// run executes task, recovering a panic so one bad event doesn't stop the
+// worker or the bot
+func run(task func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Worker task panicked: %v\n%s", r, debug.Stack())
+			metrics.WorkerPanics.Inc()
+		}
+	}()
+	task()
+}